@@ -0,0 +1,84 @@
+/*
+ * Copyright (c) 2026 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package codec
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type payload struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+var _ = Describe("Codec", func() {
+	Describe("[Unit]", func() {
+		Describe("New", func() {
+			It("should create codecs by name", func() {
+				c, err := New(NameJSON)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(c).To(Equal(JSON{}))
+				c, err = New(NameProtobuf)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(c).To(Equal(Protobuf{}))
+			})
+
+			It("should fail with an unknown name", func() {
+				_, err := New("xml")
+				Expect(err).To(HaveOccurred())
+			})
+		})
+
+		Describe("JSON", func() {
+			It("should encode and decode values", func() {
+				data, err := JSON{}.Marshal(&payload{ID: 1, Name: "test"})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(data).To(MatchJSON(`{"id":1,"name":"test"}`))
+
+				var decoded payload
+				Expect(JSON{}.Unmarshal(data, &decoded)).To(Succeed())
+				Expect(decoded).To(Equal(payload{ID: 1, Name: "test"}))
+			})
+		})
+
+		Describe("Protobuf", func() {
+			It("should encode and decode messages", func() {
+				data, err := Protobuf{}.Marshal(wrapperspb.String("test"))
+				Expect(err).NotTo(HaveOccurred())
+
+				decoded := &wrapperspb.StringValue{}
+				Expect(Protobuf{}.Unmarshal(data, decoded)).To(Succeed())
+				Expect(proto.Equal(decoded, wrapperspb.String("test"))).To(BeTrue())
+			})
+
+			It("should fail with values that are not messages", func() {
+				_, err := Protobuf{}.Marshal(&payload{})
+				Expect(err).To(HaveOccurred())
+				Expect(Protobuf{}.Unmarshal([]byte{}, &payload{})).NotTo(Succeed())
+			})
+		})
+	})
+})
@@ -0,0 +1,144 @@
+/*
+ * Copyright (c) 2026 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// MagicByte is the first byte of values encoded with the schema registry wire format
+const MagicByte byte = 0
+
+// ErrInvalidWireFormat is returned when decoding data not encoded with the schema registry wire format
+var ErrInvalidWireFormat = errors.New("codec: data is not in the schema registry wire format")
+
+// Registry stores schemas by subject, identifying each schema by a unique id
+type Registry interface {
+	// Register returns the id of schema under subject, registering it if needed
+	Register(subject, schema string) (int, error)
+	// Schema returns the schema registered with id
+	Schema(id int) (string, error)
+}
+
+// InMemoryRegistry is a Registry kept in memory, meant for tests and local development
+type InMemoryRegistry struct {
+	mutex    sync.RWMutex
+	ids      map[string]int
+	schemas  map[int]string
+	subjects map[int]string
+}
+
+// NewInMemoryRegistry creates a new InMemoryRegistry
+func NewInMemoryRegistry() *InMemoryRegistry {
+	return &InMemoryRegistry{
+		ids:      map[string]int{},
+		schemas:  map[int]string{},
+		subjects: map[int]string{},
+	}
+}
+
+// Register returns the id of schema under subject, registering it if needed
+func (r *InMemoryRegistry) Register(subject, schema string) (int, error) {
+	key := subject + "\x00" + schema
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if id, ok := r.ids[key]; ok {
+		return id, nil
+	}
+	id := len(r.ids) + 1
+	r.ids[key] = id
+	r.schemas[id] = schema
+	r.subjects[id] = subject
+	return id, nil
+}
+
+// Schema returns the schema registered with id
+func (r *InMemoryRegistry) Schema(id int) (string, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	schema, ok := r.schemas[id]
+	if !ok {
+		return "", fmt.Errorf("codec: schema %d not found", id)
+	}
+	return schema, nil
+}
+
+// RegistryCodec wraps a Codec with the schema registry wire format: a magic byte followed
+// by the 4 byte big-endian id of the schema and the value encoded by the wrapped Codec
+type RegistryCodec struct {
+	Codec    Codec
+	Registry Registry
+	Subject  string
+	Schema   string
+}
+
+// NewRegistryCodec creates a RegistryCodec encoding values of schema registered under subject
+func NewRegistryCodec(codec Codec, registry Registry, subject, schema string) *RegistryCodec {
+	return &RegistryCodec{
+		Codec:    codec,
+		Registry: registry,
+		Subject:  subject,
+		Schema:   schema,
+	}
+}
+
+// Marshal encodes v with the wrapped Codec, prefixed by the id of the codec schema
+func (c *RegistryCodec) Marshal(v interface{}) ([]byte, error) {
+	id, err := c.Registry.Register(c.Subject, c.Schema)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := c.Codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, 5, 5+len(payload))
+	data[0] = MagicByte
+	binary.BigEndian.PutUint32(data[1:5], uint32(id))
+	return append(data, payload...), nil
+}
+
+// Unmarshal decodes data into v with the wrapped Codec, failing if the schema id
+// in data is unknown to the registry
+func (c *RegistryCodec) Unmarshal(data []byte, v interface{}) error {
+	id, payload, err := SplitWireFormat(data)
+	if err != nil {
+		return err
+	}
+	_, err = c.Registry.Schema(id)
+	if err != nil {
+		return err
+	}
+	return c.Codec.Unmarshal(payload, v)
+}
+
+// SplitWireFormat returns the schema id and the payload of data encoded with the schema registry wire format
+func SplitWireFormat(data []byte) (int, []byte, error) {
+	if len(data) < 5 || data[0] != MagicByte {
+		return 0, nil, ErrInvalidWireFormat
+	}
+	return int(binary.BigEndian.Uint32(data[1:5])), data[5:], nil
+}
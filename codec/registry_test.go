@@ -0,0 +1,91 @@
+/*
+ * Copyright (c) 2026 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package codec
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Registry", func() {
+	var registry *InMemoryRegistry
+
+	BeforeEach(func() {
+		registry = NewInMemoryRegistry()
+	})
+
+	Describe("[Unit]", func() {
+		Describe("InMemoryRegistry", func() {
+			It("should return the same id for the same schema", func() {
+				id, err := registry.Register("payload-value", "schema-v1")
+				Expect(err).NotTo(HaveOccurred())
+				again, err := registry.Register("payload-value", "schema-v1")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(again).To(Equal(id))
+
+				other, err := registry.Register("payload-value", "schema-v2")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(other).NotTo(Equal(id))
+
+				schema, err := registry.Schema(other)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(schema).To(Equal("schema-v2"))
+			})
+
+			It("should fail for unknown ids", func() {
+				_, err := registry.Schema(42)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+
+		Describe("RegistryCodec", func() {
+			It("should prefix values with the magic byte and the schema id", func() {
+				registry.Register("other-value", "other")
+				c := NewRegistryCodec(JSON{}, registry, "payload-value", "schema-v1")
+				data, err := c.Marshal(&payload{ID: 1})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(data[:5]).To(Equal([]byte{MagicByte, 0, 0, 0, 2}))
+
+				id, rest, err := SplitWireFormat(data)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(id).To(Equal(2))
+				Expect(rest).To(MatchJSON(`{"id":1,"name":""}`))
+
+				var decoded payload
+				Expect(c.Unmarshal(data, &decoded)).To(Succeed())
+				Expect(decoded).To(Equal(payload{ID: 1}))
+			})
+
+			It("should fail to decode data without the wire format", func() {
+				c := NewRegistryCodec(JSON{}, registry, "payload-value", "schema-v1")
+				Expect(c.Unmarshal([]byte(`{"id":1}`), &payload{})).To(Equal(ErrInvalidWireFormat))
+				Expect(c.Unmarshal([]byte{MagicByte, 0}, &payload{})).To(Equal(ErrInvalidWireFormat))
+			})
+
+			It("should fail to decode data with an unknown schema id", func() {
+				c := NewRegistryCodec(JSON{}, registry, "payload-value", "schema-v1")
+				Expect(c.Unmarshal([]byte{MagicByte, 0, 0, 0, 9, '{', '}'}, &payload{})).NotTo(Succeed())
+			})
+		})
+	})
+})
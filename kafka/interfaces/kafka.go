@@ -28,6 +28,8 @@ import "github.com/confluentinc/confluent-kafka-go/kafka"
 type KafkaProducerClient interface {
 	Events() chan kafka.Event
 	ProduceChannel() chan *kafka.Message
+	Flush(int) int
+	Close()
 }
 
 // KafkaConsumerClient interface
@@ -36,5 +38,8 @@ type KafkaConsumerClient interface {
 	Events() chan kafka.Event
 	Assign([]kafka.TopicPartition) error
 	Unassign() error
+	Pause([]kafka.TopicPartition) error
+	Resume([]kafka.TopicPartition) error
+	CommitOffsets([]kafka.TopicPartition) ([]kafka.TopicPartition, error)
 	Close() error
 }
@@ -0,0 +1,228 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// BatchHandler processes a batch of messages consumed from kafka
+type BatchHandler func([]*Message) error
+
+// Batcher accumulates the messages of a Consumer and hands them over to a BatchHandler
+// once MaxSize messages were received or MaxWait elapsed since the first message of the batch.
+// Messages are only acknowledged, and therefore committed, after the whole batch is handled,
+// which requires the consumer to use manual commit
+type Batcher struct {
+	Config     *viper.Viper
+	Consumer   *Consumer
+	Handler    BatchHandler
+	Logger     *logrus.Logger
+	MaxSize    int
+	MaxWait    time.Duration
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// NewBatcher for creating a new Batcher instance
+func NewBatcher(
+	config *viper.Viper,
+	logger *logrus.Logger,
+	consumer *Consumer,
+	handler BatchHandler,
+) (*Batcher, error) {
+	return NewBatcherWithPrefix(config, logger, "extensions.kafkaconsumer.batch", consumer, handler)
+}
+
+// NewBatcherWithPrefix for creating a new Batcher instance
+func NewBatcherWithPrefix(
+	config *viper.Viper,
+	logger *logrus.Logger,
+	prefix string,
+	consumer *Consumer,
+	handler BatchHandler,
+) (*Batcher, error) {
+	if prefix != "" {
+		prefix += "."
+	}
+	b := &Batcher{
+		Config:   config,
+		Consumer: consumer,
+		Handler:  handler,
+		Logger:   logger,
+	}
+	err := b.configure(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *Batcher) loadConfigurationDefaults(prefix string) {
+	b.Config.SetDefault(prefix+"maxSize", 100)
+	b.Config.SetDefault(prefix+"maxWait", 1000)
+	b.Config.SetDefault(prefix+"backoff", 100)
+	b.Config.SetDefault(prefix+"maxBackoff", 5000)
+}
+
+func (b *Batcher) configure(prefix string) error {
+	b.loadConfigurationDefaults(prefix)
+	b.MaxSize = b.Config.GetInt(prefix + "maxSize")
+	b.MaxWait = time.Duration(b.Config.GetInt(prefix+"maxWait")) * time.Millisecond
+	b.Backoff = time.Duration(b.Config.GetInt(prefix+"backoff")) * time.Millisecond
+	b.MaxBackoff = time.Duration(b.Config.GetInt(prefix+"maxBackoff")) * time.Millisecond
+
+	if b.MaxSize < 1 {
+		return fmt.Errorf("%smaxSize must be at least 1", prefix)
+	}
+	if b.MaxWait <= 0 {
+		return fmt.Errorf("%smaxWait must be positive", prefix)
+	}
+	if !b.Consumer.ManualCommit {
+		return fmt.Errorf("the consumer must use manual commit to be used by a batcher, auto commit would commit batches before they are handled")
+	}
+	return nil
+}
+
+// Run accumulates the consumed messages in batches until ctx is cancelled. A failed batch
+// is retried with exponential backoff until it succeeds, so the consumer stops progressing
+// instead of skipping messages. When ctx is cancelled the messages already delivered by the
+// consumer are handled in a last batch, which is attempted once. The messages of a last batch
+// that fails, or of a batch that was still being retried, are released without being
+// committed, so Shutdown does not wait for them and they are consumed again after a restart
+func (b *Batcher) Run(ctx context.Context) {
+	l := b.Logger.WithFields(logrus.Fields{
+		"method":  "Run",
+		"maxSize": b.MaxSize,
+		"maxWait": b.MaxWait,
+	})
+	l.Info("batcher started")
+
+	handles := *b.Consumer.MessageHandlesChannel()
+	batch := make([]*Message, 0, b.MaxSize)
+	timer := time.NewTimer(b.MaxWait)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case m := <-handles:
+			if len(batch) == 0 {
+				timer.Reset(b.MaxWait)
+			}
+			batch = append(batch, m)
+			if len(batch) < b.MaxSize {
+				continue
+			}
+			if !timer.Stop() {
+				<-timer.C
+			}
+		case <-timer.C:
+		case <-ctx.Done():
+			l.Info("draining batcher")
+			b.drain(handles, batch)
+			l.Info("batcher stopped")
+			return
+		}
+
+		if !b.flush(ctx, batch) {
+			releaseBatch(pending(handles, batch))
+			l.Info("batcher stopped")
+			return
+		}
+		batch = make([]*Message, 0, b.MaxSize)
+	}
+}
+
+// flush handles batch until it succeeds, returning false if ctx was cancelled before that
+func (b *Batcher) flush(ctx context.Context, batch []*Message) bool {
+	backoff := b.Backoff
+	for attempt := 1; ; attempt++ {
+		err := b.Handler(batch)
+		if err == nil {
+			ackBatch(batch)
+			return true
+		}
+		b.Logger.WithFields(logrus.Fields{
+			"method":  "flush",
+			"size":    len(batch),
+			"attempt": attempt,
+		}).WithError(err).Warn("error handling batch")
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > b.MaxBackoff {
+			backoff = b.MaxBackoff
+		}
+	}
+}
+
+func (b *Batcher) drain(handles chan *Message, batch []*Message) {
+	batch = pending(handles, batch)
+	if len(batch) == 0 {
+		return
+	}
+	err := b.Handler(batch)
+	if err != nil {
+		b.Logger.WithFields(logrus.Fields{
+			"method": "drain",
+			"size":   len(batch),
+		}).WithError(err).Error("error handling last batch, messages will be consumed again")
+		releaseBatch(batch)
+		return
+	}
+	ackBatch(batch)
+}
+
+// pending appends the messages already delivered to handles to batch
+func pending(handles chan *Message, batch []*Message) []*Message {
+	for {
+		select {
+		case m := <-handles:
+			batch = append(batch, m)
+		default:
+			return batch
+		}
+	}
+}
+
+func ackBatch(batch []*Message) {
+	for _, m := range batch {
+		m.Ack()
+	}
+}
+
+func releaseBatch(batch []*Message) {
+	for _, m := range batch {
+		m.release()
+	}
+}
@@ -0,0 +1,251 @@
+/*
+ * Copyright (c) 2016 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/kafka/mocks"
+)
+
+var _ = Describe("Batcher", func() {
+	logger, _ := test.NewNullLogger()
+	var config *viper.Viper
+	var consumer *Consumer
+	topic := "com.games.test"
+
+	deliver := func(offset int64) {
+		consumer.receiveMessage(&kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 0, Offset: kafka.Offset(offset)},
+			Value:          []byte(fmt.Sprintf("message-%d", offset)),
+		}, nil)
+	}
+
+	offsetsOf := func(batch []*Message) []int64 {
+		offsets := []int64{}
+		for _, m := range batch {
+			offsets = append(offsets, m.Offset)
+		}
+		return offsets
+	}
+
+	BeforeEach(func() {
+		config = viper.New()
+		config.Set("extensions.kafkaconsumer.manualCommit", true)
+		config.Set("extensions.kafkaconsumer.batch.maxSize", 3)
+		config.Set("extensions.kafkaconsumer.batch.maxWait", 50)
+		config.Set("extensions.kafkaconsumer.batch.backoff", 1)
+		var err error
+		consumer, err = NewConsumer(config, logger, mocks.NewConsumerClientMock())
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("[Unit]", func() {
+		Describe("Configuration", func() {
+			It("should configure defaults", func() {
+				batcher, err := NewBatcher(viper.New(), logger, consumer, nil)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(batcher.MaxSize).To(Equal(100))
+				Expect(batcher.MaxWait).To(Equal(time.Second))
+				Expect(batcher.Backoff).To(Equal(100 * time.Millisecond))
+				Expect(batcher.MaxBackoff).To(Equal(5 * time.Second))
+			})
+
+			It("should fail if the consumer does not deliver message handles", func() {
+				consumer, err := NewConsumer(viper.New(), logger, mocks.NewConsumerClientMock())
+				Expect(err).NotTo(HaveOccurred())
+				_, err = NewBatcher(config, logger, consumer, nil)
+				Expect(err).To(HaveOccurred())
+			})
+
+			It("should fail if the consumer commits automatically", func() {
+				consumerConfig := viper.New()
+				consumerConfig.Set("extensions.kafkaconsumer.messageHandles", true)
+				consumer, err := NewConsumer(consumerConfig, logger, mocks.NewConsumerClientMock())
+				Expect(err).NotTo(HaveOccurred())
+				_, err = NewBatcher(config, logger, consumer, nil)
+				Expect(err).To(MatchError(ContainSubstring("manual commit")))
+			})
+
+			It("should fail with an invalid size", func() {
+				config.Set("extensions.kafkaconsumer.batch.maxSize", 0)
+				_, err := NewBatcher(config, logger, consumer, nil)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+
+		Describe("Run", func() {
+			var mutex sync.Mutex
+			var batches [][]int64
+			var ctx context.Context
+			var cancel context.CancelFunc
+			var done chan bool
+
+			run := func(handler BatchHandler) {
+				batcher, err := NewBatcher(config, logger, consumer, handler)
+				Expect(err).NotTo(HaveOccurred())
+				done = make(chan bool)
+				go func() {
+					defer GinkgoRecover()
+					batcher.Run(ctx)
+					close(done)
+				}()
+			}
+
+			recorded := func() [][]int64 {
+				mutex.Lock()
+				defer mutex.Unlock()
+				return batches
+			}
+
+			record := func(batch []*Message) error {
+				mutex.Lock()
+				defer mutex.Unlock()
+				batches = append(batches, offsetsOf(batch))
+				return nil
+			}
+
+			BeforeEach(func() {
+				batches = nil
+				ctx, cancel = context.WithCancel(context.Background())
+			})
+
+			AfterEach(func() {
+				cancel()
+				Eventually(done).Should(BeClosed())
+			})
+
+			It("should hand over a batch once it is full", func() {
+				config.Set("extensions.kafkaconsumer.batch.maxWait", 60000)
+				run(record)
+				for offset := int64(0); offset < 6; offset++ {
+					deliver(offset)
+				}
+				Eventually(recorded).Should(Equal([][]int64{{0, 1, 2}, {3, 4, 5}}))
+			})
+
+			It("should hand over a partial batch once maxWait elapses", func() {
+				run(record)
+				deliver(0)
+				deliver(1)
+				Eventually(recorded).Should(Equal([][]int64{{0, 1}}))
+			})
+
+			It("should commit the batch only after the handler succeeds", func() {
+				failures := 2
+				run(func(batch []*Message) error {
+					mutex.Lock()
+					defer mutex.Unlock()
+					if failures > 0 {
+						failures--
+						Expect(consumer.offsets.committable()).To(BeEmpty())
+						return fmt.Errorf("failed")
+					}
+					batches = append(batches, offsetsOf(batch))
+					return nil
+				})
+				deliver(0)
+				deliver(1)
+				deliver(2)
+				Eventually(recorded).Should(Equal([][]int64{{0, 1, 2}}))
+				Eventually(consumer.offsets.committable).Should(HaveLen(1))
+				Expect(int64(consumer.offsets.committable()[0].Offset)).To(Equal(int64(3)))
+			})
+
+			It("should not commit any offset while the handler fails", func() {
+				client := consumer.Consumer.(*mocks.ConsumerClientMock)
+				attempts := make(chan bool, 100)
+				run(func(batch []*Message) error {
+					attempts <- true
+					return fmt.Errorf("failed")
+				})
+				deliver(0)
+				deliver(1)
+				deliver(2)
+				Eventually(func() int { return len(attempts) }).Should(BeNumerically(">=", 3))
+				Expect(consumer.commitOffsets()).To(Succeed())
+				Expect(consumer.offsets.committable()).To(BeEmpty())
+				Expect(client.CommittedOffsets).To(BeEmpty())
+			})
+
+			It("should handle the pending messages when stopped", func() {
+				config.Set("extensions.kafkaconsumer.batch.maxWait", 60000)
+				run(record)
+				deliver(0)
+				deliver(1)
+				cancel()
+				Eventually(done).Should(BeClosed())
+				Expect(recorded()).To(Equal([][]int64{{0, 1}}))
+			})
+
+			It("should release the pending messages of a failed last batch without committing them", func() {
+				config.Set("extensions.kafkaconsumer.batch.maxWait", 60000)
+				run(func(batch []*Message) error {
+					return fmt.Errorf("failed")
+				})
+				deliver(0)
+				deliver(1)
+				cancel()
+				Eventually(done).Should(BeClosed())
+				Expect(consumer.offsets.committable()).To(BeEmpty())
+
+				released := make(chan bool)
+				go func() {
+					consumer.PendingMessagesWaitGroup().Wait()
+					close(released)
+				}()
+				Eventually(released).Should(BeClosed())
+			})
+
+			It("should release the pending messages of a batch being retried when stopped", func() {
+				attempts := make(chan bool, 100)
+				run(func(batch []*Message) error {
+					attempts <- true
+					return fmt.Errorf("failed")
+				})
+				deliver(0)
+				deliver(1)
+				deliver(2)
+				Eventually(func() int { return len(attempts) }).Should(BeNumerically(">=", 1))
+				cancel()
+				Eventually(done).Should(BeClosed())
+				Expect(consumer.offsets.committable()).To(BeEmpty())
+
+				released := make(chan bool)
+				go func() {
+					consumer.PendingMessagesWaitGroup().Wait()
+					close(released)
+				}()
+				Eventually(released).Should(BeClosed())
+			})
+		})
+	})
+})
@@ -0,0 +1,235 @@
+/*
+ * Copyright (c) 2016 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package kafka
+
+import (
+	"context"
+
+	"github.com/Shopify/sarama"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/kafka/interfaces"
+	"github.com/topfreegames/extensions/v9/kafka/mocks"
+)
+
+var _ interfaces.KafkaConsumerClient = &mocks.BrokerConsumer{}
+var _ interfaces.KafkaProducerClient = &mocks.BrokerProducer{}
+var _ sarama.SyncProducer = &mocks.BrokerSyncProducer{}
+
+var _ = Describe("In-memory Broker", func() {
+	logger, _ := test.NewNullLogger()
+	var broker *mocks.Broker
+	topic := "com.games.test"
+
+	newConsumer := func(client interfaces.KafkaConsumerClient) *Consumer {
+		config := viper.New()
+		config.Set("extensions.kafkaconsumer.topics", []string{topic})
+		config.Set("extensions.kafkaconsumer.group", "round-trip")
+		config.Set("extensions.kafkaconsumer.manualCommit", true)
+		config.Set("extensions.kafkaconsumer.commitInterval", 10)
+		consumer, err := NewConsumer(config, logger, client)
+		Expect(err).NotTo(HaveOccurred())
+		go consumer.ConsumeLoop()
+		return consumer
+	}
+
+	receive := func(consumer *Consumer) *Message {
+		var m *Message
+		Eventually(*consumer.MessageHandlesChannel()).Should(Receive(&m))
+		return m
+	}
+
+	BeforeEach(func() {
+		broker = mocks.NewBroker(2)
+	})
+
+	Describe("[Unit]", func() {
+		It("should deliver messages from a Producer to a Consumer", func() {
+			producer, err := NewProducer(viper.New(), logger, broker.NewProducer())
+			Expect(err).NotTo(HaveOccurred())
+			consumer := newConsumer(broker.NewConsumer("round-trip"))
+			defer consumer.Cleanup()
+
+			report, err := producer.Send(context.Background(), topic, []byte("key"), []byte("value"), nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Offset).To(Equal(int64(0)))
+
+			m := receive(consumer)
+			Expect(m.Key).To(Equal([]byte("key")))
+			Expect(m.Value).To(Equal([]byte("value")))
+			Expect(m.Partition).To(Equal(report.Partition))
+
+			m.Ack()
+			Eventually(func() kafka.Offset {
+				return broker.CommittedOffset("round-trip", topic, m.Partition)
+			}).Should(Equal(kafka.Offset(1)))
+			Expect(producer.Close()).To(Succeed())
+		})
+
+		It("should not deliver messages of paused partitions until they are resumed", func() {
+			producer, err := NewProducer(viper.New(), logger, broker.NewProducer())
+			Expect(err).NotTo(HaveOccurred())
+			consumer := newConsumer(broker.NewConsumer("round-trip"))
+			defer consumer.Cleanup()
+			Eventually(consumer.readyChan).Should(BeClosed())
+
+			Expect(consumer.Pause()).To(Succeed())
+			_, err = producer.Send(context.Background(), topic, []byte("key"), []byte("value"), nil)
+			Expect(err).NotTo(HaveOccurred())
+			Consistently(*consumer.MessageHandlesChannel()).ShouldNot(Receive())
+
+			Expect(consumer.Resume()).To(Succeed())
+			Expect(receive(consumer).Value).To(Equal([]byte("value")))
+			Expect(producer.Close()).To(Succeed())
+		})
+
+		It("should deliver messages from a SyncProducer with headers", func() {
+			producer, err := NewSyncProducer(viper.New(), logger, sarama.NewConfig(), broker.NewSyncProducer(false))
+			Expect(err).NotTo(HaveOccurred())
+			consumer := newConsumer(broker.NewConsumer("round-trip"))
+			defer consumer.Cleanup()
+
+			_, _, err = producer.ProduceMessage(topic, []byte("key"), []byte("value"), []Header{{Key: "h", Value: []byte("v")}})
+			Expect(err).NotTo(HaveOccurred())
+			m := receive(consumer)
+			Expect(m.Value).To(Equal([]byte("value")))
+			Expect(m.Headers).To(ContainElement(Header{Key: "h", Value: []byte("v")}))
+			Expect(broker.Messages(topic)[0].Headers).To(HaveKeyWithValue("h", []byte("v")))
+		})
+
+		It("should deliver headers from a Producer", func() {
+			producer, err := NewProducer(viper.New(), logger, broker.NewProducer())
+			Expect(err).NotTo(HaveOccurred())
+			consumer := newConsumer(broker.NewConsumer("round-trip"))
+			defer consumer.Cleanup()
+
+			_, err = producer.Send(context.Background(), topic, []byte("key"), []byte("value"), []Header{{Key: "h", Value: []byte("v")}})
+			Expect(err).NotTo(HaveOccurred())
+			value, ok := receive(consumer).Header("h")
+			Expect(ok).To(BeTrue())
+			Expect(value).To(Equal([]byte("v")))
+			Expect(producer.Close()).To(Succeed())
+		})
+
+		It("should continue the producer trace on the consumer", func() {
+			tracer := mocktracer.New()
+			opentracing.SetGlobalTracer(tracer)
+			defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+			producer, err := NewProducer(viper.New(), logger, broker.NewProducer())
+			Expect(err).NotTo(HaveOccurred())
+			consumer := newConsumer(broker.NewConsumer("round-trip"))
+			defer consumer.Cleanup()
+
+			parent := tracer.StartSpan("parent")
+			ctx := opentracing.ContextWithSpan(context.Background(), parent)
+			_, err = producer.Send(ctx, topic, nil, []byte("value"), nil)
+			Expect(err).NotTo(HaveOccurred())
+			produced := tracer.FinishedSpans()
+			Expect(produced).To(HaveLen(1))
+			Expect(produced[0].OperationName).To(Equal("kafka produce " + topic))
+			Expect(produced[0].ParentID).To(Equal(parent.(*mocktracer.MockSpan).SpanContext.SpanID))
+
+			m := receive(consumer)
+			m.Ack()
+			consumed := opentracing.SpanFromContext(m.Context()).(*mocktracer.MockSpan)
+			Expect(consumed.ParentID).To(Equal(produced[0].SpanContext.SpanID))
+			Expect(consumed.SpanContext.TraceID).To(Equal(produced[0].SpanContext.TraceID))
+			Expect(producer.Close()).To(Succeed())
+		})
+
+		It("should resume from the committed offsets", func() {
+			producer := broker.NewSyncProducer(false)
+			for _, value := range []string{"a", "b"} {
+				_, _, err := producer.SendMessage(&sarama.ProducerMessage{Topic: topic, Key: sarama.StringEncoder("key"), Value: sarama.StringEncoder(value)})
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			consumer := newConsumer(broker.NewConsumer("round-trip"))
+			receive(consumer).Ack()
+			Expect(consumer.Cleanup()).To(Succeed())
+
+			consumer = newConsumer(broker.NewConsumer("round-trip"))
+			defer consumer.Cleanup()
+			Expect(receive(consumer).Value).To(Equal([]byte("b")))
+		})
+
+		It("should rebalance partitions among the members of a group", func() {
+			first := broker.NewConsumer("group")
+			Expect(first.SubscribeTopics([]string{topic}, nil)).To(Succeed())
+			var assigned kafka.AssignedPartitions
+			Eventually(first.Events()).Should(Receive(&assigned))
+			Expect(assigned.Partitions).To(HaveLen(2))
+			Expect(first.Assign(assigned.Partitions)).To(Succeed())
+
+			second := broker.NewConsumer("group")
+			Expect(second.SubscribeTopics([]string{topic}, nil)).To(Succeed())
+			var revoked kafka.RevokedPartitions
+			Eventually(first.Events()).Should(Receive(&revoked))
+			Expect(revoked.Partitions).To(HaveLen(2))
+			Eventually(first.Events()).Should(Receive(&assigned))
+			Expect(assigned.Partitions).To(HaveLen(1))
+			Eventually(second.Events()).Should(Receive(&assigned))
+			Expect(assigned.Partitions).To(HaveLen(1))
+
+			Expect(second.Close()).To(Succeed())
+			Eventually(first.Events()).Should(Receive(&revoked))
+			Eventually(first.Events()).Should(Receive(&assigned))
+			Expect(assigned.Partitions).To(HaveLen(2))
+			Expect(first.Close()).To(Succeed())
+		})
+
+		It("should commit consumed offsets in producer transactions", func() {
+			config := viper.New()
+			config.Set("extensions.kafkaproducer.transactionalID", "pipeline")
+			producer, err := NewSyncProducer(config, logger, sarama.NewConfig(), broker.NewSyncProducer(true))
+			Expect(err).NotTo(HaveOccurred())
+			_, _, err = producer.ProduceMessage(topic, nil, []byte("input"), nil)
+			Expect(err).NotTo(HaveOccurred())
+			consumer := newConsumer(broker.NewConsumer("round-trip"))
+			defer consumer.Cleanup()
+
+			m := receive(consumer)
+			err = producer.ConsumeTransformProduce(context.Background(), consumer, m, func(m *Message) ([]*Record, error) {
+				return []*Record{{Topic: "com.games.output", Value: m.Value}}, nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(broker.Messages("com.games.output")).To(HaveLen(1))
+			Expect(broker.CommittedOffset("round-trip", topic, m.Partition)).To(Equal(kafka.Offset(m.Offset + 1)))
+		})
+
+		It("should discard aborted transactions", func() {
+			producer := broker.NewSyncProducer(true)
+			Expect(producer.BeginTxn()).To(Succeed())
+			_, _, err := producer.SendMessage(&sarama.ProducerMessage{Topic: topic, Value: sarama.StringEncoder("value")})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(producer.AbortTxn()).To(Succeed())
+			Expect(broker.Messages(topic)).To(BeEmpty())
+		})
+	})
+})
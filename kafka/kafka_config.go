@@ -0,0 +1,157 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package kafka
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+
+	"github.com/Shopify/sarama"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+// mergeClientConfig merges the security settings and the librdkafka properties configured
+// under prefix into configMap, which holds the properties set by the extension
+func mergeClientConfig(config *viper.Viper, prefix string, configMap kafka.ConfigMap) error {
+	security, err := newSecurityConfig(config, prefix)
+	if err != nil {
+		return err
+	}
+	err = mergeConfigMap(configMap, security.ConfigMap())
+	if err != nil {
+		return err
+	}
+	properties, err := librdkafkaConfig(config, prefix+"librdkafka")
+	if err != nil {
+		return err
+	}
+	err = mergeConfigMap(configMap, properties)
+	if err != nil {
+		return fmt.Errorf("invalid %slibrdkafka: %w", prefix, err)
+	}
+	return nil
+}
+
+// librdkafkaConfig returns the librdkafka properties configured under key, e.g.
+// extensions.kafkaconsumer.librdkafka.fetch.max.bytes, as a flat ConfigMap
+func librdkafkaConfig(config *viper.Viper, key string) (kafka.ConfigMap, error) {
+	configMap := kafka.ConfigMap{}
+	value := config.Get(key)
+	if value == nil {
+		return configMap, nil
+	}
+	properties, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be a map of librdkafka properties", key)
+	}
+	err := flattenProperties(configMap, "", properties)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return configMap, nil
+}
+
+func flattenProperties(configMap kafka.ConfigMap, prefix string, properties map[string]interface{}) error {
+	for name, value := range properties {
+		property := prefix + name
+		switch v := value.(type) {
+		case map[string]interface{}:
+			err := flattenProperties(configMap, property+".", v)
+			if err != nil {
+				return err
+			}
+		case string, bool, int:
+			configMap[property] = v
+		case int32, int64, uint, uint32, uint64:
+			configMap[property] = int(reflect.ValueOf(v).Convert(reflect.TypeOf(0)).Int())
+		case float64:
+			if v != math.Trunc(v) {
+				return fmt.Errorf("property %s must be an integer, got %v", property, v)
+			}
+			configMap[property] = int(v)
+		default:
+			return fmt.Errorf("property %s has unsupported type %T", property, value)
+		}
+	}
+	return nil
+}
+
+// mergeConfigMap sets the properties of extra in configMap, failing if any of them is
+// already set, since those are controlled by the dedicated extension settings
+func mergeConfigMap(configMap, extra kafka.ConfigMap) error {
+	conflicts := []string{}
+	for property := range extra {
+		if _, ok := configMap[property]; ok {
+			conflicts = append(conflicts, property)
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return fmt.Errorf("properties %v are set by the extension and can not be overridden", conflicts)
+	}
+	for property, value := range extra {
+		configMap[property] = value
+	}
+	return nil
+}
+
+// decodeSaramaConfig overrides the fields of kafkaConfig configured under key, e.g.
+// extensions.kafkaproducer.sarama.producer.compression. Durations, compression codecs and
+// kafka versions can be given as strings and unknown fields are rejected
+func decodeSaramaConfig(config *viper.Viper, key string, kafkaConfig *sarama.Config) error {
+	value := config.Get(key)
+	if value == nil {
+		return nil
+	}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			stringToKafkaVersionHookFunc(),
+			mapstructure.TextUnmarshallerHookFunc(),
+		),
+		ErrorUnused:      true,
+		WeaklyTypedInput: true,
+		Result:           kafkaConfig,
+	})
+	if err != nil {
+		return err
+	}
+	err = decoder.Decode(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return nil
+}
+
+func stringToKafkaVersionHookFunc() mapstructure.DecodeHookFuncType {
+	return func(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+		if from.Kind() != reflect.String || to != reflect.TypeOf(sarama.KafkaVersion{}) {
+			return data, nil
+		}
+		return sarama.ParseKafkaVersion(data.(string))
+	}
+}
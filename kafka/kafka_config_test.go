@@ -0,0 +1,136 @@
+/*
+ * Copyright (c) 2016 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package kafka
+
+import (
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/kafka/mocks"
+)
+
+var _ = Describe("Client Configuration", func() {
+	var config *viper.Viper
+	logger, _ := test.NewNullLogger()
+
+	BeforeEach(func() {
+		config = viper.New()
+	})
+
+	Describe("[Unit]", func() {
+		Describe("librdkafka properties", func() {
+			It("should be merged into the consumer configuration", func() {
+				config.Set("extensions.kafkaconsumer.librdkafka.fetch.max.bytes", 1048576)
+				config.Set("extensions.kafkaconsumer.librdkafka.max.poll.interval.ms", "300000")
+				config.Set("extensions.kafkaconsumer.librdkafka.check.crcs", true)
+				consumer, err := NewConsumer(config, logger, mocks.NewConsumerClientMock())
+				Expect(err).NotTo(HaveOccurred())
+
+				configMap, err := consumer.clientConfig("extensions.kafkaconsumer.")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(*configMap).To(HaveKeyWithValue("fetch.max.bytes", 1048576))
+				Expect(*configMap).To(HaveKeyWithValue("max.poll.interval.ms", "300000"))
+				Expect(*configMap).To(HaveKeyWithValue("check.crcs", true))
+				Expect(*configMap).To(HaveKeyWithValue("group.id", "test"))
+			})
+
+			It("should not override the properties set by the extension", func() {
+				config.Set("extensions.kafkaconsumer.librdkafka.enable.auto.commit", false)
+				_, err := NewConsumer(config, logger, mocks.NewConsumerClientMock())
+				Expect(err).To(MatchError(ContainSubstring("enable.auto.commit")))
+			})
+
+			It("should reject values of unsupported types", func() {
+				config.Set("extensions.kafkaproducer.librdkafka.linger.ms", 0.5)
+				_, err := NewProducer(config, logger, mocks.NewProducerClientMock())
+				Expect(err).To(MatchError(ContainSubstring("linger.ms")))
+			})
+
+			It("should flatten nested properties", func() {
+				configMap, err := librdkafkaConfig(viperWith(map[string]interface{}{
+					"librdkafka": map[string]interface{}{
+						"compression": map[string]interface{}{"codec": "lz4"},
+						"linger":      map[string]interface{}{"ms": float64(5)},
+					},
+				}), "librdkafka")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configMap).To(Equal(kafka.ConfigMap{"compression.codec": "lz4", "linger.ms": 5}))
+			})
+		})
+
+		Describe("sarama fields", func() {
+			var mockCtrl *gomock.Controller
+
+			BeforeEach(func() {
+				mockCtrl = gomock.NewController(GinkgoT())
+			})
+
+			AfterEach(func() {
+				mockCtrl.Finish()
+			})
+
+			It("should override the sarama configuration", func() {
+				config.Set("extensions.kafkaproducer.sarama.producer.compression", "gzip")
+				config.Set("extensions.kafkaproducer.sarama.producer.flush.frequency", "50ms")
+				config.Set("extensions.kafkaproducer.sarama.producer.flush.messages", 100)
+				config.Set("extensions.kafkaproducer.sarama.version", "2.1.0")
+				c := sarama.NewConfig()
+				c.ClientID = "service"
+				producer, err := NewSyncProducer(config, logger, c, mocks.NewMockSyncProducer(mockCtrl))
+				Expect(err).NotTo(HaveOccurred())
+				c = producer.kafkaConfig
+				Expect(c.Producer.Compression).To(Equal(sarama.CompressionGZIP))
+				Expect(c.Producer.Flush.Frequency).To(Equal(50 * time.Millisecond))
+				Expect(c.Producer.Flush.Messages).To(Equal(100))
+				Expect(c.Version).To(Equal(sarama.V2_1_0_0))
+				Expect(c.ClientID).To(Equal("service"))
+			})
+
+			It("should reject unknown fields", func() {
+				config.Set("extensions.kafkaproducer.sarama.producer.compresion", "gzip")
+				_, err := NewSyncProducer(config, logger, sarama.NewConfig(), mocks.NewMockSyncProducer(mockCtrl))
+				Expect(err).To(MatchError(ContainSubstring("compresion")))
+			})
+
+			It("should validate the resulting configuration", func() {
+				config.Set("extensions.kafkaproducer.sarama.producer.maxMessageBytes", 0)
+				_, err := NewSyncProducer(config, logger, sarama.NewConfig(), mocks.NewMockSyncProducer(mockCtrl))
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+})
+
+func viperWith(values map[string]interface{}) *viper.Viper {
+	config := viper.New()
+	for key, value := range values {
+		config.Set(key, value)
+	}
+	return config
+}
@@ -23,31 +23,58 @@
 package kafka
 
 import (
+	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 	raven "github.com/getsentry/raven-go"
+	"github.com/opentracing/opentracing-go"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/codec"
 	"github.com/topfreegames/extensions/v9/kafka/interfaces"
+	"github.com/topfreegames/extensions/v9/middleware"
+	tkafka "github.com/topfreegames/extensions/v9/tracing/kafka"
 	"github.com/topfreegames/extensions/v9/util"
 )
 
 // Consumer for getting push requests
 type Consumer struct {
 	Brokers                        string
+	Codec                          codec.Codec
 	Config                         *viper.Viper
 	Consumer                       interfaces.KafkaConsumerClient
 	ConsumerGroup                  string
 	ChannelSize                    int
+	CommitInterval                 int
 	Logger                         *logrus.Logger
+	ManualCommit                   bool
+	RateLimit                      float64
+	RateLimitBurst                 int
+	MessageHandles                 bool
+	MetricsInterval                int
+	MetricsReporter                middleware.MetricsReporter
 	messagesReceived               int64
+	metrics                        *consumerMetrics
+	assignment                     *assignmentState
+	limiter                        *tokenBucket
+	limiterMutex                   sync.Mutex
 	msgChan                        chan []byte
+	handlesChan                    chan *Message
+	offsets                        *offsetTracker
+	pauseChan                      chan *pauseRequest
 	readyChan                      chan bool
+	readyOnce                      sync.Once
+	assignedHooks                  []PartitionsAssignedHook
+	revokedHooks                   []PartitionsRevokedHook
 	OffsetResetStrategy            string
 	run                            bool
+	runMutex                       sync.Mutex
+	stopChan                       chan struct{}
 	SessionTimeout                 int
+	ShutdownTimeout                int
 	Topics                         []string
 	pendingMessagesWG              *sync.WaitGroup
 	HandleAllMessagesBeforeExiting bool
@@ -76,8 +103,12 @@ func NewConsumerWithPrefix(
 		Config:            config,
 		Logger:            logger,
 		messagesReceived:  0,
+		metrics:           newConsumerMetrics(),
+		assignment:        newAssignmentState(),
+		offsets:           newOffsetTracker(),
+		pauseChan:         make(chan *pauseRequest),
 		pendingMessagesWG: nil,
-		readyChan:         make(chan bool, 1),
+		readyChan:         make(chan bool),
 	}
 	var client interfaces.KafkaConsumerClient
 	if len(clientOrNil) == 1 {
@@ -98,6 +129,14 @@ func (q *Consumer) loadConfigurationDefaults(prefix string) {
 	q.Config.SetDefault(prefix+"sessionTimeout", 6000)
 	q.Config.SetDefault(prefix+"offsetResetStrategy", "latest")
 	q.Config.SetDefault(prefix+"handleAllMessagesBeforeExiting", true)
+	q.Config.SetDefault(prefix+"manualCommit", false)
+	q.Config.SetDefault(prefix+"messageHandles", false)
+	q.Config.SetDefault(prefix+"commitInterval", 1000)
+	q.Config.SetDefault(prefix+"shutdownTimeout", 10000)
+	q.Config.SetDefault(prefix+"codec", codec.NameJSON)
+	q.Config.SetDefault(prefix+"metricsInterval", 10000)
+	q.Config.SetDefault(prefix+"rateLimit", 0)
+	q.Config.SetDefault(prefix+"rateLimitBurst", 1)
 }
 
 func (q *Consumer) configure(client interfaces.KafkaConsumerClient, prefix string) error {
@@ -109,22 +148,62 @@ func (q *Consumer) configure(client interfaces.KafkaConsumerClient, prefix strin
 	q.Topics = q.Config.GetStringSlice(prefix + "topics")
 	q.ChannelSize = q.Config.GetInt(prefix + "channelSize")
 	q.HandleAllMessagesBeforeExiting = q.Config.GetBool(prefix + "handleAllMessagesBeforeExiting")
+	q.ManualCommit = q.Config.GetBool(prefix + "manualCommit")
+	q.MessageHandles = q.ManualCommit || q.Config.GetBool(prefix+"messageHandles")
+	q.CommitInterval = q.Config.GetInt(prefix + "commitInterval")
+	q.ShutdownTimeout = q.Config.GetInt(prefix + "shutdownTimeout")
+	q.MetricsInterval = q.Config.GetInt(prefix + "metricsInterval")
+	q.RateLimit = q.Config.GetFloat64(prefix + "rateLimit")
+	q.RateLimitBurst = q.Config.GetInt(prefix + "rateLimitBurst")
+	q.SetRateLimit(q.RateLimit, q.RateLimitBurst)
+	valueCodec, err := codec.New(q.Config.GetString(prefix + "codec"))
+	if err != nil {
+		return err
+	}
+	q.Codec = valueCodec
 
 	q.msgChan = make(chan []byte, q.ChannelSize)
+	q.handlesChan = make(chan *Message, q.ChannelSize)
 
 	if q.HandleAllMessagesBeforeExiting {
 		var wg sync.WaitGroup
 		q.pendingMessagesWG = &wg
 	}
 
-	err := q.configureConsumer(client)
+	configMap, err := q.clientConfig(prefix)
+	if err != nil {
+		return err
+	}
+	err = q.configureConsumer(client, configMap)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (q *Consumer) configureConsumer(client interfaces.KafkaConsumerClient) error {
+// clientConfig returns the librdkafka configuration of the consumer, the properties under
+// librdkafka and the security settings are merged into the ones set by the extension
+func (q *Consumer) clientConfig(prefix string) (*kafka.ConfigMap, error) {
+	configMap := kafka.ConfigMap{
+		"bootstrap.servers":               q.Brokers,
+		"group.id":                        q.ConsumerGroup,
+		"session.timeout.ms":              q.SessionTimeout,
+		"go.events.channel.enable":        true,
+		"go.application.rebalance.enable": true,
+		"enable.auto.commit":              !q.ManualCommit,
+		"default.topic.config": kafka.ConfigMap{
+			"auto.offset.reset":  q.OffsetResetStrategy,
+			"auto.commit.enable": !q.ManualCommit,
+		},
+	}
+	err := mergeClientConfig(q.Config, prefix, configMap)
+	if err != nil {
+		return nil, err
+	}
+	return &configMap, nil
+}
+
+func (q *Consumer) configureConsumer(client interfaces.KafkaConsumerClient, configMap *kafka.ConfigMap) error {
 	l := q.Logger.WithFields(logrus.Fields{
 		"method":                          "configureConsumer",
 		"bootstrap.servers":               q.Brokers,
@@ -132,28 +211,17 @@ func (q *Consumer) configureConsumer(client interfaces.KafkaConsumerClient) erro
 		"session.timeout.ms":              q.SessionTimeout,
 		"go.events.channel.enable":        true,
 		"go.application.rebalance.enable": true,
-		"enable.auto.commit":              true,
+		"enable.auto.commit":              !q.ManualCommit,
 		"default.topic.config": kafka.ConfigMap{
 			"auto.offset.reset":  q.OffsetResetStrategy,
-			"auto.commit.enable": true,
+			"auto.commit.enable": !q.ManualCommit,
 		},
 		"topics": q.Topics,
 	})
 	l.Debug("configuring kafka queue extension")
 
 	if client == nil {
-		c, err := kafka.NewConsumer(&kafka.ConfigMap{
-			"bootstrap.servers":               q.Brokers,
-			"group.id":                        q.ConsumerGroup,
-			"session.timeout.ms":              q.SessionTimeout,
-			"go.events.channel.enable":        true,
-			"go.application.rebalance.enable": true,
-			"enable.auto.commit":              true,
-			"default.topic.config": kafka.ConfigMap{
-				"auto.offset.reset":  q.OffsetResetStrategy,
-				"auto.commit.enable": true,
-			},
-		})
+		c, err := kafka.NewConsumer(configMap)
 		if err != nil {
 			l.WithError(err).Error("error configuring kafka queue")
 			return err
@@ -173,17 +241,58 @@ func (q *Consumer) PendingMessagesWaitGroup() *sync.WaitGroup {
 
 // StopConsuming stops consuming messages from the queue
 func (q *Consumer) StopConsuming() {
+	q.runMutex.Lock()
+	defer q.runMutex.Unlock()
+	if q.run && q.stopChan != nil {
+		close(q.stopChan)
+	}
 	q.run = false
 }
 
+func (q *Consumer) startRunning() chan struct{} {
+	q.runMutex.Lock()
+	defer q.runMutex.Unlock()
+	q.run = true
+	q.stopChan = make(chan struct{})
+	return q.stopChan
+}
+
+// runningStop returns the stop channel of the running consume loop, or nil if it is not running
+func (q *Consumer) runningStop() chan struct{} {
+	q.runMutex.Lock()
+	defer q.runMutex.Unlock()
+	if !q.run {
+		return nil
+	}
+	return q.stopChan
+}
+
+func (q *Consumer) isRunning() bool {
+	q.runMutex.Lock()
+	defer q.runMutex.Unlock()
+	return q.run
+}
+
 // MessagesChannel returns the channel that will receive all messages got from kafka
 func (q *Consumer) MessagesChannel() *chan []byte {
 	return &q.msgChan
 }
 
+// Decode decodes data received from MessagesChannel into v with the consumer Codec
+func (q *Consumer) Decode(data []byte, v interface{}) error {
+	return q.Codec.Unmarshal(data, v)
+}
+
+// MessageHandlesChannel returns the channel that will receive all messages got from kafka,
+// along with their metadata, when MessageHandles or ManualCommit are enabled.
+// Each message must be acknowledged with Message.Ack
+func (q *Consumer) MessageHandlesChannel() *chan *Message {
+	return &q.handlesChan
+}
+
 // ConsumeLoop consume messages from the queue and put in messages to send channel
 func (q *Consumer) ConsumeLoop() error {
-	q.run = true
+	stop := q.startRunning()
 	l := q.Logger.WithFields(logrus.Fields{
 		"method": "ConsumeLoop",
 		"topics": q.Topics,
@@ -192,13 +301,30 @@ func (q *Consumer) ConsumeLoop() error {
 	err := q.Consumer.SubscribeTopics(q.Topics, nil)
 	if err != nil {
 		l.WithError(err).Error("error subscribing to topics")
+		q.StopConsuming()
 		return err
 	}
 
 	l.Info("successfully subscribed to topics")
 
-	for q.run == true {
+	if q.reportingMetrics() {
+		go q.reportMetrics(stop)
+	}
+
+	var commitTicker <-chan time.Time
+	if q.ManualCommit {
+		ticker := time.NewTicker(time.Duration(q.CommitInterval) * time.Millisecond)
+		defer ticker.Stop()
+		commitTicker = ticker.C
+	}
+
+	for q.isRunning() {
 		select {
+		case <-stop:
+		case <-commitTicker:
+			q.commitOffsets()
+		case req := <-q.pauseChan:
+			q.servePause(req)
 		case ev := <-q.Consumer.Events():
 			switch e := ev.(type) {
 			case kafka.AssignedPartitions:
@@ -207,19 +333,22 @@ func (q *Consumer) ConsumeLoop() error {
 					l.WithError(err).Error("error assigning partitions")
 					continue
 				}
-				q.readyChan <- true
+				q.readyOnce.Do(func() {
+					close(q.readyChan)
+				})
 			case kafka.RevokedPartitions:
-				err = q.unassignPartitions()
+				err = q.unassignPartitions(e.Partitions)
 				if err != nil {
 					l.WithError(err).Error("error revoking partitions")
 				}
 			case *kafka.Message:
-				q.receiveMessage(e.TopicPartition, e.Value)
+				q.receiveMessage(e, stop)
 			case kafka.PartitionEOF:
 				q.handlePartitionEOF(ev)
 			case kafka.OffsetsCommitted:
 				q.handleOffsetsCommitted(ev)
 			case kafka.Error:
+				q.reportError("connection")
 				q.handleError(ev)
 				q.StopConsuming()
 				return e
@@ -238,23 +367,53 @@ func (q *Consumer) assignPartitions(partitions []kafka.TopicPartition) error {
 		"partitions": fmt.Sprintf("%v", partitions),
 	})
 
+	partitions, err := q.runPartitionsAssignedHooks(partitions)
+	if err != nil {
+		l.WithError(err).Error("Partitions assigned hook failed.")
+		return err
+	}
+
 	l.Debug("Assigning partitions...")
-	err := q.Consumer.Assign(partitions)
+	paused := q.assignment.assign(partitions)
+	err = q.Consumer.Assign(partitions)
 	if err != nil {
 		l.WithError(err).Error("Failed to assign partitions.")
 		return err
 	}
+	if len(paused) > 0 {
+		err = q.Consumer.Pause(paused)
+		if err != nil {
+			l.WithError(err).Error("Failed to pause assigned partitions.")
+			return err
+		}
+	}
+	q.reportRebalance("assigned")
 	l.Info("Partitions assigned.")
 	return nil
 }
 
-func (q *Consumer) unassignPartitions() error {
+func (q *Consumer) unassignPartitions(partitions []kafka.TopicPartition) error {
 	l := q.Logger.WithFields(logrus.Fields{
-		"method": "unassignPartitions",
+		"method":     "unassignPartitions",
+		"partitions": fmt.Sprintf("%v", partitions),
 	})
 
+	err := q.runPartitionsRevokedHooks(partitions)
+	if err != nil {
+		l.WithError(err).Error("Partitions revoked hook failed.")
+	}
+
+	if q.ManualCommit {
+		q.commitOffsets()
+		q.offsets.reset()
+	}
+
+	q.reportRebalance("revoked")
+	q.metrics.resetPositions()
+	q.assignment.unassign()
+
 	l.Debug("Unassigning partitions...")
-	err := q.Consumer.Unassign()
+	err = q.Consumer.Unassign()
 	if err != nil {
 		l.WithError(err).Error("Failed to unassign partitions.")
 		return err
@@ -263,7 +422,7 @@ func (q *Consumer) unassignPartitions() error {
 	return nil
 }
 
-func (q *Consumer) receiveMessage(topicPartition kafka.TopicPartition, value []byte) {
+func (q *Consumer) receiveMessage(message *kafka.Message, stop chan struct{}) {
 	l := q.Logger.WithFields(logrus.Fields{
 		"method": "receiveMessage",
 	})
@@ -274,15 +433,163 @@ func (q *Consumer) receiveMessage(topicPartition kafka.TopicPartition, value []b
 	if q.messagesReceived%1000 == 0 {
 		l.Infof("messages from kafka: %d", q.messagesReceived)
 	}
-	l.Debugf("message on %s:\n%s\n", topicPartition, string(value))
+	if q.reportingMetrics() && message.TopicPartition.Topic != nil {
+		q.metrics.messageReceived(*message.TopicPartition.Topic, message.TopicPartition.Partition, int64(message.TopicPartition.Offset))
+	}
+	l.Debugf("message on %s:\n%s\n", message.TopicPartition, string(message.Value))
 	if q.pendingMessagesWG != nil {
 		q.pendingMessagesWG.Add(1)
 	}
-	q.msgChan <- value
+	if !q.waitRateLimit(stop) {
+		q.dropMessage(l)
+		return
+	}
+	// without handles nothing acknowledges the message, so its span ends on delivery
+	var handle *Message
+	var span opentracing.Span
+	if q.MessageHandles {
+		handle = q.newMessage(message)
+		span = handle.span
+	} else {
+		span, _ = q.startConsumeSpan(message)
+	}
+	delivered := q.deliver(handle, message.Value, stop)
+	if handle == nil || !delivered {
+		span.Finish()
+	}
+	if !delivered {
+		q.dropMessage(l)
+		return
+	}
 
 	l.Debug("Received message processed.")
 }
 
+// deliver sends a message to the handlers, as a handle when there is one. Pause requests
+// are served while waiting, since the handlers may be the ones pausing the consumer.
+// It returns false if the consumer stopped before the message was delivered
+func (q *Consumer) deliver(handle *Message, value []byte, stop chan struct{}) bool {
+	for {
+		if handle != nil {
+			select {
+			case q.handlesChan <- handle:
+				return true
+			case req := <-q.pauseChan:
+				q.servePause(req)
+			case <-stop:
+				return false
+			}
+		} else {
+			select {
+			case q.msgChan <- value:
+				return true
+			case req := <-q.pauseChan:
+				q.servePause(req)
+			case <-stop:
+				return false
+			}
+		}
+	}
+}
+
+// dropMessage releases a message that could not be delivered because the consumer stopped.
+// With manual commit its offset is never committed, so it is consumed again later
+func (q *Consumer) dropMessage(l *logrus.Entry) {
+	q.release()
+	l.Debug("Consumer stopped before the message was delivered.")
+}
+
+// release stops waiting for a message that will not be acknowledged on shutdown
+func (q *Consumer) release() {
+	if q.pendingMessagesWG != nil {
+		q.pendingMessagesWG.Done()
+	}
+}
+
+// startConsumeSpan starts the span of a consumed message, as a child of the span context
+// propagated in its headers when there is one
+func (q *Consumer) startConsumeSpan(message *kafka.Message) (opentracing.Span, context.Context) {
+	var topic string
+	if message.TopicPartition.Topic != nil {
+		topic = *message.TopicPartition.Topic
+	}
+	parent, err := tkafka.ExtractConfluent(message.Headers)
+	if err != nil {
+		parent = nil
+	}
+	return tkafka.StartConsumeSpan(
+		context.Background(), parent, topic, message.TopicPartition.Partition, int64(message.TopicPartition.Offset),
+	)
+}
+
+func (q *Consumer) newMessage(message *kafka.Message) *Message {
+	var topic string
+	if message.TopicPartition.Topic != nil {
+		topic = *message.TopicPartition.Topic
+	}
+	m := &Message{
+		Topic:         topic,
+		Partition:     message.TopicPartition.Partition,
+		Offset:        int64(message.TopicPartition.Offset),
+		Key:           message.Key,
+		Value:         message.Value,
+		Headers:       fromKafkaHeaders(message.Headers),
+		Timestamp:     message.Timestamp,
+		TimestampType: message.TimestampType,
+		consumer:      q,
+		receivedAt:    time.Now(),
+	}
+	m.span, m.ctx = q.startConsumeSpan(message)
+	if q.ManualCommit {
+		m.partition = q.offsets.track(topic, m.Partition, m.Offset)
+	}
+	return m
+}
+
+func (q *Consumer) ack(m *Message) {
+	q.reportProcessingLatency(m)
+	if m.partition != nil {
+		m.partition.ack(m.Offset)
+	}
+	if q.pendingMessagesWG != nil {
+		q.pendingMessagesWG.Done()
+	}
+}
+
+// ackCommitted acknowledges m like ack, recording committed as already committed
+// for its partition so that it is not committed again by the consumer
+func (q *Consumer) ackCommitted(m *Message, committed int64) {
+	q.reportProcessingLatency(m)
+	if m.partition != nil {
+		m.partition.ackCommitted(m.Offset, committed)
+	}
+	if q.pendingMessagesWG != nil {
+		q.pendingMessagesWG.Done()
+	}
+}
+
+// commitOffsets commits the offsets of every partition whose acknowledged messages advanced
+func (q *Consumer) commitOffsets() error {
+	l := q.Logger.WithFields(logrus.Fields{
+		"method": "commitOffsets",
+	})
+
+	offsets := q.offsets.committable()
+	if len(offsets) == 0 {
+		return nil
+	}
+
+	l.WithField("offsets", fmt.Sprintf("%v", offsets)).Debug("Committing offsets...")
+	_, err := q.Consumer.CommitOffsets(offsets)
+	if err != nil {
+		q.reportError("commit")
+		l.WithError(err).Error("Failed to commit offsets.")
+		return err
+	}
+	q.offsets.markCommitted(offsets)
+	return nil
+}
+
 func (q *Consumer) handlePartitionEOF(ev kafka.Event) {
 	l := q.Logger.WithFields(logrus.Fields{
 		"method":    "handlePartitionEOF",
@@ -321,15 +628,71 @@ func (q *Consumer) handleUnrecognized(ev kafka.Event) {
 	l.Warn("Kafka event not recognized.")
 }
 
-//WaitUntilReady blocks until q.assignPartitions runs succesfully for the first time
+//WaitUntilReady blocks until q.assignPartitions runs succesfully for the first time,
+//returning immediately after that
 func (q *Consumer) WaitUntilReady() {
 	<-q.readyChan
 }
 
+// Run consumes messages until ctx is cancelled or the consume loop fails, then
+// shuts the consumer down gracefully
+func (q *Consumer) Run(ctx context.Context) error {
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- q.ConsumeLoop()
+	}()
+
+	var err error
+	select {
+	case err = <-errChan:
+	case <-ctx.Done():
+		q.StopConsuming()
+		err = <-errChan
+	}
+
+	shutdownErr := q.Shutdown(time.Duration(q.ShutdownTimeout) * time.Millisecond)
+	if err != nil {
+		return err
+	}
+	return shutdownErr
+}
+
+// Shutdown stops consuming, waits up to timeout for the messages tracked by
+// PendingMessagesWaitGroup to be processed, commits the final offsets and closes
+// the connection to kafka
+func (q *Consumer) Shutdown(timeout time.Duration) error {
+	l := q.Logger.WithFields(logrus.Fields{
+		"method": "Shutdown",
+	})
+
+	q.StopConsuming()
+	if q.pendingMessagesWG != nil {
+		l.Info("Waiting for pending messages...")
+		drained := make(chan struct{})
+		go func() {
+			q.pendingMessagesWG.Wait()
+			close(drained)
+		}()
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-drained:
+			l.Info("Pending messages processed.")
+		case <-timer.C:
+			l.Warn("Timed out waiting for pending messages.")
+		}
+	}
+	return q.Cleanup()
+}
+
 //Cleanup closes kafka consumer connection
 func (q *Consumer) Cleanup() error {
-	if q.run {
-		q.StopConsuming()
+	q.StopConsuming()
+	if q.ManualCommit && q.Consumer != nil {
+		err := q.commitOffsets()
+		if err != nil {
+			return err
+		}
 	}
 	if q.Consumer != nil {
 		err := q.Consumer.Close()
@@ -23,15 +23,19 @@
 package kafka
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
 	"github.com/sirupsen/logrus"
 	"github.com/sirupsen/logrus/hooks/test"
 	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/codec"
 	"github.com/topfreegames/extensions/v9/kafka/mocks"
 	. "github.com/topfreegames/extensions/v9/testing"
 	"github.com/topfreegames/extensions/v9/util"
@@ -91,9 +95,9 @@ var _ = Describe("Kafka Extension", func() {
 
 		Describe("Stop consuming", func() {
 			It("should stop consuming", func() {
-				consumer.run = true
+				consumer.startRunning()
 				consumer.StopConsuming()
-				Expect(consumer.run).To(BeFalse())
+				Expect(consumer.isRunning()).To(BeFalse())
 			})
 		})
 
@@ -225,7 +229,7 @@ var _ = Describe("Kafka Extension", func() {
 				event := kafka.Error{}
 				publishEvent(event)
 
-				Eventually(consumer.run, 5).Should(BeFalse())
+				Eventually(consumer.isRunning, 5).Should(BeFalse())
 				Expect(hook.Entries).To(ContainLogMessage("Error in Kafka connection."))
 			})
 
@@ -240,6 +244,306 @@ var _ = Describe("Kafka Extension", func() {
 			})
 		})
 
+		Describe("Tracing", func() {
+			It("should trace messages delivered to the messages channel", func() {
+				tracer := mocktracer.New()
+				opentracing.SetGlobalTracer(tracer)
+				defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+				parent := tracer.StartSpan("produce")
+				carrier := opentracing.TextMapCarrier{}
+				Expect(tracer.Inject(parent.Context(), opentracing.TextMap, carrier)).To(Succeed())
+				headers := []kafka.Header{}
+				for key, value := range carrier {
+					headers = append(headers, kafka.Header{Key: key, Value: []byte(value)})
+				}
+
+				topic := consumer.Topics[0]
+				consumer.receiveMessage(&kafka.Message{
+					TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 1, Offset: 5},
+					Headers:        headers,
+				}, nil)
+				Eventually(*consumer.MessagesChannel()).Should(Receive())
+
+				finished := tracer.FinishedSpans()
+				Expect(finished).To(HaveLen(1))
+				Expect(finished[0].OperationName).To(Equal("kafka consume " + topic))
+				Expect(finished[0].ParentID).To(Equal(parent.(*mocktracer.MockSpan).SpanContext.SpanID))
+				Expect(finished[0].Tag("kafka.offset")).To(BeEquivalentTo(5))
+			})
+		})
+
+		Describe("Message handles", func() {
+			BeforeEach(func() {
+				config := viper.New()
+				config.Set("extensions.kafkaconsumer.topics", []string{"com.games.test"})
+				config.Set("extensions.kafkaconsumer.messageHandles", true)
+
+				var err error
+				consumer, err = NewConsumer(config, logger, kafkaConsumerClientMock)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should deliver message metadata", func() {
+				topic := consumer.Topics[0]
+				timestamp := time.Now()
+				startConsuming()
+				defer consumer.StopConsuming()
+				publishEvent(&kafka.Message{
+					TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 3, Offset: 7},
+					Key:            []byte("key"),
+					Value:          []byte("value"),
+					Timestamp:      timestamp,
+					TimestampType:  kafka.TimestampCreateTime,
+				})
+
+				var msg *Message
+				Eventually(consumer.handlesChan, 5).Should(Receive(&msg))
+				Expect(msg.Key).To(Equal([]byte("key")))
+				Expect(msg.Value).To(Equal([]byte("value")))
+				Expect(msg.Timestamp).To(Equal(timestamp))
+				Expect(msg.TimestampType).To(Equal(kafka.TimestampCreateTime))
+				Expect(msg.TopicPartition()).To(Equal(kafka.TopicPartition{Topic: &topic, Partition: 3, Offset: 7}))
+				Expect(consumer.msgChan).To(BeEmpty())
+			})
+
+			It("should decode message values with the consumer codec", func() {
+				topic := consumer.Topics[0]
+				consumer.receiveMessage(&kafka.Message{
+					TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 3, Offset: 7},
+					Value:          []byte(`{"id":"123"}`),
+				}, nil)
+
+				var msg *Message
+				Eventually(consumer.handlesChan).Should(Receive(&msg))
+				var decoded map[string]string
+				Expect(msg.Decode(&decoded)).To(Succeed())
+				Expect(decoded).To(Equal(map[string]string{"id": "123"}))
+			})
+
+			It("should trace each message until it is acknowledged", func() {
+				tracer := mocktracer.New()
+				opentracing.SetGlobalTracer(tracer)
+				defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+				topic := consumer.Topics[0]
+				consumer.receiveMessage(&kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 3, Offset: 7}}, nil)
+				msg := <-consumer.handlesChan
+				Expect(opentracing.SpanFromContext(msg.Context())).NotTo(BeNil())
+				Expect(tracer.FinishedSpans()).To(BeEmpty())
+
+				msg.Ack()
+				finished := tracer.FinishedSpans()
+				Expect(finished).To(HaveLen(1))
+				Expect(finished[0].OperationName).To(Equal("kafka consume " + topic))
+				Expect(finished[0].Tag("kafka.partition")).To(BeEquivalentTo(3))
+				Expect(finished[0].Tag("kafka.offset")).To(BeEquivalentTo(7))
+			})
+
+			It("should keep auto commit enabled", func() {
+				Expect(consumer.ManualCommit).To(BeFalse())
+				Expect(consumer.MessageHandles).To(BeTrue())
+			})
+
+			It("should release the pending messages wait group on ack", func() {
+				topic := consumer.Topics[0]
+				consumer.receiveMessage(&kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic}}, nil)
+				(<-consumer.handlesChan).Ack()
+
+				done := make(chan bool)
+				go func() {
+					consumer.PendingMessagesWaitGroup().Wait()
+					close(done)
+				}()
+				Eventually(done).Should(BeClosed())
+				Expect(consumer.offsets.committable()).To(BeEmpty())
+			})
+		})
+
+		Describe("Manual commit", func() {
+			var topic string
+
+			BeforeEach(func() {
+				config := viper.New()
+				config.Set("extensions.kafkaconsumer.topics", []string{"com.games.test"})
+				config.Set("extensions.kafkaconsumer.manualCommit", true)
+				config.Set("extensions.kafkaconsumer.commitInterval", 10)
+
+				var err error
+				consumer, err = NewConsumer(config, logger, kafkaConsumerClientMock)
+				Expect(err).NotTo(HaveOccurred())
+				topic = consumer.Topics[0]
+			})
+
+			It("should deliver message handles", func() {
+				startConsuming()
+				defer consumer.StopConsuming()
+				part := kafka.TopicPartition{Topic: &topic, Partition: 1, Offset: 10}
+				publishEvent(&kafka.Message{TopicPartition: part, Value: []byte("test")})
+
+				var msg *Message
+				Eventually(consumer.handlesChan, 5).Should(Receive(&msg))
+				Expect(msg.Topic).To(Equal(topic))
+				Expect(msg.Partition).To(BeEquivalentTo(1))
+				Expect(msg.Offset).To(BeEquivalentTo(10))
+				Expect(msg.Value).To(Equal([]byte("test")))
+				Expect(consumer.msgChan).To(BeEmpty())
+			})
+
+			It("should not commit messages that were not acknowledged", func() {
+				startConsuming()
+				defer consumer.StopConsuming()
+				part := kafka.TopicPartition{Topic: &topic, Partition: 1, Offset: 10}
+				publishEvent(&kafka.Message{TopicPartition: part, Value: []byte("test")})
+
+				Eventually(consumer.handlesChan, 5).Should(Receive())
+				Consistently(func() []kafka.TopicPartition {
+					return kafkaConsumerClientMock.CommittedOffsets
+				}, 0.05).Should(BeEmpty())
+			})
+
+			It("should commit the offset after the message is acknowledged", func() {
+				startConsuming()
+				defer consumer.StopConsuming()
+				part := kafka.TopicPartition{Topic: &topic, Partition: 1, Offset: 10}
+				publishEvent(&kafka.Message{TopicPartition: part, Value: []byte("test")})
+
+				var msg *Message
+				Eventually(consumer.handlesChan, 5).Should(Receive(&msg))
+				msg.Ack()
+				Eventually(func() []kafka.TopicPartition {
+					return kafkaConsumerClientMock.CommittedOffsets
+				}, 5).Should(ContainElement(kafka.TopicPartition{Topic: &topic, Partition: 1, Offset: 11}))
+			})
+
+			It("should not skip unacknowledged messages when acks arrive out of order", func() {
+				for i := 0; i < 3; i++ {
+					consumer.receiveMessage(&kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 1, Offset: kafka.Offset(i)}, Value: []byte("test")}, nil)
+				}
+				msgs := []*Message{<-consumer.handlesChan, <-consumer.handlesChan, <-consumer.handlesChan}
+
+				msgs[2].Ack()
+				msgs[1].Ack()
+				Expect(consumer.commitOffsets()).To(Succeed())
+				Expect(kafkaConsumerClientMock.CommittedOffsets).To(BeEmpty())
+
+				msgs[0].Ack()
+				Expect(consumer.commitOffsets()).To(Succeed())
+				Expect(kafkaConsumerClientMock.CommittedOffsets).To(Equal([]kafka.TopicPartition{
+					{Topic: &topic, Partition: 1, Offset: 3},
+				}))
+			})
+
+			It("should release the pending messages wait group on ack", func() {
+				consumer.receiveMessage(&kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 1, Offset: 0}, Value: []byte("test")}, nil)
+				msg := <-consumer.handlesChan
+				msg.Ack()
+				msg.Ack()
+
+				done := make(chan bool)
+				go func() {
+					consumer.PendingMessagesWaitGroup().Wait()
+					close(done)
+				}()
+				Eventually(done).Should(BeClosed())
+			})
+
+			It("should commit acknowledged offsets before revoking partitions", func() {
+				consumer.receiveMessage(&kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 1, Offset: 0}, Value: []byte("test")}, nil)
+				(<-consumer.handlesChan).Ack()
+
+				Expect(consumer.unassignPartitions(nil)).To(Succeed())
+				Expect(kafkaConsumerClientMock.CommittedOffsets).To(Equal([]kafka.TopicPartition{
+					{Topic: &topic, Partition: 1, Offset: 1},
+				}))
+				Expect(consumer.offsets.committable()).To(BeEmpty())
+			})
+
+			It("should commit acknowledged offsets upon cleanup", func() {
+				consumer.receiveMessage(&kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 1, Offset: 0}, Value: []byte("test")}, nil)
+				(<-consumer.handlesChan).Ack()
+
+				Expect(consumer.Cleanup()).To(Succeed())
+				Expect(kafkaConsumerClientMock.CommittedOffsets).To(HaveLen(1))
+				Expect(kafkaConsumerClientMock.Closed).To(BeTrue())
+			})
+		})
+
+		Describe("Rebalance hooks", func() {
+			var topic string
+			var part kafka.TopicPartition
+
+			BeforeEach(func() {
+				topic = consumer.Topics[0]
+				part = kafka.TopicPartition{Topic: &topic, Partition: 1}
+			})
+
+			It("should assign the partitions returned by the assigned hooks", func() {
+				stored := kafka.TopicPartition{Topic: &topic, Partition: 1, Offset: 42}
+				received := []kafka.TopicPartition{}
+				consumer.AddPartitionsAssignedHook(func(partitions []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+					received = partitions
+					return []kafka.TopicPartition{stored}, nil
+				})
+				consumer.AddPartitionsAssignedHook(func(partitions []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+					Expect(partitions).To(Equal([]kafka.TopicPartition{stored}))
+					return nil, nil
+				})
+
+				Expect(consumer.assignPartitions([]kafka.TopicPartition{part})).To(Succeed())
+				Expect(received).To(Equal([]kafka.TopicPartition{part}))
+				Expect(kafkaConsumerClientMock.AssignedPartitions).To(Equal([]kafka.TopicPartition{stored}))
+			})
+
+			It("should not assign partitions if an assigned hook fails", func() {
+				consumer.AddPartitionsAssignedHook(func(partitions []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+					return nil, fmt.Errorf("offset store unavailable")
+				})
+
+				err := consumer.assignPartitions([]kafka.TopicPartition{part})
+				Expect(err).To(MatchError("offset store unavailable"))
+				Expect(kafkaConsumerClientMock.AssignedPartitions).To(BeEmpty())
+			})
+
+			It("should run revoked hooks before unassigning partitions", func() {
+				kafkaConsumerClientMock.AssignedPartitions = []kafka.TopicPartition{part}
+				var revoked []kafka.TopicPartition
+				consumer.AddPartitionsRevokedHook(func(partitions []kafka.TopicPartition) error {
+					Expect(kafkaConsumerClientMock.AssignedPartitions).NotTo(BeEmpty())
+					revoked = partitions
+					return nil
+				})
+
+				startConsuming()
+				defer consumer.StopConsuming()
+				publishEvent(kafka.RevokedPartitions{Partitions: []kafka.TopicPartition{part}})
+				Eventually(func() []kafka.TopicPartition { return revoked }, 5).Should(Equal([]kafka.TopicPartition{part}))
+				Eventually(func() []kafka.TopicPartition { return kafkaConsumerClientMock.AssignedPartitions }, 5).Should(BeEmpty())
+			})
+
+			It("should unassign partitions even if a revoked hook fails", func() {
+				kafkaConsumerClientMock.AssignedPartitions = []kafka.TopicPartition{part}
+				consumer.AddPartitionsRevokedHook(func(partitions []kafka.TopicPartition) error {
+					return fmt.Errorf("could not flush")
+				})
+
+				Expect(consumer.unassignPartitions([]kafka.TopicPartition{part})).To(Succeed())
+				Expect(kafkaConsumerClientMock.AssignedPartitions).To(BeEmpty())
+				Expect(hook.Entries).To(ContainLogMessage("Partitions revoked hook failed."))
+			})
+
+			It("should keep consuming after several assignments", func() {
+				startConsuming()
+				defer consumer.StopConsuming()
+				for i := 0; i < 3; i++ {
+					publishEvent(kafka.AssignedPartitions{Partitions: []kafka.TopicPartition{part}})
+				}
+				publishEvent(&kafka.Message{TopicPartition: part, Value: []byte("test")})
+				Eventually(consumer.msgChan, 5).Should(Receive())
+				consumer.WaitUntilReady()
+			})
+		})
+
 		Describe("Configuration Defaults", func() {
 			It("should configure defaults", func() {
 				cnf := viper.New()
@@ -253,6 +557,19 @@ var _ = Describe("Kafka Extension", func() {
 				Expect(cons.Config.GetInt("extensions.kafkaconsumer.sessionTimeout")).To(Equal(6000))
 				Expect(cons.Config.GetString("extensions.kafkaconsumer.offsetResetStrategy")).To(Equal("latest"))
 				Expect(cons.Config.GetBool("extensions.kafkaconsumer.handleAllMessagesBeforeExiting")).To(BeTrue())
+				Expect(cons.Config.GetBool("extensions.kafkaconsumer.manualCommit")).To(BeFalse())
+				Expect(cons.Config.GetBool("extensions.kafkaconsumer.messageHandles")).To(BeFalse())
+				Expect(cons.Config.GetInt("extensions.kafkaconsumer.commitInterval")).To(Equal(1000))
+				Expect(cons.Config.GetInt("extensions.kafkaconsumer.shutdownTimeout")).To(Equal(10000))
+				Expect(cons.Config.GetString("extensions.kafkaconsumer.codec")).To(Equal("json"))
+				Expect(cons.Codec).To(Equal(codec.JSON{}))
+			})
+
+			It("should fail with an unknown codec", func() {
+				cnf := viper.New()
+				cnf.Set("extensions.kafkaconsumer.codec", "xml")
+				_, err := NewConsumer(cnf, logger, kafkaConsumerClientMock)
+				Expect(err).To(HaveOccurred())
 			})
 
 			It("should read a config with prefix", func() {
@@ -314,12 +631,86 @@ var _ = Describe("Kafka Extension", func() {
 			})
 		})
 
+		Describe("Run", func() {
+			It("should stop promptly when the context is cancelled", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				errChan := make(chan error)
+				go func() {
+					errChan <- consumer.Run(ctx)
+				}()
+				Eventually(consumer.isRunning).Should(BeTrue())
+
+				cancel()
+				Eventually(errChan, 1).Should(Receive(BeNil()))
+				Expect(consumer.isRunning()).To(BeFalse())
+				Expect(kafkaConsumerClientMock.Closed).To(BeTrue())
+			})
+
+			It("should return the error if subscribing fails", func() {
+				kafkaConsumerClientMock.Error = fmt.Errorf("could not subscribe")
+				err := consumer.Run(context.Background())
+				Expect(err).To(MatchError("could not subscribe"))
+			})
+
+			It("should not block shutdown on messages that were not delivered", func() {
+				consumer.msgChan = make(chan []byte)
+				ctx, cancel := context.WithCancel(context.Background())
+				errChan := make(chan error)
+				go func() {
+					errChan <- consumer.Run(ctx)
+				}()
+				topic := consumer.Topics[0]
+				publishEvent(&kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic}, Value: []byte("test")})
+
+				cancel()
+				Eventually(errChan, 1).Should(Receive(BeNil()))
+			})
+		})
+
+		Describe("Shutdown", func() {
+			var topic string
+
+			BeforeEach(func() {
+				config := viper.New()
+				config.Set("extensions.kafkaconsumer.manualCommit", true)
+
+				var err error
+				consumer, err = NewConsumer(config, logger, kafkaConsumerClientMock)
+				Expect(err).NotTo(HaveOccurred())
+				topic = consumer.Topics[0]
+			})
+
+			It("should wait for pending messages and commit their offsets", func() {
+				consumer.receiveMessage(&kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 1, Offset: 5}}, nil)
+				msg := <-consumer.handlesChan
+				go func() {
+					time.Sleep(20 * time.Millisecond)
+					msg.Ack()
+				}()
+
+				Expect(consumer.Shutdown(time.Second)).To(Succeed())
+				Expect(kafkaConsumerClientMock.CommittedOffsets).To(Equal([]kafka.TopicPartition{
+					{Topic: &topic, Partition: 1, Offset: 6},
+				}))
+				Expect(kafkaConsumerClientMock.Closed).To(BeTrue())
+			})
+
+			It("should give up waiting for pending messages after the timeout", func() {
+				consumer.receiveMessage(&kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 1, Offset: 5}}, nil)
+
+				Expect(consumer.Shutdown(10 * time.Millisecond)).To(Succeed())
+				Expect(kafkaConsumerClientMock.CommittedOffsets).To(BeEmpty())
+				Expect(kafkaConsumerClientMock.Closed).To(BeTrue())
+				Expect(hook.Entries).To(ContainLogMessage("Timed out waiting for pending messages."))
+			})
+		})
+
 		Describe("Cleanup", func() {
 			It("should stop running upon cleanup", func() {
-				consumer.run = true
+				consumer.startRunning()
 				err := consumer.Cleanup()
 				Expect(err).NotTo(HaveOccurred())
-				Expect(consumer.run).To(BeFalse())
+				Expect(consumer.isRunning()).To(BeFalse())
 			})
 
 			It("should close connection to kafka upon cleanup", func() {
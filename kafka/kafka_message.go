@@ -0,0 +1,154 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package kafka
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/opentracing/opentracing-go"
+)
+
+// Header is a kafka record header
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+func toKafkaHeaders(headers []Header) []kafka.Header {
+	if len(headers) == 0 {
+		return nil
+	}
+	kafkaHeaders := make([]kafka.Header, 0, len(headers))
+	for _, header := range headers {
+		kafkaHeaders = append(kafkaHeaders, kafka.Header{Key: header.Key, Value: header.Value})
+	}
+	return kafkaHeaders
+}
+
+func fromKafkaHeaders(kafkaHeaders []kafka.Header) []Header {
+	if len(kafkaHeaders) == 0 {
+		return nil
+	}
+	headers := make([]Header, 0, len(kafkaHeaders))
+	for _, header := range kafkaHeaders {
+		headers = append(headers, Header{Key: header.Key, Value: header.Value})
+	}
+	return headers
+}
+
+// Message is a message consumed from kafka that can be acknowledged once processed
+type Message struct {
+	Topic         string
+	Partition     int32
+	Offset        int64
+	Key           []byte
+	Value         []byte
+	Headers       []Header
+	Timestamp     time.Time
+	TimestampType kafka.TimestampType
+
+	ctx        context.Context
+	span       opentracing.Span
+	consumer   *Consumer
+	partition  *partitionOffsets
+	receivedAt time.Time
+	ackOnce    sync.Once
+}
+
+// Context returns a context carrying the span started when the message was consumed,
+// use it to trace the work done while processing the message
+func (m *Message) Context() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
+}
+
+// Decode decodes the message value into v with the Codec of the consumer
+func (m *Message) Decode(v interface{}) error {
+	return m.consumer.Decode(m.Value, v)
+}
+
+// Header returns the value of the last header with key, and whether it was found
+func (m *Message) Header(key string) ([]byte, bool) {
+	for i := len(m.Headers) - 1; i >= 0; i-- {
+		if m.Headers[i].Key == key {
+			return m.Headers[i].Value, true
+		}
+	}
+	return nil, false
+}
+
+// TopicPartition returns the topic, partition and offset of the message
+func (m *Message) TopicPartition() kafka.TopicPartition {
+	topic := m.Topic
+	return kafka.TopicPartition{
+		Topic:     &topic,
+		Partition: m.Partition,
+		Offset:    kafka.Offset(m.Offset),
+	}
+}
+
+// Ack marks the message as processed and finishes its consume span. When manual
+// commit is enabled its offset is only committed after every previous message of the
+// same partition is acknowledged. Calling Ack more than once has no effect
+func (m *Message) Ack() {
+	m.ackOnce.Do(func() {
+		if m.span != nil {
+			m.span.Finish()
+		}
+		if m.consumer != nil {
+			m.consumer.ack(m)
+		}
+	})
+}
+
+// release finishes the consume span of a message that was not processed, without
+// acknowledging it. Its offset is never committed, so with manual commit it is consumed
+// again after the next rebalance or restart. Calling Ack afterwards has no effect
+func (m *Message) release() {
+	m.ackOnce.Do(func() {
+		if m.span != nil {
+			m.span.Finish()
+		}
+		if m.consumer != nil {
+			m.consumer.release()
+		}
+	})
+}
+
+// ackCommitted acknowledges the message like Ack, when its partition offsets up to
+// committed were committed outside of the consumer
+func (m *Message) ackCommitted(committed int64) {
+	m.ackOnce.Do(func() {
+		if m.span != nil {
+			m.span.Finish()
+		}
+		if m.consumer != nil {
+			m.consumer.ackCommitted(m, committed)
+		}
+	})
+}
@@ -0,0 +1,254 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package kafka
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/sirupsen/logrus"
+)
+
+// ConsumerMetricTypes are the metrics reported by the Consumer through its MetricsReporter
+var ConsumerMetricTypes = struct {
+	Lag               string
+	MessagesPerSecond string
+	ProcessingLatency string
+	Rebalances        string
+	Errors            string
+}{
+	Lag:               "kafka_consumer_lag",
+	MessagesPerSecond: "kafka_consumer_messages_per_second",
+	ProcessingLatency: "kafka_consumer_processing_latency",
+	Rebalances:        "kafka_consumer_rebalances",
+	Errors:            "kafka_consumer_errors",
+}
+
+// watermarkOffsetsQuerier is implemented by clients able to query the offsets of a partition,
+// such as the confluent consumer, and is needed to report the consumer lag
+type watermarkOffsetsQuerier interface {
+	QueryWatermarkOffsets(topic string, partition int32, timeoutMs int) (int64, int64, error)
+}
+
+// committedOffsetsQuerier is implemented by clients able to query the offsets committed by
+// the consumer group, such as the confluent consumer, and is used to report the lag of
+// partitions no message was received from
+type committedOffsetsQuerier interface {
+	Committed(partitions []kafka.TopicPartition, timeoutMs int) ([]kafka.TopicPartition, error)
+}
+
+// consumerMetrics keeps the state needed to report throughput and lag
+type consumerMetrics struct {
+	mutex      sync.Mutex
+	received   int64
+	lastReport time.Time
+	positions  map[topicPartitionKey]int64
+}
+
+func newConsumerMetrics() *consumerMetrics {
+	return &consumerMetrics{
+		lastReport: time.Now(),
+		positions:  map[topicPartitionKey]int64{},
+	}
+}
+
+// messageReceived records the next offset to be consumed from a partition
+func (m *consumerMetrics) messageReceived(topic string, partition int32, offset int64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.received++
+	m.positions[topicPartitionKey{topic: topic, partition: partition}] = offset + 1
+}
+
+func (m *consumerMetrics) resetPositions() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.positions = map[topicPartitionKey]int64{}
+}
+
+// throughput returns the messages received per second since the last call
+func (m *consumerMetrics) throughput() float64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := time.Now()
+	elapsed := now.Sub(m.lastReport).Seconds()
+	received := m.received
+	m.received = 0
+	m.lastReport = now
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(received) / elapsed
+}
+
+func (m *consumerMetrics) currentPositions() map[topicPartitionKey]int64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	positions := make(map[topicPartitionKey]int64, len(m.positions))
+	for key, position := range m.positions {
+		positions[key] = position
+	}
+	return positions
+}
+
+func (q *Consumer) reportingMetrics() bool {
+	return q.MetricsReporter != nil
+}
+
+func (q *Consumer) metricTags(tags ...string) []string {
+	return append([]string{fmt.Sprintf("group:%s", q.ConsumerGroup)}, tags...)
+}
+
+func (q *Consumer) reportRebalance(kind string) {
+	if !q.reportingMetrics() {
+		return
+	}
+	q.MetricsReporter.Increment(ConsumerMetricTypes.Rebalances, q.metricTags("type:"+kind)...)
+}
+
+func (q *Consumer) reportError(kind string) {
+	if !q.reportingMetrics() {
+		return
+	}
+	q.MetricsReporter.Increment(ConsumerMetricTypes.Errors, q.metricTags("type:"+kind)...)
+}
+
+func (q *Consumer) reportProcessingLatency(m *Message) {
+	if !q.reportingMetrics() || m.receivedAt.IsZero() {
+		return
+	}
+	q.MetricsReporter.Timing(
+		ConsumerMetricTypes.ProcessingLatency,
+		time.Since(m.receivedAt),
+		q.metricTags(fmt.Sprintf("topic:%s", m.Topic))...,
+	)
+}
+
+// reportMetrics periodically reports the consumer throughput and the lag of every
+// assigned partition, until stop is closed
+func (q *Consumer) reportMetrics(stop chan struct{}) {
+	ticker := time.NewTicker(time.Duration(q.MetricsInterval) * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			q.reportThroughput()
+			q.reportLag()
+		}
+	}
+}
+
+func (q *Consumer) reportThroughput() {
+	q.MetricsReporter.Gauge(ConsumerMetricTypes.MessagesPerSecond, q.metrics.throughput(), q.metricTags()...)
+}
+
+// reportLag reports the lag of every assigned partition, measured from the position after
+// the last received message, or from the committed offset of the consumer group when no
+// message was received from the partition yet
+func (q *Consumer) reportLag() {
+	querier, ok := q.Consumer.(watermarkOffsetsQuerier)
+	if !ok {
+		return
+	}
+	positions := q.metrics.currentPositions()
+	committed := q.committedPositions(positions)
+	for key := range committed {
+		if _, ok := positions[key]; !ok {
+			positions[key] = committed[key]
+		}
+	}
+	for key, position := range positions {
+		l := q.Logger.WithFields(logrus.Fields{
+			"method":    "reportLag",
+			"topic":     key.topic,
+			"partition": key.partition,
+		})
+		low, high, err := querier.QueryWatermarkOffsets(key.topic, key.partition, q.MetricsInterval)
+		if err != nil {
+			l.WithError(err).Warn("error querying watermark offsets")
+			q.reportError("watermarks")
+			continue
+		}
+		if position < 0 {
+			position = q.resetPosition(low, high)
+		}
+		lag := high - position
+		if lag < 0 {
+			lag = 0
+		}
+		q.MetricsReporter.Gauge(
+			ConsumerMetricTypes.Lag,
+			float64(lag),
+			q.metricTags(fmt.Sprintf("topic:%s", key.topic), fmt.Sprintf("partition:%d", key.partition))...,
+		)
+	}
+}
+
+// committedPositions returns the committed offsets of the assigned partitions missing from
+// positions, a negative offset means there is no committed offset for the partition
+func (q *Consumer) committedPositions(positions map[topicPartitionKey]int64) map[topicPartitionKey]int64 {
+	missing := []kafka.TopicPartition{}
+	for _, tp := range q.assignment.assignedPartitions() {
+		if _, ok := positions[partitionKey(tp)]; !ok {
+			missing = append(missing, kafka.TopicPartition{Topic: tp.Topic, Partition: tp.Partition})
+		}
+	}
+	committed := make(map[topicPartitionKey]int64, len(missing))
+	if len(missing) == 0 {
+		return committed
+	}
+	for _, tp := range missing {
+		committed[partitionKey(tp)] = int64(kafka.OffsetInvalid)
+	}
+	querier, ok := q.Consumer.(committedOffsetsQuerier)
+	if !ok {
+		return committed
+	}
+	offsets, err := querier.Committed(missing, q.MetricsInterval)
+	if err != nil {
+		q.Logger.WithFields(logrus.Fields{
+			"method": "committedPositions",
+		}).WithError(err).Warn("error querying committed offsets")
+		q.reportError("committed")
+		return committed
+	}
+	for _, tp := range offsets {
+		committed[partitionKey(tp)] = int64(tp.Offset)
+	}
+	return committed
+}
+
+// resetPosition returns the offset the consumer starts from in a partition without a
+// committed offset, according to the offset reset strategy
+func (q *Consumer) resetPosition(low, high int64) int64 {
+	switch q.OffsetResetStrategy {
+	case "earliest", "smallest", "beginning":
+		return low
+	default:
+		return high
+	}
+}
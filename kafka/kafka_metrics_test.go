@@ -0,0 +1,168 @@
+/*
+ * Copyright (c) 2016 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package kafka
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/kafka/mocks"
+	mmocks "github.com/topfreegames/extensions/v9/middleware/mocks"
+)
+
+var _ = Describe("Consumer Metrics", func() {
+	logger, _ := test.NewNullLogger()
+	var mockCtrl *gomock.Controller
+	var reporter *mmocks.MockMetricsReporter
+	var client *mocks.ConsumerClientMock
+	var consumer *Consumer
+	topic := "com.games.test"
+
+	deliver := func(partition int32, offset int64) {
+		consumer.receiveMessage(&kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: partition, Offset: kafka.Offset(offset)},
+		}, nil)
+	}
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		reporter = mmocks.NewMockMetricsReporter(mockCtrl)
+		client = mocks.NewConsumerClientMock()
+		config := viper.New()
+		config.Set("extensions.kafkaconsumer.messageHandles", true)
+		config.Set("extensions.kafkaconsumer.group", "metrics")
+		var err error
+		consumer, err = NewConsumer(config, logger, client)
+		Expect(err).NotTo(HaveOccurred())
+		consumer.MetricsReporter = reporter
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Describe("[Unit]", func() {
+		It("should configure the metrics interval", func() {
+			Expect(consumer.MetricsInterval).To(Equal(10000))
+		})
+
+		It("should report the lag of each partition", func() {
+			deliver(0, 10)
+			deliver(1, 4)
+			client.HighWatermarks[topic] = map[int32]int64{0: 21, 1: 5}
+			reporter.EXPECT().Gauge(ConsumerMetricTypes.Lag, float64(10), "group:metrics", "topic:"+topic, "partition:0")
+			reporter.EXPECT().Gauge(ConsumerMetricTypes.Lag, float64(0), "group:metrics", "topic:"+topic, "partition:1")
+			consumer.reportLag()
+		})
+
+		It("should report the lag of assigned partitions no message was received from", func() {
+			reporter.EXPECT().Increment(ConsumerMetricTypes.Rebalances, "group:metrics", "type:assigned")
+			Expect(consumer.assignPartitions([]kafka.TopicPartition{
+				{Topic: &topic, Partition: 0},
+				{Topic: &topic, Partition: 1},
+				{Topic: &topic, Partition: 2},
+			})).To(Succeed())
+			deliver(0, 10)
+			client.CommittedOffsets = []kafka.TopicPartition{{Topic: &topic, Partition: 1, Offset: 3}}
+			client.HighWatermarks[topic] = map[int32]int64{0: 21, 1: 8, 2: 6}
+			reporter.EXPECT().Gauge(ConsumerMetricTypes.Lag, float64(10), "group:metrics", "topic:"+topic, "partition:0")
+			reporter.EXPECT().Gauge(ConsumerMetricTypes.Lag, float64(5), "group:metrics", "topic:"+topic, "partition:1")
+			reporter.EXPECT().Gauge(ConsumerMetricTypes.Lag, float64(0), "group:metrics", "topic:"+topic, "partition:2")
+			consumer.reportLag()
+		})
+
+		It("should measure the lag from the low watermark with the earliest reset strategy", func() {
+			consumer.OffsetResetStrategy = "earliest"
+			reporter.EXPECT().Increment(ConsumerMetricTypes.Rebalances, "group:metrics", "type:assigned")
+			Expect(consumer.assignPartitions([]kafka.TopicPartition{{Topic: &topic, Partition: 0}})).To(Succeed())
+			client.HighWatermarks[topic] = map[int32]int64{0: 6}
+			reporter.EXPECT().Gauge(ConsumerMetricTypes.Lag, float64(6), "group:metrics", "topic:"+topic, "partition:0")
+			consumer.reportLag()
+		})
+
+		It("should stop reporting the lag of revoked partitions", func() {
+			deliver(0, 10)
+			reporter.EXPECT().Increment(ConsumerMetricTypes.Rebalances, "group:metrics", "type:revoked")
+			Expect(consumer.unassignPartitions(nil)).To(Succeed())
+			consumer.reportLag()
+		})
+
+		It("should report errors querying the lag", func() {
+			deliver(0, 10)
+			client.Error = fmt.Errorf("broker unavailable")
+			reporter.EXPECT().Increment(ConsumerMetricTypes.Errors, "group:metrics", "type:watermarks")
+			consumer.reportLag()
+		})
+
+		It("should report the throughput", func() {
+			deliver(0, 1)
+			deliver(0, 2)
+			reporter.EXPECT().Gauge(ConsumerMetricTypes.MessagesPerSecond, gomock.Any(), "group:metrics").Do(
+				func(metric string, value float64, tags ...string) {
+					Expect(value).To(BeNumerically(">", 0))
+				})
+			consumer.reportThroughput()
+			reporter.EXPECT().Gauge(ConsumerMetricTypes.MessagesPerSecond, float64(0), "group:metrics")
+			consumer.reportThroughput()
+		})
+
+		It("should report the processing latency when messages are acknowledged", func() {
+			deliver(0, 1)
+			var msg *Message
+			Eventually(consumer.handlesChan).Should(Receive(&msg))
+			time.Sleep(time.Millisecond)
+			reporter.EXPECT().Timing(ConsumerMetricTypes.ProcessingLatency, gomock.Any(), "group:metrics", "topic:"+topic).Do(
+				func(metric string, value time.Duration, tags ...string) {
+					Expect(value).To(BeNumerically(">=", time.Millisecond))
+				})
+			msg.Ack()
+		})
+
+		It("should report rebalances", func() {
+			reporter.EXPECT().Increment(ConsumerMetricTypes.Rebalances, "group:metrics", "type:assigned")
+			Expect(consumer.assignPartitions([]kafka.TopicPartition{{Topic: &topic, Partition: 0}})).To(Succeed())
+		})
+
+		It("should report commit errors", func() {
+			consumer.ManualCommit = true
+			consumer.offsets.track(topic, 0, 1).ack(1)
+			client.Error = fmt.Errorf("commit failed")
+			reporter.EXPECT().Increment(ConsumerMetricTypes.Errors, "group:metrics", "type:commit")
+			Expect(consumer.commitOffsets()).NotTo(Succeed())
+		})
+
+		It("should report from the consume loop", func() {
+			consumer.MetricsInterval = 10
+			reporter.EXPECT().Gauge(ConsumerMetricTypes.MessagesPerSecond, gomock.Any(), gomock.Any()).MinTimes(1)
+			go consumer.ConsumeLoop()
+			defer consumer.StopConsuming()
+			time.Sleep(50 * time.Millisecond)
+		})
+	})
+})
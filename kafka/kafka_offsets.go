@@ -0,0 +1,161 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package kafka
+
+import (
+	"sync"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+type topicPartitionKey struct {
+	topic     string
+	partition int32
+}
+
+// partitionOffsets keeps the offsets delivered for a single partition in the
+// order they were received, so that the committed offset only advances past
+// messages that were all acknowledged
+type partitionOffsets struct {
+	tracker   *offsetTracker
+	pending   []int64
+	acked     map[int64]bool
+	next      int64
+	committed int64
+}
+
+// offsetTracker tracks delivered and acknowledged offsets for every assigned partition
+type offsetTracker struct {
+	mutex      sync.Mutex
+	partitions map[topicPartitionKey]*partitionOffsets
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{
+		partitions: map[topicPartitionKey]*partitionOffsets{},
+	}
+}
+
+// track registers a delivered offset and returns the partition it belongs to
+func (t *offsetTracker) track(topic string, partition int32, offset int64) *partitionOffsets {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	key := topicPartitionKey{topic: topic, partition: partition}
+	p, ok := t.partitions[key]
+	if !ok {
+		p = &partitionOffsets{
+			tracker:   t,
+			acked:     map[int64]bool{},
+			next:      -1,
+			committed: -1,
+		}
+		t.partitions[key] = p
+	}
+	p.pending = append(p.pending, offset)
+	return p
+}
+
+// ack marks offset as processed and advances the next offset to be committed
+// over every contiguous acknowledged offset
+func (p *partitionOffsets) ack(offset int64) {
+	p.tracker.mutex.Lock()
+	defer p.tracker.mutex.Unlock()
+	p.ackLocked(offset)
+}
+
+// ackCommitted marks offset as processed like ack and records committed as
+// already committed, for offsets committed outside of the consumer such as in
+// a producer transaction
+func (p *partitionOffsets) ackCommitted(offset, committed int64) {
+	p.tracker.mutex.Lock()
+	defer p.tracker.mutex.Unlock()
+	p.ackLocked(offset)
+	if committed > p.committed {
+		p.committed = committed
+	}
+}
+
+// nextIfAcked returns the next offset to be committed if offset was acknowledged,
+// and whether it advances past the committed offset
+func (p *partitionOffsets) nextIfAcked(offset int64) (int64, bool) {
+	p.tracker.mutex.Lock()
+	defer p.tracker.mutex.Unlock()
+	next := p.next
+	for _, pending := range p.pending {
+		if pending != offset && !p.acked[pending] {
+			break
+		}
+		next = pending + 1
+	}
+	return next, next > p.committed
+}
+
+func (p *partitionOffsets) ackLocked(offset int64) {
+	p.acked[offset] = true
+	for len(p.pending) > 0 && p.acked[p.pending[0]] {
+		delete(p.acked, p.pending[0])
+		p.next = p.pending[0] + 1
+		p.pending = p.pending[1:]
+	}
+}
+
+// committable returns the offsets that advanced since the last successful commit
+func (t *offsetTracker) committable() []kafka.TopicPartition {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	offsets := []kafka.TopicPartition{}
+	for key, p := range t.partitions {
+		if p.next > p.committed {
+			topic := key.topic
+			offsets = append(offsets, kafka.TopicPartition{
+				Topic:     &topic,
+				Partition: key.partition,
+				Offset:    kafka.Offset(p.next),
+			})
+		}
+	}
+	return offsets
+}
+
+// markCommitted records offsets as successfully committed
+func (t *offsetTracker) markCommitted(offsets []kafka.TopicPartition) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, tp := range offsets {
+		if tp.Topic == nil {
+			continue
+		}
+		p, ok := t.partitions[topicPartitionKey{topic: *tp.Topic, partition: tp.Partition}]
+		if ok && int64(tp.Offset) > p.committed {
+			p.committed = int64(tp.Offset)
+		}
+	}
+}
+
+// reset forgets every tracked partition, acknowledgements of messages
+// delivered before the reset are ignored
+func (t *offsetTracker) reset() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.partitions = map[topicPartitionKey]*partitionOffsets{}
+}
@@ -0,0 +1,204 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package kafka
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/sirupsen/logrus"
+)
+
+// assignmentState tracks the assigned partitions and which of them are paused, so that
+// pauses are applied again to the partitions of later assignments
+type assignmentState struct {
+	mutex    sync.Mutex
+	assigned []kafka.TopicPartition
+	paused   map[topicPartitionKey]bool
+	pauseAll bool
+}
+
+func newAssignmentState() *assignmentState {
+	return &assignmentState{
+		paused: map[topicPartitionKey]bool{},
+	}
+}
+
+func partitionKey(tp kafka.TopicPartition) topicPartitionKey {
+	var topic string
+	if tp.Topic != nil {
+		topic = *tp.Topic
+	}
+	return topicPartitionKey{topic: topic, partition: tp.Partition}
+}
+
+// assign records a new assignment and returns the assigned partitions that must be paused
+func (s *assignmentState) assign(partitions []kafka.TopicPartition) []kafka.TopicPartition {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.assigned = partitions
+	if s.pauseAll {
+		for _, tp := range partitions {
+			s.paused[partitionKey(tp)] = true
+		}
+	}
+	return s.pausedLocked()
+}
+
+func (s *assignmentState) unassign() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.assigned = nil
+}
+
+// setPaused pauses or resumes partitions, or every partition when none is given, and
+// returns the assigned partitions among them. Partitions that are not assigned are
+// paused once they are
+func (s *assignmentState) setPaused(paused bool, partitions []kafka.TopicPartition) []kafka.TopicPartition {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(partitions) == 0 {
+		s.pauseAll = paused
+		partitions = s.assigned
+		if !paused {
+			s.paused = map[topicPartitionKey]bool{}
+		}
+	} else if !paused {
+		s.pauseAll = false
+	}
+	changed := map[topicPartitionKey]bool{}
+	for _, tp := range partitions {
+		key := partitionKey(tp)
+		changed[key] = true
+		if paused {
+			s.paused[key] = true
+		} else {
+			delete(s.paused, key)
+		}
+	}
+	assigned := []kafka.TopicPartition{}
+	for _, tp := range s.assigned {
+		if changed[partitionKey(tp)] {
+			assigned = append(assigned, tp)
+		}
+	}
+	return assigned
+}
+
+func (s *assignmentState) pausedLocked() []kafka.TopicPartition {
+	paused := []kafka.TopicPartition{}
+	for _, tp := range s.assigned {
+		if s.paused[partitionKey(tp)] {
+			paused = append(paused, tp)
+		}
+	}
+	return paused
+}
+
+// assignedPartitions returns every assigned partition, paused or not
+func (s *assignmentState) assignedPartitions() []kafka.TopicPartition {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]kafka.TopicPartition{}, s.assigned...)
+}
+
+func (s *assignmentState) pausedPartitions() []kafka.TopicPartition {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.pausedLocked()
+}
+
+// Pause stops fetching messages from partitions, or from every assigned partition when
+// none is given, without leaving the consumer group. Pausing every partition also pauses
+// the partitions assigned by later rebalances. Messages that were already fetched before
+// the pause are still delivered, fetching continues after them once resumed.
+// While ConsumeLoop runs the partitions are paused by it, between rebalances
+func (q *Consumer) Pause(partitions ...kafka.TopicPartition) error {
+	return q.setPaused(true, partitions)
+}
+
+// Resume resumes fetching messages from paused partitions, or from every partition when
+// none is given
+func (q *Consumer) Resume(partitions ...kafka.TopicPartition) error {
+	return q.setPaused(false, partitions)
+}
+
+// Paused returns the assigned partitions that are paused
+func (q *Consumer) Paused() []kafka.TopicPartition {
+	return q.assignment.pausedPartitions()
+}
+
+// pauseRequest asks the consume loop to pause or resume partitions, so that the
+// assignment is only changed by the goroutine that also handles rebalances
+type pauseRequest struct {
+	paused     bool
+	partitions []kafka.TopicPartition
+	done       chan error
+}
+
+// setPaused hands the request to the consume loop and waits for it to be applied. When
+// the loop is not running there are no concurrent rebalances and it is applied directly
+func (q *Consumer) setPaused(paused bool, partitions []kafka.TopicPartition) error {
+	req := &pauseRequest{paused: paused, partitions: partitions, done: make(chan error, 1)}
+	stop := q.runningStop()
+	if stop == nil {
+		return q.applyPause(req)
+	}
+	select {
+	case q.pauseChan <- req:
+		return <-req.done
+	case <-stop:
+		return q.applyPause(req)
+	}
+}
+
+// servePause applies a request received by the consume loop
+func (q *Consumer) servePause(req *pauseRequest) {
+	req.done <- q.applyPause(req)
+}
+
+func (q *Consumer) applyPause(req *pauseRequest) error {
+	l := q.Logger.WithFields(logrus.Fields{
+		"method":     "setPaused",
+		"paused":     req.paused,
+		"partitions": fmt.Sprintf("%v", req.partitions),
+	})
+
+	assigned := q.assignment.setPaused(req.paused, req.partitions)
+	if len(assigned) == 0 {
+		return nil
+	}
+	var err error
+	if req.paused {
+		err = q.Consumer.Pause(assigned)
+	} else {
+		err = q.Consumer.Resume(assigned)
+	}
+	if err != nil {
+		l.WithError(err).Error("Failed to pause or resume partitions.")
+		return err
+	}
+	l.Info("Partitions paused or resumed.")
+	return nil
+}
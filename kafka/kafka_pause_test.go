@@ -0,0 +1,144 @@
+/*
+ * Copyright (c) 2016 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package kafka
+
+import (
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/kafka/mocks"
+)
+
+var _ = Describe("Consumer Pause", func() {
+	logger, _ := test.NewNullLogger()
+	var client *mocks.ConsumerClientMock
+	var consumer *Consumer
+	topic := "com.games.test"
+
+	partition := func(p int32, offset int64) kafka.TopicPartition {
+		return kafka.TopicPartition{Topic: &topic, Partition: p, Offset: kafka.Offset(offset)}
+	}
+
+	deliver := func(p int32, offset int64) {
+		consumer.receiveMessage(&kafka.Message{TopicPartition: partition(p, offset)}, nil)
+	}
+
+	BeforeEach(func() {
+		client = mocks.NewConsumerClientMock()
+		config := viper.New()
+		config.Set("extensions.kafkaconsumer.messageHandles", true)
+		var err error
+		consumer, err = NewConsumer(config, logger, client)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(consumer.assignPartitions([]kafka.TopicPartition{
+			partition(0, int64(kafka.OffsetStored)),
+			partition(1, int64(kafka.OffsetStored)),
+		})).To(Succeed())
+	})
+
+	Describe("[Unit]", func() {
+		It("should pause every partition", func() {
+			Expect(consumer.Pause()).To(Succeed())
+			Expect(client.PausedPartitions).To(HaveLen(2))
+			Expect(client.AssignedPartitions).To(HaveLen(2))
+			Expect(consumer.Paused()).To(HaveLen(2))
+		})
+
+		It("should pause and resume specific partitions without reassigning", func() {
+			assigned := client.AssignedPartitions
+			Expect(consumer.Pause(partition(1, 0))).To(Succeed())
+			Expect(client.PausedPartitions).To(Equal([]kafka.TopicPartition{partition(1, int64(kafka.OffsetStored))}))
+			Expect(consumer.Paused()).To(Equal([]kafka.TopicPartition{partition(1, int64(kafka.OffsetStored))}))
+
+			Expect(consumer.Resume(partition(1, 0))).To(Succeed())
+			Expect(client.PausedPartitions).To(BeEmpty())
+			Expect(consumer.Paused()).To(BeEmpty())
+			Expect(client.AssignedPartitions).To(Equal(assigned))
+		})
+
+		It("should deliver messages fetched before the pause", func() {
+			Expect(consumer.Pause(partition(0, 0))).To(Succeed())
+			deliver(0, 1)
+			deliver(1, 1)
+			Expect(consumer.handlesChan).To(HaveLen(2))
+		})
+
+		It("should only pause partitions once they are assigned", func() {
+			Expect(consumer.Pause(partition(2, 0))).To(Succeed())
+			Expect(client.PausedPartitions).To(BeEmpty())
+			Expect(consumer.assignPartitions([]kafka.TopicPartition{partition(2, int64(kafka.OffsetStored))})).To(Succeed())
+			Expect(client.PausedPartitions).To(Equal([]kafka.TopicPartition{partition(2, int64(kafka.OffsetStored))}))
+		})
+
+		It("should pause from the consume loop after a revoke in progress", func() {
+			revoking := make(chan bool)
+			release := make(chan bool)
+			consumer.AddPartitionsRevokedHook(func(partitions []kafka.TopicPartition) error {
+				close(revoking)
+				<-release
+				return nil
+			})
+			go consumer.ConsumeLoop()
+			defer consumer.StopConsuming()
+			client.EventsChan <- kafka.RevokedPartitions{Partitions: []kafka.TopicPartition{
+				partition(0, int64(kafka.OffsetStored)),
+				partition(1, int64(kafka.OffsetStored)),
+			}}
+			Eventually(revoking).Should(BeClosed())
+
+			paused := make(chan error, 1)
+			go func() {
+				paused <- consumer.Pause(partition(1, 0))
+			}()
+			Consistently(paused).ShouldNot(Receive())
+
+			close(release)
+			Eventually(paused).Should(Receive(BeNil()))
+			Expect(client.PausedPartitions).To(BeEmpty())
+			Expect(consumer.Paused()).To(BeEmpty())
+		})
+
+		It("should serve pause requests while waiting for the handlers", func() {
+			consumer.ChannelSize = 0
+			consumer.handlesChan = make(chan *Message)
+			go consumer.ConsumeLoop()
+			defer consumer.StopConsuming()
+			client.EventsChan <- &kafka.Message{TopicPartition: partition(0, 1)}
+			Expect(consumer.Pause(partition(1, 0))).To(Succeed())
+			Expect(client.PausedPartitions).To(Equal([]kafka.TopicPartition{partition(1, int64(kafka.OffsetStored))}))
+			Eventually(consumer.handlesChan).Should(Receive())
+		})
+
+		It("should keep new assignments paused when every partition is paused", func() {
+			Expect(consumer.Pause()).To(Succeed())
+			Expect(consumer.unassignPartitions(nil)).To(Succeed())
+			Expect(consumer.assignPartitions([]kafka.TopicPartition{partition(2, int64(kafka.OffsetStored))})).To(Succeed())
+			Expect(client.PausedPartitions).To(Equal([]kafka.TopicPartition{partition(2, int64(kafka.OffsetStored))}))
+
+			Expect(consumer.Resume()).To(Succeed())
+			Expect(client.PausedPartitions).To(BeEmpty())
+		})
+	})
+})
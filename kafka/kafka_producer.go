@@ -23,20 +23,48 @@
 package kafka
 
 import (
+	"context"
+	"fmt"
+	"time"
+
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 	raven "github.com/getsentry/raven-go"
+	"github.com/opentracing/opentracing-go"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/codec"
 	"github.com/topfreegames/extensions/v9/kafka/interfaces"
+	"github.com/topfreegames/extensions/v9/tracing"
+	tkafka "github.com/topfreegames/extensions/v9/tracing/kafka"
 	"github.com/topfreegames/extensions/v9/util"
 )
 
+// DeliveryReport is the outcome of producing a message
+type DeliveryReport struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Error     error
+}
+
+// DeliveryCallback is called with the delivery report of a message
+type DeliveryCallback func(*DeliveryReport)
+
+// delivery is the opaque of produced messages, its span is finished with the delivery report
+type delivery struct {
+	span     opentracing.Span
+	callback DeliveryCallback
+}
+
 // Producer for producing push feedbacks to a kafka queue
 type Producer struct {
-	Brokers  string
-	Config   *viper.Viper
-	Producer interfaces.KafkaProducerClient
-	Logger   *log.Logger
+	Brokers      string
+	Codec        codec.Codec
+	Config       *viper.Viper
+	FlushTimeout time.Duration
+	Producer     interfaces.KafkaProducerClient
+	Logger       *log.Logger
+	eventsDone   chan struct{}
 }
 
 // NewProducer for creating a new Producer instance
@@ -72,14 +100,26 @@ func NewProducerWithPrefix(
 
 func (q *Producer) loadConfigurationDefaults(prefix string) {
 	q.Config.SetDefault(prefix+"brokers", "localhost:9941")
+	q.Config.SetDefault(prefix+"flushTimeout", 10000)
+	q.Config.SetDefault(prefix+"codec", codec.NameJSON)
 }
 
 func (q *Producer) configure(producer interfaces.KafkaProducerClient, prefix string) error {
 	q.loadConfigurationDefaults(prefix)
 	q.Brokers = q.Config.GetString(prefix + "brokers")
+	q.FlushTimeout = time.Duration(q.Config.GetInt(prefix+"flushTimeout")) * time.Millisecond
+	valueCodec, err := codec.New(q.Config.GetString(prefix + "codec"))
+	if err != nil {
+		return err
+	}
+	q.Codec = valueCodec
 	c := &kafka.ConfigMap{
 		"bootstrap.servers": q.Brokers,
 	}
+	err = mergeClientConfig(q.Config, prefix, *c)
+	if err != nil {
+		return err
+	}
 	l := q.Logger.WithFields(log.Fields{
 		"brokers": q.Brokers,
 	})
@@ -95,12 +135,14 @@ func (q *Producer) configure(producer interfaces.KafkaProducerClient, prefix str
 	} else {
 		q.Producer = producer
 	}
+	q.eventsDone = make(chan struct{})
 	go q.listenForKafkaResponses()
 	l.Info("kafka producer initialized")
 	return nil
 }
 
 func (q *Producer) listenForKafkaResponses() {
+	defer close(q.eventsDone)
 	l := q.Logger.WithFields(log.Fields{
 		"method": "listenForKafkaResponses",
 	})
@@ -108,6 +150,9 @@ func (q *Producer) listenForKafkaResponses() {
 		switch ev := e.(type) {
 		case *kafka.Message:
 			m := ev
+			if d, ok := m.Opaque.(*delivery); ok {
+				d.finish(m)
+			}
 			if m.TopicPartition.Error != nil {
 				raven.CaptureError(m.TopicPartition.Error, map[string]string{
 					"version":   util.Version,
@@ -128,14 +173,141 @@ func (q *Producer) listenForKafkaResponses() {
 	}
 }
 
+// Flush waits up to timeout for the messages being produced to be delivered and
+// returns the number of messages still undelivered
+func (q *Producer) Flush(timeout time.Duration) int {
+	return q.Producer.Flush(int(timeout / time.Millisecond))
+}
+
+// Close flushes the pending messages for up to FlushTimeout, closes the kafka client and waits
+// for the remaining delivery reports to be handled. The Producer must not be used after Close.
+// An error is returned if some messages could not be delivered before closing
+func (q *Producer) Close() error {
+	l := q.Logger.WithFields(log.Fields{
+		"method": "Close",
+	})
+	remaining := q.Flush(q.FlushTimeout)
+	q.Producer.Close()
+	<-q.eventsDone
+	if remaining > 0 {
+		l.WithField("remaining", remaining).Warn("kafka producer closed with undelivered messages")
+		return fmt.Errorf("kafka producer closed with %d undelivered messages", remaining)
+	}
+	l.Info("kafka producer closed")
+	return nil
+}
+
 // SendAsync sends the message to a topic of kafka Queue
 func (q *Producer) SendAsync(message []byte, topic string) {
+	q.Producer.ProduceChannel() <- q.newMessage(context.Background(), topic, nil, message, nil, nil)
+}
+
+// Send produces a message with key to topic and waits for its delivery report. An error is
+// returned if the message was not delivered or ctx is done before the report is received
+func (q *Producer) Send(
+	ctx context.Context,
+	topic string,
+	key, value []byte,
+	headers []Header,
+) (*DeliveryReport, error) {
+	reports := make(chan *DeliveryReport, 1)
+	err := q.SendWithCallback(ctx, topic, key, value, headers, func(report *DeliveryReport) {
+		reports <- report
+	})
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case report := <-reports:
+		return report, report.Error
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// SendValue encodes value with the producer Codec and sends it like Send
+func (q *Producer) SendValue(
+	ctx context.Context,
+	topic string,
+	key []byte,
+	value interface{},
+	headers []Header,
+) (*DeliveryReport, error) {
+	data, err := q.Codec.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return q.Send(ctx, topic, key, data, headers)
+}
+
+// SendWithCallback produces a message with key to topic without waiting for its delivery,
+// callback is called with the delivery report from the goroutine listening to kafka events
+// and must not block. An error is returned if the message could not be enqueued
+func (q *Producer) SendWithCallback(
+	ctx context.Context,
+	topic string,
+	key, value []byte,
+	headers []Header,
+	callback DeliveryCallback,
+) error {
+	m := q.newMessage(ctx, topic, key, value, headers, callback)
+	select {
+	case q.Producer.ProduceChannel() <- m:
+		return nil
+	case <-ctx.Done():
+		m.Opaque.(*delivery).span.Finish()
+		return ctx.Err()
+	}
+}
+
+// newMessage creates a message with a produce span, child of the span active in ctx, whose
+// context is injected into the message headers and which is finished with the delivery report
+func (q *Producer) newMessage(
+	ctx context.Context,
+	topic string,
+	key, value []byte,
+	headers []Header,
+	callback DeliveryCallback,
+) *kafka.Message {
+	span, _ := tkafka.StartProduceSpan(ctx, topic)
 	m := &kafka.Message{
 		TopicPartition: kafka.TopicPartition{
 			Topic:     &topic,
 			Partition: kafka.PartitionAny,
 		},
-		Value: message,
+		Key:     key,
+		Value:   value,
+		Headers: toKafkaHeaders(headers),
+		Opaque:  &delivery{span: span, callback: callback},
+	}
+	err := tkafka.InjectConfluent(span, m)
+	if err != nil {
+		q.Logger.WithError(err).Warn("error injecting span context into message headers")
+	}
+	return m
+}
+
+func (d *delivery) finish(m *kafka.Message) {
+	if err := m.TopicPartition.Error; err != nil {
+		tracing.LogError(d.span, err.Error())
+	} else {
+		d.span.SetTag("kafka.partition", m.TopicPartition.Partition)
+		d.span.SetTag("kafka.offset", int64(m.TopicPartition.Offset))
+	}
+	d.span.Finish()
+	if d.callback != nil {
+		d.callback(newDeliveryReport(m))
+	}
+}
+
+func newDeliveryReport(m *kafka.Message) *DeliveryReport {
+	report := &DeliveryReport{
+		Partition: m.TopicPartition.Partition,
+		Offset:    int64(m.TopicPartition.Offset),
+		Error:     m.TopicPartition.Error,
+	}
+	if m.TopicPartition.Topic != nil {
+		report.Topic = *m.TopicPartition.Topic
 	}
-	q.Producer.ProduceChannel() <- m
+	return report
 }
@@ -23,14 +23,23 @@
 package kafka
 
 import (
+	"context"
+	"fmt"
+	"time"
+
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
 	"github.com/sirupsen/logrus"
 	"github.com/sirupsen/logrus/hooks/test"
 	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/codec"
 	"github.com/topfreegames/extensions/v9/kafka/mocks"
+	tkafka "github.com/topfreegames/extensions/v9/tracing/kafka"
 	"github.com/topfreegames/extensions/v9/util"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 var _ = Describe("Producer Extension", func() {
@@ -78,6 +87,153 @@ var _ = Describe("Producer Extension", func() {
 		})
 	})
 
+	Describe("[Unit] Delivery Reports", func() {
+		var failingProducer *mocks.ProducerClientMock
+
+		BeforeEach(func() {
+			failingProducer = mocks.NewProducerClientMock()
+			go func() {
+				for msg := range failingProducer.ProduceChan {
+					msg.TopicPartition.Error = fmt.Errorf("broker unavailable")
+					failingProducer.EventsChan <- msg
+				}
+			}()
+		})
+
+		It("should return the delivery report", func() {
+			producer, err := NewProducer(config, logger, mockProducer)
+			Expect(err).NotTo(HaveOccurred())
+			report, err := producer.Send(context.Background(), "test-topic", []byte("key"), []byte("value"), nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Topic).To(Equal("test-topic"))
+			Expect(report.Partition).To(Equal(kafka.PartitionAny))
+		})
+
+		It("should finish the produce span with the delivery report", func() {
+			tracer := mocktracer.New()
+			opentracing.SetGlobalTracer(tracer)
+			defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+			producer, err := NewProducer(config, logger, failingProducer)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = producer.Send(context.Background(), "test-topic", nil, []byte("value"), nil)
+			Expect(err).To(HaveOccurred())
+			finished := tracer.FinishedSpans()
+			Expect(finished).To(HaveLen(1))
+			Expect(finished[0].OperationName).To(Equal("kafka produce test-topic"))
+			Expect(finished[0].Tag("error")).To(Equal(true))
+		})
+
+		It("should inject the span context in messages sent asynchronously", func() {
+			tracer := mocktracer.New()
+			opentracing.SetGlobalTracer(tracer)
+			defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+			client := mocks.NewProducerClientMock()
+			producer, err := NewProducer(config, logger, client)
+			Expect(err).NotTo(HaveOccurred())
+			go producer.SendAsync([]byte("value"), "test-topic")
+			var sent *kafka.Message
+			Eventually(client.ProduceChan).Should(Receive(&sent))
+			spanContext, err := tkafka.ExtractConfluent(sent.Headers)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spanContext.(mocktracer.MockSpanContext).SpanID).NotTo(BeZero())
+		})
+
+		It("should send the message key", func() {
+			client := mocks.NewProducerClientMock()
+			producer, err := NewProducer(config, logger, client)
+			Expect(err).NotTo(HaveOccurred())
+			go producer.SendWithCallback(context.Background(), "test-topic", []byte("key"), []byte("value"), nil, nil)
+			var sent *kafka.Message
+			Eventually(client.ProduceChan).Should(Receive(&sent))
+			Expect(sent.Key).To(Equal([]byte("key")))
+			Expect(sent.Value).To(Equal([]byte("value")))
+			Expect(sent.TopicPartition.Partition).To(Equal(kafka.PartitionAny))
+		})
+
+		It("should return delivery failures", func() {
+			producer, err := NewProducer(config, logger, failingProducer)
+			Expect(err).NotTo(HaveOccurred())
+			report, err := producer.Send(context.Background(), "test-topic", nil, []byte("value"), nil)
+			Expect(err).To(MatchError("broker unavailable"))
+			Expect(report.Error).To(Equal(err))
+		})
+
+		It("should call the delivery callback", func() {
+			producer, err := NewProducer(config, logger, failingProducer)
+			Expect(err).NotTo(HaveOccurred())
+			reports := make(chan *DeliveryReport, 1)
+			err = producer.SendWithCallback(context.Background(), "test-topic", nil, []byte("value"), nil, func(report *DeliveryReport) {
+				reports <- report
+			})
+			Expect(err).NotTo(HaveOccurred())
+			var report *DeliveryReport
+			Eventually(reports).Should(Receive(&report))
+			Expect(report.Topic).To(Equal("test-topic"))
+			Expect(report.Error).To(MatchError("broker unavailable"))
+		})
+
+		It("should return if the context is done before the delivery report", func() {
+			producer, err := NewProducer(config, logger, mocks.NewProducerClientMock())
+			Expect(err).NotTo(HaveOccurred())
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err = producer.Send(ctx, "test-topic", nil, []byte("value"), nil)
+			Expect(err).To(Equal(context.Canceled))
+		})
+
+		It("should encode values with the producer codec", func() {
+			config.Set("extensions.kafkaproducer.codec", "protobuf")
+			client := mocks.NewProducerClientMock()
+			producer, err := NewProducer(config, logger, client)
+			Expect(err).NotTo(HaveOccurred())
+			go producer.SendValue(context.Background(), "test-topic", nil, wrapperspb.String("value"), nil)
+			var sent *kafka.Message
+			Eventually(client.ProduceChan).Should(Receive(&sent))
+			decoded := &wrapperspb.StringValue{}
+			Expect(codec.Protobuf{}.Unmarshal(sent.Value, decoded)).To(Succeed())
+			Expect(decoded.GetValue()).To(Equal("value"))
+		})
+
+		It("should send headers", func() {
+			client := mocks.NewProducerClientMock()
+			producer, err := NewProducer(config, logger, client)
+			Expect(err).NotTo(HaveOccurred())
+			go producer.Send(context.Background(), "test-topic", []byte("key"), []byte("value"), []Header{{Key: "k", Value: []byte("v")}})
+			var sent *kafka.Message
+			Eventually(client.ProduceChan).Should(Receive(&sent))
+			Expect(sent.Key).To(Equal([]byte("key")))
+			Expect(sent.Headers).To(Equal([]kafka.Header{{Key: "k", Value: []byte("v")}}))
+		})
+	})
+
+	Describe("[Unit] Close", func() {
+		It("should flush and close the client", func() {
+			producer, err := NewProducer(config, logger, mockProducer)
+			Expect(err).NotTo(HaveOccurred())
+			producer.SendAsync([]byte("test message"), "test-topic")
+			Expect(producer.Flush(time.Second)).To(Equal(0))
+			Expect(producer.Close()).To(Succeed())
+			Expect(mockProducer.Closed).To(BeTrue())
+			Expect(mockProducer.SentMessages).To(Equal(1))
+		})
+
+		It("should stop listening to kafka events", func() {
+			producer, err := NewProducer(config, logger, mockProducer)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(producer.Close()).To(Succeed())
+			Expect(producer.eventsDone).To(BeClosed())
+		})
+
+		It("should configure the flush timeout", func() {
+			config.Set("extensions.kafkaproducer.flushTimeout", 500)
+			producer, err := NewProducer(config, logger, mockProducer)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(producer.FlushTimeout).To(Equal(500 * time.Millisecond))
+		})
+	})
+
 	Describe("Configuration Defaults", func() {
 		It("should configure defaults", func() {
 			cnf := viper.New()
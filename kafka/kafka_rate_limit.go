@@ -0,0 +1,106 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package kafka
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenBucket is a token bucket rate limiter holding up to burst tokens,
+// refilled at rate tokens per second
+type tokenBucket struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// reserve takes a token and returns how long to wait before it can be used
+func (b *tokenBucket) reserve() time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// SetRateLimit limits the messages delivered by the consumer to messagesPerSecond, allowing
+// bursts of up to burst messages. A messagesPerSecond of 0 removes the limit
+func (q *Consumer) SetRateLimit(messagesPerSecond float64, burst int) {
+	q.limiterMutex.Lock()
+	defer q.limiterMutex.Unlock()
+	if messagesPerSecond <= 0 {
+		q.limiter = nil
+		return
+	}
+	q.limiter = newTokenBucket(messagesPerSecond, burst)
+}
+
+// waitRateLimit blocks until the rate limit allows delivering a message, returning
+// false if stop is closed first. Pause requests are served while waiting
+func (q *Consumer) waitRateLimit(stop chan struct{}) bool {
+	q.limiterMutex.Lock()
+	limiter := q.limiter
+	q.limiterMutex.Unlock()
+	if limiter == nil {
+		return true
+	}
+	wait := limiter.reserve()
+	if wait <= 0 {
+		return true
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			return true
+		case req := <-q.pauseChan:
+			q.servePause(req)
+		case <-stop:
+			return false
+		}
+	}
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright (c) 2016 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package kafka
+
+import (
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/kafka/mocks"
+)
+
+var _ = Describe("Consumer Rate Limit", func() {
+	logger, _ := test.NewNullLogger()
+	var config *viper.Viper
+	topic := "com.games.test"
+
+	newConsumer := func() *Consumer {
+		consumer, err := NewConsumer(config, logger, mocks.NewConsumerClientMock())
+		Expect(err).NotTo(HaveOccurred())
+		return consumer
+	}
+
+	deliver := func(consumer *Consumer, offset int64, stop chan struct{}) {
+		consumer.receiveMessage(&kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &topic, Offset: kafka.Offset(offset)},
+		}, stop)
+	}
+
+	BeforeEach(func() {
+		config = viper.New()
+		config.Set("extensions.kafkaconsumer.messageHandles", true)
+	})
+
+	Describe("[Unit]", func() {
+		It("should not limit by default", func() {
+			consumer := newConsumer()
+			Expect(consumer.RateLimit).To(BeZero())
+			Expect(consumer.limiter).To(BeNil())
+		})
+
+		It("should configure the rate limit", func() {
+			config.Set("extensions.kafkaconsumer.rateLimit", 20)
+			config.Set("extensions.kafkaconsumer.rateLimitBurst", 2)
+			consumer := newConsumer()
+			Expect(consumer.RateLimit).To(Equal(float64(20)))
+			Expect(consumer.RateLimitBurst).To(Equal(2))
+
+			start := time.Now()
+			for i := int64(0); i < 4; i++ {
+				deliver(consumer, i, make(chan struct{}))
+			}
+			Expect(time.Since(start)).To(BeNumerically(">=", 90*time.Millisecond))
+			Expect(consumer.handlesChan).To(HaveLen(4))
+		})
+
+		It("should drop the message when stopped while waiting", func() {
+			consumer := newConsumer()
+			consumer.SetRateLimit(0.1, 1)
+			stop := make(chan struct{})
+			deliver(consumer, 0, stop)
+			close(stop)
+			deliver(consumer, 1, stop)
+			Expect(consumer.handlesChan).To(HaveLen(1))
+		})
+
+		It("should serve pause requests while waiting", func() {
+			client := mocks.NewConsumerClientMock()
+			consumer, err := NewConsumer(config, logger, client)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(consumer.assignPartitions([]kafka.TopicPartition{{Topic: &topic}})).To(Succeed())
+			consumer.SetRateLimit(0.1, 1)
+			go consumer.ConsumeLoop()
+			defer consumer.StopConsuming()
+			client.EventsChan <- &kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic, Offset: 0}}
+			client.EventsChan <- &kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic, Offset: 1}}
+
+			paused := make(chan error, 1)
+			go func() {
+				paused <- consumer.Pause()
+			}()
+			Eventually(paused).Should(Receive(BeNil()))
+			Expect(client.PausedPartitions).To(HaveLen(1))
+			Expect(consumer.handlesChan).To(HaveLen(1))
+		})
+
+		It("should remove the limit", func() {
+			consumer := newConsumer()
+			consumer.SetRateLimit(0.1, 1)
+			consumer.SetRateLimit(0, 0)
+			deliver(consumer, 0, nil)
+			deliver(consumer, 1, nil)
+			Expect(consumer.handlesChan).To(HaveLen(2))
+		})
+	})
+})
@@ -0,0 +1,70 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package kafka
+
+import (
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// PartitionsAssignedHook is called when partitions are assigned to the consumer, before
+// consuming from them. It may return the partitions with the offsets to start consuming
+// from, e.g. loaded from an external offset store, or nil to keep them as they are
+type PartitionsAssignedHook func(partitions []kafka.TopicPartition) ([]kafka.TopicPartition, error)
+
+// PartitionsRevokedHook is called when partitions are revoked from the consumer, before
+// the offsets of the acknowledged messages are committed and the partitions are unassigned
+type PartitionsRevokedHook func(partitions []kafka.TopicPartition) error
+
+// AddPartitionsAssignedHook registers a hook to run on every partition assignment.
+// Hooks run in the order they were added, each receiving the partitions returned by the previous one
+func (q *Consumer) AddPartitionsAssignedHook(hook PartitionsAssignedHook) {
+	q.assignedHooks = append(q.assignedHooks, hook)
+}
+
+// AddPartitionsRevokedHook registers a hook to run on every partition revocation
+func (q *Consumer) AddPartitionsRevokedHook(hook PartitionsRevokedHook) {
+	q.revokedHooks = append(q.revokedHooks, hook)
+}
+
+func (q *Consumer) runPartitionsAssignedHooks(partitions []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+	for _, hook := range q.assignedHooks {
+		assigned, err := hook(partitions)
+		if err != nil {
+			return nil, err
+		}
+		if assigned != nil {
+			partitions = assigned
+		}
+	}
+	return partitions, nil
+}
+
+func (q *Consumer) runPartitionsRevokedHooks(partitions []kafka.TopicPartition) error {
+	for _, hook := range q.revokedHooks {
+		err := hook(partitions)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
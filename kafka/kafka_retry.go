@@ -0,0 +1,255 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Headers added to messages republished to retry and dead-letter topics, along with their original headers
+const (
+	HeaderOriginalTopic     = "x-original-topic"
+	HeaderOriginalPartition = "x-original-partition"
+	HeaderOriginalOffset    = "x-original-offset"
+	HeaderError             = "x-error"
+	HeaderAttempts          = "x-attempts"
+	HeaderFailedAt          = "x-failed-at"
+)
+
+// MessageHandler processes a message consumed from kafka
+type MessageHandler func(*Message) error
+
+// RetryPolicy configures how failed messages are retried before being sent to the dead-letter topic
+type RetryPolicy struct {
+	MaxAttempts     int
+	Backoff         time.Duration
+	MaxBackoff      time.Duration
+	RetryTopics     []string
+	RetryDelays     []time.Duration
+	DeadLetterTopic string
+}
+
+// Retrier runs message handlers according to a RetryPolicy, republishing messages that
+// still fail to the next retry topic or to the dead-letter topic
+type Retrier struct {
+	Config   *viper.Viper
+	Logger   *logrus.Logger
+	Policy   *RetryPolicy
+	Producer *SyncProducer
+}
+
+// NewRetrier for creating a new Retrier instance
+func NewRetrier(
+	config *viper.Viper,
+	logger *logrus.Logger,
+	producer *SyncProducer,
+) (*Retrier, error) {
+	return NewRetrierWithPrefix(config, logger, "extensions.kafkaconsumer.retry", producer)
+}
+
+// NewRetrierWithPrefix for creating a new Retrier instance
+func NewRetrierWithPrefix(
+	config *viper.Viper,
+	logger *logrus.Logger,
+	prefix string,
+	producer *SyncProducer,
+) (*Retrier, error) {
+	if prefix != "" {
+		prefix += "."
+	}
+	r := &Retrier{
+		Config:   config,
+		Logger:   logger,
+		Producer: producer,
+	}
+	err := r.configure(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Retrier) loadConfigurationDefaults(prefix string) {
+	r.Config.SetDefault(prefix+"maxAttempts", 3)
+	r.Config.SetDefault(prefix+"backoff", 100)
+	r.Config.SetDefault(prefix+"maxBackoff", 5000)
+	r.Config.SetDefault(prefix+"topics", []string{})
+	r.Config.SetDefault(prefix+"delays", []int{})
+	r.Config.SetDefault(prefix+"deadLetterTopic", "")
+}
+
+func (r *Retrier) configure(prefix string) error {
+	r.loadConfigurationDefaults(prefix)
+	r.Policy = &RetryPolicy{
+		MaxAttempts:     r.Config.GetInt(prefix + "maxAttempts"),
+		Backoff:         time.Duration(r.Config.GetInt(prefix+"backoff")) * time.Millisecond,
+		MaxBackoff:      time.Duration(r.Config.GetInt(prefix+"maxBackoff")) * time.Millisecond,
+		RetryTopics:     r.Config.GetStringSlice(prefix + "topics"),
+		DeadLetterTopic: r.Config.GetString(prefix + "deadLetterTopic"),
+	}
+	for _, delay := range r.Config.GetIntSlice(prefix + "delays") {
+		r.Policy.RetryDelays = append(r.Policy.RetryDelays, time.Duration(delay)*time.Millisecond)
+	}
+
+	if r.Policy.MaxAttempts < 1 {
+		return fmt.Errorf("%smaxAttempts must be at least 1", prefix)
+	}
+	if len(r.Policy.RetryDelays) != len(r.Policy.RetryTopics) {
+		return fmt.Errorf("%sdelays must have one delay for each retry topic", prefix)
+	}
+	if (len(r.Policy.RetryTopics) > 0 || r.Policy.DeadLetterTopic != "") && r.Producer == nil {
+		return fmt.Errorf("a producer is required to publish to retry and dead-letter topics")
+	}
+	return nil
+}
+
+// Topics returns the retry topics the consumer must also subscribe to
+func (r *Retrier) Topics() []string {
+	return r.Policy.RetryTopics
+}
+
+// Handle runs handler for m, retrying up to MaxAttempts times with exponential backoff.
+// Messages that still fail are republished to the next retry topic, or to the dead-letter
+// topic once every retry topic was exhausted. Messages consumed from a retry topic are
+// only handled after the delay configured for that topic has elapsed since they were produced.
+// Handle returns nil when the message was handled or republished, so it can be acknowledged
+func (r *Retrier) Handle(ctx context.Context, m *Message, handler MessageHandler) error {
+	l := r.Logger.WithFields(logrus.Fields{
+		"method":    "Handle",
+		"topic":     m.Topic,
+		"partition": m.Partition,
+		"offset":    m.Offset,
+	})
+
+	level := r.retryLevel(m.Topic)
+	if level > 0 {
+		err := r.waitRetryDelay(ctx, m, r.Policy.RetryDelays[level-1])
+		if err != nil {
+			return err
+		}
+	}
+
+	var err error
+	backoff := r.Policy.Backoff
+	for attempt := 1; attempt <= r.Policy.MaxAttempts; attempt++ {
+		err = handler(m)
+		if err == nil {
+			return nil
+		}
+		l.WithError(err).Warnf("attempt %d of %d failed", attempt, r.Policy.MaxAttempts)
+		if attempt == r.Policy.MaxAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > r.Policy.MaxBackoff {
+			backoff = r.Policy.MaxBackoff
+		}
+	}
+
+	headers := r.failureHeaders(m, err, level)
+	if level < len(r.Policy.RetryTopics) {
+		return r.republish(ctx, l, r.Policy.RetryTopics[level], m, headers)
+	}
+	if r.Policy.DeadLetterTopic != "" {
+		return r.republish(ctx, l, r.Policy.DeadLetterTopic, m, headers)
+	}
+	return err
+}
+
+// retryLevel returns 0 for messages from the original topics and i+1 for messages
+// from the i-th retry topic
+func (r *Retrier) retryLevel(topic string) int {
+	for i, retryTopic := range r.Policy.RetryTopics {
+		if retryTopic == topic {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func (r *Retrier) waitRetryDelay(ctx context.Context, m *Message, delay time.Duration) error {
+	wait := time.Until(m.Timestamp.Add(delay))
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// failureHeaders returns the headers of m with the failure described in the x- headers,
+// replacing the ones of earlier failures. The original topic, partition and offset are
+// kept from the headers of messages consumed from retry topics
+func (r *Retrier) failureHeaders(m *Message, err error, level int) []Header {
+	original := func(key, value string) Header {
+		if v, ok := m.Header(key); ok {
+			return Header{Key: key, Value: v}
+		}
+		return Header{Key: key, Value: []byte(value)}
+	}
+	failure := []Header{
+		original(HeaderOriginalTopic, m.Topic),
+		original(HeaderOriginalPartition, strconv.Itoa(int(m.Partition))),
+		original(HeaderOriginalOffset, strconv.FormatInt(m.Offset, 10)),
+		{Key: HeaderError, Value: []byte(err.Error())},
+		{Key: HeaderAttempts, Value: []byte(strconv.Itoa((level + 1) * r.Policy.MaxAttempts))},
+		{Key: HeaderFailedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339Nano))},
+	}
+	replaced := map[string]bool{}
+	for _, header := range failure {
+		replaced[header.Key] = true
+	}
+	headers := make([]Header, 0, len(m.Headers)+len(failure))
+	for _, header := range m.Headers {
+		if !replaced[header.Key] {
+			headers = append(headers, header)
+		}
+	}
+	return append(headers, failure...)
+}
+
+func (r *Retrier) republish(ctx context.Context, l *logrus.Entry, topic string, m *Message, headers []Header) error {
+	_, _, err := r.Producer.ProduceMessageWithContext(ctx, topic, m.Key, m.Value, headers)
+	if err != nil {
+		l.WithError(err).WithField("destination", topic).Error("error republishing failed message")
+		return err
+	}
+	l.WithField("destination", topic).Info("failed message republished")
+	return nil
+}
@@ -0,0 +1,275 @@
+/*
+ * Copyright (c) 2016 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/kafka/mocks"
+)
+
+var _ = Describe("Retrier", func() {
+	logger, hook := test.NewNullLogger()
+	logger.Level = logrus.DebugLevel
+
+	var config *viper.Viper
+	var mockCtrl *gomock.Controller
+	var mockProducer *mocks.MockSyncProducer
+	var producer *SyncProducer
+	var ctx context.Context
+
+	headerValue := func(m *sarama.ProducerMessage, key string) string {
+		for _, header := range m.Headers {
+			if string(header.Key) == key {
+				return string(header.Value)
+			}
+		}
+		return ""
+	}
+
+	BeforeEach(func() {
+		hook.Reset()
+		ctx = context.Background()
+		config = viper.New()
+		config.Set("extensions.kafkaconsumer.retry.backoff", 1)
+		config.Set("extensions.kafkaconsumer.retry.maxBackoff", 2)
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockProducer = mocks.NewMockSyncProducer(mockCtrl)
+		var err error
+		producer, err = NewSyncProducer(config, logger, sarama.NewConfig(), mockProducer)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Describe("[Unit]", func() {
+		Describe("Configuration", func() {
+			It("should configure defaults", func() {
+				retrier, err := NewRetrier(viper.New(), logger, nil)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(retrier.Policy.MaxAttempts).To(Equal(3))
+				Expect(retrier.Policy.Backoff).To(Equal(100 * time.Millisecond))
+				Expect(retrier.Policy.MaxBackoff).To(Equal(5 * time.Second))
+				Expect(retrier.Policy.RetryTopics).To(BeEmpty())
+				Expect(retrier.Policy.DeadLetterTopic).To(BeEmpty())
+			})
+
+			It("should fail if delays do not match retry topics", func() {
+				config.Set("extensions.kafkaconsumer.retry.topics", []string{"retry-1", "retry-2"})
+				config.Set("extensions.kafkaconsumer.retry.delays", []int{1000})
+				_, err := NewRetrier(config, logger, producer)
+				Expect(err).To(HaveOccurred())
+			})
+
+			It("should fail if there is no producer to republish messages", func() {
+				config.Set("extensions.kafkaconsumer.retry.deadLetterTopic", "dlq")
+				_, err := NewRetrier(config, logger, nil)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+
+		Describe("Handle", func() {
+			It("should retry until the handler succeeds", func() {
+				retrier, err := NewRetrier(config, logger, producer)
+				Expect(err).NotTo(HaveOccurred())
+				calls := 0
+				err = retrier.Handle(ctx, &Message{Topic: "topic"}, func(*Message) error {
+					calls++
+					if calls < 3 {
+						return fmt.Errorf("failed")
+					}
+					return nil
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(calls).To(Equal(3))
+			})
+
+			It("should return the error if there is nowhere to republish the message", func() {
+				retrier, err := NewRetrier(config, logger, producer)
+				Expect(err).NotTo(HaveOccurred())
+				err = retrier.Handle(ctx, &Message{Topic: "topic"}, func(*Message) error {
+					return fmt.Errorf("failed")
+				})
+				Expect(err).To(MatchError("failed"))
+			})
+
+			It("should send messages that exhausted every attempt to the dead-letter topic", func() {
+				config.Set("extensions.kafkaconsumer.retry.deadLetterTopic", "dlq")
+				retrier, err := NewRetrier(config, logger, producer)
+				Expect(err).NotTo(HaveOccurred())
+
+				var sent *sarama.ProducerMessage
+				mockProducer.EXPECT().SendMessage(gomock.Any()).DoAndReturn(func(m *sarama.ProducerMessage) (int32, int64, error) {
+					sent = m
+					return 0, 0, nil
+				})
+				err = retrier.Handle(ctx, &Message{Topic: "topic", Partition: 2, Offset: 42, Key: []byte("key"), Value: []byte("payload")}, func(*Message) error {
+					return fmt.Errorf("failed")
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(sent.Topic).To(Equal("dlq"))
+				Expect(sent.Key).To(Equal(sarama.ByteEncoder("key")))
+				Expect(sent.Value).To(Equal(sarama.ByteEncoder("payload")))
+				Expect(headerValue(sent, HeaderOriginalTopic)).To(Equal("topic"))
+				Expect(headerValue(sent, HeaderOriginalPartition)).To(Equal("2"))
+				Expect(headerValue(sent, HeaderOriginalOffset)).To(Equal("42"))
+				Expect(headerValue(sent, HeaderError)).To(Equal("failed"))
+				Expect(headerValue(sent, HeaderAttempts)).To(Equal("3"))
+			})
+
+			It("should send failed messages to the next retry topic", func() {
+				config.Set("extensions.kafkaconsumer.retry.topics", []string{"retry-1", "retry-2"})
+				config.Set("extensions.kafkaconsumer.retry.delays", []int{0, 0})
+				config.Set("extensions.kafkaconsumer.retry.deadLetterTopic", "dlq")
+				retrier, err := NewRetrier(config, logger, producer)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(retrier.Topics()).To(Equal([]string{"retry-1", "retry-2"}))
+
+				topics := []string{}
+				mockProducer.EXPECT().SendMessage(gomock.Any()).DoAndReturn(func(m *sarama.ProducerMessage) (int32, int64, error) {
+					topics = append(topics, m.Topic)
+					return 0, 0, nil
+				}).Times(3)
+				failing := func(*Message) error { return fmt.Errorf("failed") }
+				for _, topic := range []string{"topic", "retry-1", "retry-2"} {
+					Expect(retrier.Handle(ctx, &Message{Topic: topic}, failing)).To(Succeed())
+				}
+				Expect(topics).To(Equal([]string{"retry-1", "retry-2", "dlq"}))
+			})
+
+			It("should keep the original topic, partition and offset of retried messages", func() {
+				config.Set("extensions.kafkaconsumer.retry.topics", []string{"retry-1"})
+				config.Set("extensions.kafkaconsumer.retry.delays", []int{0})
+				config.Set("extensions.kafkaconsumer.retry.deadLetterTopic", "dlq")
+				retrier, err := NewRetrier(config, logger, producer)
+				Expect(err).NotTo(HaveOccurred())
+
+				var sent *sarama.ProducerMessage
+				mockProducer.EXPECT().SendMessage(gomock.Any()).DoAndReturn(func(m *sarama.ProducerMessage) (int32, int64, error) {
+					sent = m
+					return 0, 0, nil
+				})
+				m := &Message{
+					Topic:     "retry-1",
+					Partition: 0,
+					Offset:    7,
+					Headers: []Header{
+						{Key: HeaderOriginalTopic, Value: []byte("topic")},
+						{Key: HeaderOriginalPartition, Value: []byte("2")},
+						{Key: HeaderOriginalOffset, Value: []byte("42")},
+					},
+				}
+				Expect(retrier.Handle(ctx, m, func(*Message) error { return fmt.Errorf("failed again") })).To(Succeed())
+				Expect(sent.Topic).To(Equal("dlq"))
+				Expect(headerValue(sent, HeaderOriginalTopic)).To(Equal("topic"))
+				Expect(headerValue(sent, HeaderOriginalPartition)).To(Equal("2"))
+				Expect(headerValue(sent, HeaderOriginalOffset)).To(Equal("42"))
+				Expect(headerValue(sent, HeaderError)).To(Equal("failed again"))
+			})
+
+			It("should keep the original headers and replace the ones of earlier failures", func() {
+				config.Set("extensions.kafkaconsumer.retry.topics", []string{"retry-1", "retry-2"})
+				config.Set("extensions.kafkaconsumer.retry.delays", []int{0, 0})
+				retrier, err := NewRetrier(config, logger, producer)
+				Expect(err).NotTo(HaveOccurred())
+
+				var sent *sarama.ProducerMessage
+				mockProducer.EXPECT().SendMessage(gomock.Any()).DoAndReturn(func(m *sarama.ProducerMessage) (int32, int64, error) {
+					sent = m
+					return 0, 0, nil
+				})
+				m := &Message{
+					Topic: "retry-1",
+					Headers: []Header{
+						{Key: "routing-key", Value: []byte("eu")},
+						{Key: HeaderError, Value: []byte("failed")},
+					},
+				}
+				Expect(retrier.Handle(ctx, m, func(*Message) error { return fmt.Errorf("failed again") })).To(Succeed())
+				Expect(sent.Topic).To(Equal("retry-2"))
+				Expect(headerValue(sent, "routing-key")).To(Equal("eu"))
+				errors := 0
+				for _, header := range sent.Headers {
+					if string(header.Key) == HeaderError {
+						errors++
+						Expect(string(header.Value)).To(Equal("failed again"))
+					}
+				}
+				Expect(errors).To(Equal(1))
+			})
+
+			It("should wait for the retry topic delay before handling the message", func() {
+				config.Set("extensions.kafkaconsumer.retry.topics", []string{"retry-1"})
+				config.Set("extensions.kafkaconsumer.retry.delays", []int{50})
+				retrier, err := NewRetrier(config, logger, producer)
+				Expect(err).NotTo(HaveOccurred())
+
+				produced := time.Now()
+				var handled time.Time
+				err = retrier.Handle(ctx, &Message{Topic: "retry-1", Timestamp: produced}, func(*Message) error {
+					handled = time.Now()
+					return nil
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(handled.Sub(produced)).To(BeNumerically(">=", 50*time.Millisecond))
+			})
+
+			It("should stop waiting when the context is cancelled", func() {
+				config.Set("extensions.kafkaconsumer.retry.topics", []string{"retry-1"})
+				config.Set("extensions.kafkaconsumer.retry.delays", []int{60000})
+				retrier, err := NewRetrier(config, logger, producer)
+				Expect(err).NotTo(HaveOccurred())
+
+				cancelCtx, cancel := context.WithCancel(ctx)
+				cancel()
+				err = retrier.Handle(cancelCtx, &Message{Topic: "retry-1", Timestamp: time.Now()}, func(*Message) error {
+					return nil
+				})
+				Expect(err).To(Equal(context.Canceled))
+			})
+
+			It("should return the error if republishing fails", func() {
+				config.Set("extensions.kafkaconsumer.retry.deadLetterTopic", "dlq")
+				retrier, err := NewRetrier(config, logger, producer)
+				Expect(err).NotTo(HaveOccurred())
+
+				mockProducer.EXPECT().SendMessage(gomock.Any()).Return(int32(0), int64(0), fmt.Errorf("broker down"))
+				err = retrier.Handle(ctx, &Message{Topic: "topic"}, func(*Message) error {
+					return fmt.Errorf("failed")
+				})
+				Expect(err).To(MatchError("broker down"))
+			})
+		})
+	})
+})
@@ -23,12 +23,18 @@
 package kafka
 
 import (
+	"context"
 	"strings"
+	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/Shopify/sarama"
 	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/codec"
+	"github.com/topfreegames/extensions/v9/tracing"
+	tkafka "github.com/topfreegames/extensions/v9/tracing/kafka"
 )
 
 // SyncProducer is a kafka producer using sarama lib
@@ -37,10 +43,14 @@ type SyncProducer struct {
 	logger      *log.Logger
 	kafkaConfig *sarama.Config
 	Brokers     string
+	Codec       codec.Codec
 	Producer    sarama.SyncProducer
+	txnMutex    sync.Mutex
 }
 
-// NewSyncProducer creates a new kafka sync producer
+// NewSyncProducer creates a new kafka sync producer, a nil kafkaConfig uses
+// sarama.NewConfig(). A given kafkaConfig is copied so the options set by the producer
+// do not modify it, see copySaramaConfig for the values still shared with it
 func NewSyncProducer(
 	config *viper.Viper,
 	logger *log.Logger,
@@ -61,10 +71,15 @@ func NewSyncProducerWithPrefix(
 	if prefix != "" {
 		prefix += "."
 	}
+	if kafkaConfig == nil {
+		kafkaConfig = sarama.NewConfig()
+	}
+	// the configuration is copied so that the options set by configure do not
+	// leak into the caller's config, which may be shared by other clients
 	s := &SyncProducer{
 		config:      config,
 		logger:      logger,
-		kafkaConfig: kafkaConfig,
+		kafkaConfig: copySaramaConfig(kafkaConfig),
 	}
 	var producer sarama.SyncProducer
 	if len(clientOrNil) == 1 {
@@ -74,17 +89,49 @@ func NewSyncProducerWithPrefix(
 	return s, err
 }
 
+// copySaramaConfig copies kafkaConfig along with its TLS config and slices, so configuring
+// the copy never modifies kafkaConfig. The metric registry, which collects the metrics of
+// every client created with it, and the dialers, partitioner and other values held by
+// reference are shared
+func copySaramaConfig(kafkaConfig *sarama.Config) *sarama.Config {
+	c := *kafkaConfig
+	if kafkaConfig.Net.TLS.Config != nil {
+		c.Net.TLS.Config = kafkaConfig.Net.TLS.Config.Clone()
+	}
+	c.Producer.Interceptors = append([]sarama.ProducerInterceptor(nil), kafkaConfig.Producer.Interceptors...)
+	c.Consumer.Interceptors = append([]sarama.ConsumerInterceptor(nil), kafkaConfig.Consumer.Interceptors...)
+	c.Consumer.Group.Rebalance.GroupStrategies = append(
+		[]sarama.BalanceStrategy(nil), kafkaConfig.Consumer.Group.Rebalance.GroupStrategies...,
+	)
+	c.Consumer.Group.Member.UserData = append([]byte(nil), kafkaConfig.Consumer.Group.Member.UserData...)
+	return &c
+}
+
 func (s *SyncProducer) loadConfigurationDefaults(prefix string) {
 	s.config.SetDefault(prefix+"brokers", "localhost:9092")
+	s.config.SetDefault(prefix+"idempotent", false)
+	s.config.SetDefault(prefix+"transactionalID", "")
+	s.config.SetDefault(prefix+"codec", codec.NameJSON)
 }
 
 func (s *SyncProducer) configure(producer sarama.SyncProducer, prefix string) error {
 	s.loadConfigurationDefaults(prefix)
 	s.Brokers = s.config.GetString(prefix + "brokers")
+	valueCodec, err := codec.New(s.config.GetString(prefix + "codec"))
+	if err != nil {
+		return err
+	}
+	s.Codec = valueCodec
 	l := s.logger.WithFields(log.Fields{
 		"brokers": s.Brokers,
 	})
 	l.Debug("configuring kafka producer")
+	s.configureDeliveryGuarantees(prefix)
+	err = s.configureClient(prefix)
+	if err != nil {
+		l.WithError(err).Error("invalid kafka producer configuration")
+		return err
+	}
 	if producer == nil {
 		p, err := sarama.NewSyncProducer(strings.Split(s.Brokers, ","), s.kafkaConfig)
 		s.Producer = p
@@ -99,15 +146,144 @@ func (s *SyncProducer) configure(producer sarama.SyncProducer, prefix string) er
 	return nil
 }
 
-// Produce produces a message
+// configureDeliveryGuarantees sets the kafkaConfig options required by idempotent and
+// transactional producers, transactional producers are always idempotent
+func (s *SyncProducer) configureDeliveryGuarantees(prefix string) {
+	transactionalID := s.config.GetString(prefix + "transactionalID")
+	if !s.config.GetBool(prefix+"idempotent") && transactionalID == "" {
+		return
+	}
+	s.kafkaConfig.Producer.Idempotent = true
+	s.kafkaConfig.Producer.RequiredAcks = sarama.WaitForAll
+	s.kafkaConfig.Producer.Return.Successes = true
+	s.kafkaConfig.Net.MaxOpenRequests = 1
+	if s.kafkaConfig.Producer.Retry.Max < 1 {
+		s.kafkaConfig.Producer.Retry.Max = 1
+	}
+	if !s.kafkaConfig.Version.IsAtLeast(sarama.V0_11_0_0) {
+		s.kafkaConfig.Version = sarama.V0_11_0_0
+	}
+	if transactionalID != "" {
+		s.kafkaConfig.Producer.Transaction.ID = transactionalID
+	}
+}
+
+// configureClient applies the security settings and the sarama fields configured under
+// prefix to kafkaConfig and validates the resulting configuration
+func (s *SyncProducer) configureClient(prefix string) error {
+	security, err := newSecurityConfig(s.config, prefix)
+	if err != nil {
+		return err
+	}
+	err = security.ConfigureSarama(s.kafkaConfig)
+	if err != nil {
+		return err
+	}
+	err = decodeSaramaConfig(s.config, prefix+"sarama", s.kafkaConfig)
+	if err != nil {
+		return err
+	}
+	return s.kafkaConfig.Validate()
+}
+
+// Produce produces a message, traced like ProduceMessageWithContext without a parent span
 func (s *SyncProducer) Produce(topic string, message []byte) (int32, int64, error) {
-	s.logger.WithFields(log.Fields{
+	return s.ProduceMessageWithContext(context.Background(), topic, nil, message, nil)
+}
+
+// ProduceMessage produces a message with the given key and record headers, traced like
+// ProduceMessageWithContext without a parent span
+func (s *SyncProducer) ProduceMessage(topic string, key, message []byte, headers []Header) (int32, int64, error) {
+	return s.ProduceMessageWithContext(context.Background(), topic, key, message, headers)
+}
+
+// ProduceWithContext produces a message, tracing it as a child of the span active in ctx
+func (s *SyncProducer) ProduceWithContext(ctx context.Context, topic string, message []byte) (int32, int64, error) {
+	return s.ProduceMessageWithContext(ctx, topic, nil, message, nil)
+}
+
+// ProduceMessageWithContext produces a message with the given key and record headers,
+// tracing it as a child of the span active in ctx and propagating the span context
+// to consumers through the message headers
+func (s *SyncProducer) ProduceMessageWithContext(
+	ctx context.Context,
+	topic string,
+	key, message []byte,
+	headers []Header,
+) (int32, int64, error) {
+	l := s.logger.WithFields(log.Fields{
 		"topic":   topic,
+		"key":     string(key),
 		"message": string(message),
-	}).Debug("kafka sync producer extension sending message")
+	})
+	l.Debug("kafka sync producer extension sending traced message")
+
+	m := newProducerMessage(topic, key, message, headers)
+	span, _ := tkafka.StartProduceSpan(ctx, topic)
+	defer span.Finish()
+	defer tracing.LogPanic(span)
+
+	err := tkafka.Inject(span, m)
+	if err != nil {
+		l.WithError(err).Warn("error injecting span context into message headers")
+	}
+
+	partition, offset, err := s.Producer.SendMessage(m)
+	if err != nil {
+		tracing.LogError(span, err.Error())
+		return partition, offset, err
+	}
+	span.SetTag("kafka.partition", partition)
+	span.SetTag("kafka.offset", offset)
+	return partition, offset, nil
+}
+
+// ProduceValue encodes value with the producer Codec and produces it like ProduceMessageWithContext
+func (s *SyncProducer) ProduceValue(
+	ctx context.Context,
+	topic string,
+	key []byte,
+	value interface{},
+	headers []Header,
+) (int32, int64, error) {
+	data, err := s.Codec.Marshal(value)
+	if err != nil {
+		return 0, 0, err
+	}
+	return s.ProduceMessageWithContext(ctx, topic, key, data, headers)
+}
+
+// Flush returns the number of undelivered messages, which is always 0 since every
+// message is delivered synchronously. It exists so both producers can be handled alike
+func (s *SyncProducer) Flush(timeout time.Duration) int {
+	return 0
+}
+
+// Close closes the underlying sarama producer. The SyncProducer must not be used after Close
+func (s *SyncProducer) Close() error {
+	err := s.Producer.Close()
+	if err != nil {
+		s.logger.WithError(err).Error("error closing kafka producer")
+		return err
+	}
+	s.logger.Info("kafka producer closed")
+	return nil
+}
+
+func newProducerMessage(topic string, key, message []byte, headers []Header) *sarama.ProducerMessage {
 	m := &sarama.ProducerMessage{
-		Topic: topic,
-		Value: sarama.ByteEncoder(message),
+		Topic:   topic,
+		Value:   sarama.ByteEncoder(message),
+		Headers: make([]sarama.RecordHeader, 0, len(headers)),
+	}
+	if key != nil {
+		m.Key = sarama.ByteEncoder(key)
+	}
+	for _, header := range headers {
+		m.Headers = append(m.Headers, sarama.RecordHeader{
+			Key:   []byte(header.Key),
+			Value: header.Value,
+		})
 	}
-	return s.Producer.SendMessage(m)
+	return m
 }
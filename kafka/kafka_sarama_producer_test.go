@@ -23,9 +23,14 @@
 package kafka
 
 import (
+	"context"
+	"time"
+
 	"github.com/Shopify/sarama"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
 
 	"github.com/golang/mock/gomock"
 	"github.com/sirupsen/logrus"
@@ -127,3 +132,77 @@ var _ = XDescribe("SyncProducer Extension", func() {
 		})
 	})
 })
+
+var _ = Describe("SyncProducer Tracing", func() {
+	var mockProducer *mocks.MockSyncProducer
+	var mockCtrl *gomock.Controller
+	var tracer *mocktracer.MockTracer
+	logger, _ := test.NewNullLogger()
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockProducer = mocks.NewMockSyncProducer(mockCtrl)
+		tracer = mocktracer.New()
+		opentracing.SetGlobalTracer(tracer)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+		opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+	})
+
+	Describe("[Unit]", func() {
+		It("should propagate the active span in the message headers", func() {
+			producer, err := NewSyncProducer(viper.New(), logger, sarama.NewConfig(), mockProducer)
+			Expect(err).NotTo(HaveOccurred())
+			parent := tracer.StartSpan("parent")
+			ctx := opentracing.ContextWithSpan(context.Background(), parent)
+
+			var sent *sarama.ProducerMessage
+			mockProducer.EXPECT().SendMessage(gomock.Any()).DoAndReturn(func(m *sarama.ProducerMessage) (int32, int64, error) {
+				sent = m
+				return 1, 2, nil
+			})
+			_, _, err = producer.ProduceMessageWithContext(ctx, "topic", []byte("key"), []byte("message"), []Header{{Key: "custom", Value: []byte("value")}})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(sent.Key).To(Equal(sarama.ByteEncoder("key")))
+			Expect(sent.Headers).To(ContainElement(sarama.RecordHeader{Key: []byte("custom"), Value: []byte("value")}))
+			carrier := opentracing.TextMapCarrier{}
+			for _, header := range sent.Headers {
+				carrier[string(header.Key)] = string(header.Value)
+			}
+			spanContext, err := tracer.Extract(opentracing.TextMap, carrier)
+			Expect(err).NotTo(HaveOccurred())
+
+			finished := tracer.FinishedSpans()
+			Expect(finished).To(HaveLen(1))
+			Expect(finished[0].OperationName).To(Equal("kafka produce topic"))
+			Expect(finished[0].ParentID).To(Equal(parent.(*mocktracer.MockSpan).SpanContext.SpanID))
+			Expect(spanContext.(mocktracer.MockSpanContext).SpanID).To(Equal(finished[0].SpanContext.SpanID))
+			Expect(finished[0].Tag("kafka.partition")).To(BeEquivalentTo(1))
+			Expect(finished[0].Tag("kafka.offset")).To(BeEquivalentTo(2))
+		})
+
+		It("should encode values with the producer codec", func() {
+			producer, err := NewSyncProducer(viper.New(), logger, sarama.NewConfig(), mockProducer)
+			Expect(err).NotTo(HaveOccurred())
+			var sent *sarama.ProducerMessage
+			mockProducer.EXPECT().SendMessage(gomock.Any()).DoAndReturn(func(m *sarama.ProducerMessage) (int32, int64, error) {
+				sent = m
+				return 0, 0, nil
+			})
+			_, _, err = producer.ProduceValue(context.Background(), "topic", nil, map[string]int{"id": 1}, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sent.Value).To(BeEquivalentTo(`{"id":1}`))
+		})
+
+		It("should close the underlying producer", func() {
+			producer, err := NewSyncProducer(viper.New(), logger, sarama.NewConfig(), mockProducer)
+			Expect(err).NotTo(HaveOccurred())
+			mockProducer.EXPECT().Close().Return(nil)
+			Expect(producer.Flush(time.Second)).To(Equal(0))
+			Expect(producer.Close()).To(Succeed())
+		})
+	})
+})
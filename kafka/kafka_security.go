@@ -0,0 +1,206 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package kafka
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/Shopify/sarama"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/spf13/viper"
+)
+
+// SASL mechanisms supported by the security helpers
+const (
+	SASLMechanismPlain       = "PLAIN"
+	SASLMechanismScramSHA256 = "SCRAM-SHA-256"
+	SASLMechanismScramSHA512 = "SCRAM-SHA-512"
+)
+
+// SASLConfig configures SASL authentication with the brokers
+type SASLConfig struct {
+	Enabled   bool
+	Mechanism string
+	Username  string
+	Password  string
+}
+
+// TLSConfig configures TLS connections to the brokers, the certificate and key
+// are only needed for client authentication
+type TLSConfig struct {
+	Enabled            bool
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+}
+
+// SecurityConfig holds the SASL and TLS settings of a kafka client
+type SecurityConfig struct {
+	SASL SASLConfig
+	TLS  TLSConfig
+}
+
+// NewSecurityConfig reads the SASL and TLS settings under prefix, e.g.
+// extensions.kafkaconsumer.sasl.username and extensions.kafkaconsumer.tls.caFile
+func NewSecurityConfig(config *viper.Viper, prefix string) (*SecurityConfig, error) {
+	if prefix != "" {
+		prefix += "."
+	}
+	return newSecurityConfig(config, prefix)
+}
+
+func newSecurityConfig(config *viper.Viper, prefix string) (*SecurityConfig, error) {
+	config.SetDefault(prefix+"sasl.enabled", false)
+	config.SetDefault(prefix+"sasl.mechanism", SASLMechanismPlain)
+	config.SetDefault(prefix+"tls.enabled", false)
+	config.SetDefault(prefix+"tls.insecureSkipVerify", false)
+
+	s := &SecurityConfig{
+		SASL: SASLConfig{
+			Enabled:   config.GetBool(prefix + "sasl.enabled"),
+			Mechanism: config.GetString(prefix + "sasl.mechanism"),
+			Username:  config.GetString(prefix + "sasl.username"),
+			Password:  config.GetString(prefix + "sasl.password"),
+		},
+		TLS: TLSConfig{
+			Enabled:            config.GetBool(prefix + "tls.enabled"),
+			CAFile:             config.GetString(prefix + "tls.caFile"),
+			CertFile:           config.GetString(prefix + "tls.certFile"),
+			KeyFile:            config.GetString(prefix + "tls.keyFile"),
+			InsecureSkipVerify: config.GetBool(prefix + "tls.insecureSkipVerify"),
+		},
+	}
+	err := s.validate(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SecurityConfig) validate(prefix string) error {
+	if s.SASL.Enabled {
+		switch s.SASL.Mechanism {
+		case SASLMechanismPlain, SASLMechanismScramSHA256, SASLMechanismScramSHA512:
+		default:
+			return fmt.Errorf("%ssasl.mechanism %q is not supported", prefix, s.SASL.Mechanism)
+		}
+		if s.SASL.Username == "" {
+			return fmt.Errorf("%ssasl.username is required when SASL is enabled", prefix)
+		}
+	}
+	if s.TLS.Enabled && (s.TLS.CertFile == "") != (s.TLS.KeyFile == "") {
+		return fmt.Errorf("%stls.certFile and %stls.keyFile must be set together", prefix, prefix)
+	}
+	return nil
+}
+
+// SecurityProtocol returns the kafka security protocol matching the enabled settings
+func (s *SecurityConfig) SecurityProtocol() string {
+	switch {
+	case s.SASL.Enabled && s.TLS.Enabled:
+		return "SASL_SSL"
+	case s.SASL.Enabled:
+		return "SASL_PLAINTEXT"
+	case s.TLS.Enabled:
+		return "SSL"
+	}
+	return "PLAINTEXT"
+}
+
+// ConfigMap returns the librdkafka properties for the enabled settings, it is empty
+// when neither SASL nor TLS are enabled
+func (s *SecurityConfig) ConfigMap() kafka.ConfigMap {
+	configMap := kafka.ConfigMap{}
+	if !s.SASL.Enabled && !s.TLS.Enabled {
+		return configMap
+	}
+	configMap["security.protocol"] = s.SecurityProtocol()
+	if s.SASL.Enabled {
+		configMap["sasl.mechanisms"] = s.SASL.Mechanism
+		configMap["sasl.username"] = s.SASL.Username
+		configMap["sasl.password"] = s.SASL.Password
+	}
+	if s.TLS.Enabled {
+		if s.TLS.CAFile != "" {
+			configMap["ssl.ca.location"] = s.TLS.CAFile
+		}
+		if s.TLS.CertFile != "" {
+			configMap["ssl.certificate.location"] = s.TLS.CertFile
+			configMap["ssl.key.location"] = s.TLS.KeyFile
+		}
+		if s.TLS.InsecureSkipVerify {
+			configMap["enable.ssl.certificate.verification"] = false
+		}
+	}
+	return configMap
+}
+
+// ConfigureSarama applies the enabled settings to kafkaConfig. SCRAM mechanisms also need
+// kafkaConfig.Net.SASL.SCRAMClientGeneratorFunc to be set by the caller
+func (s *SecurityConfig) ConfigureSarama(kafkaConfig *sarama.Config) error {
+	if s.SASL.Enabled {
+		kafkaConfig.Net.SASL.Enable = true
+		kafkaConfig.Net.SASL.Handshake = true
+		kafkaConfig.Net.SASL.Mechanism = sarama.SASLMechanism(s.SASL.Mechanism)
+		kafkaConfig.Net.SASL.User = s.SASL.Username
+		kafkaConfig.Net.SASL.Password = s.SASL.Password
+	}
+	if s.TLS.Enabled {
+		tlsConfig, err := s.TLS.tlsConfig()
+		if err != nil {
+			return err
+		}
+		kafkaConfig.Net.TLS.Enable = true
+		kafkaConfig.Net.TLS.Config = tlsConfig
+	}
+	return nil
+}
+
+func (t *TLSConfig) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+	if t.CAFile != "" {
+		ca, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s", t.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
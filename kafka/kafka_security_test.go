@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) 2016 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package kafka
+
+import (
+	"github.com/Shopify/sarama"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/viper"
+)
+
+var _ = Describe("SecurityConfig", func() {
+	var config *viper.Viper
+
+	BeforeEach(func() {
+		config = viper.New()
+	})
+
+	Describe("[Unit]", func() {
+		It("should not set any property by default", func() {
+			security, err := NewSecurityConfig(config, "extensions.kafkaconsumer")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(security.SecurityProtocol()).To(Equal("PLAINTEXT"))
+			Expect(security.ConfigMap()).To(BeEmpty())
+		})
+
+		It("should configure SASL over TLS", func() {
+			config.Set("extensions.kafkaconsumer.sasl.enabled", true)
+			config.Set("extensions.kafkaconsumer.sasl.mechanism", SASLMechanismScramSHA512)
+			config.Set("extensions.kafkaconsumer.sasl.username", "user")
+			config.Set("extensions.kafkaconsumer.sasl.password", "secret")
+			config.Set("extensions.kafkaconsumer.tls.enabled", true)
+			config.Set("extensions.kafkaconsumer.tls.caFile", "/etc/kafka/ca.pem")
+			security, err := NewSecurityConfig(config, "extensions.kafkaconsumer")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(security.ConfigMap()).To(Equal(kafka.ConfigMap{
+				"security.protocol": "SASL_SSL",
+				"sasl.mechanisms":   "SCRAM-SHA-512",
+				"sasl.username":     "user",
+				"sasl.password":     "secret",
+				"ssl.ca.location":   "/etc/kafka/ca.pem",
+			}))
+		})
+
+		It("should configure sarama", func() {
+			config.Set("sasl.enabled", true)
+			config.Set("sasl.username", "user")
+			config.Set("sasl.password", "secret")
+			config.Set("tls.enabled", true)
+			config.Set("tls.insecureSkipVerify", true)
+			security, err := NewSecurityConfig(config, "")
+			Expect(err).NotTo(HaveOccurred())
+
+			c := sarama.NewConfig()
+			Expect(security.ConfigureSarama(c)).To(Succeed())
+			Expect(c.Net.SASL.Enable).To(BeTrue())
+			Expect(c.Net.SASL.Mechanism).To(BeEquivalentTo(sarama.SASLTypePlaintext))
+			Expect(c.Net.SASL.User).To(Equal("user"))
+			Expect(c.Net.SASL.Password).To(Equal("secret"))
+			Expect(c.Net.TLS.Enable).To(BeTrue())
+			Expect(c.Net.TLS.Config.InsecureSkipVerify).To(BeTrue())
+			Expect(c.Validate()).To(Succeed())
+		})
+
+		It("should fail to read a missing CA file", func() {
+			config.Set("tls.enabled", true)
+			config.Set("tls.caFile", "/does/not/exist.pem")
+			security, err := NewSecurityConfig(config, "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(security.ConfigureSarama(sarama.NewConfig())).NotTo(Succeed())
+		})
+
+		It("should validate the settings", func() {
+			config.Set("sasl.enabled", true)
+			config.Set("sasl.mechanism", "GSSAPI")
+			config.Set("sasl.username", "user")
+			_, err := NewSecurityConfig(config, "")
+			Expect(err).To(HaveOccurred())
+
+			config.Set("sasl.mechanism", SASLMechanismPlain)
+			config.Set("sasl.username", "")
+			_, err = NewSecurityConfig(config, "")
+			Expect(err).To(HaveOccurred())
+
+			config.Set("sasl.enabled", false)
+			config.Set("tls.enabled", true)
+			config.Set("tls.certFile", "/etc/kafka/client.pem")
+			_, err = NewSecurityConfig(config, "")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should be applied to the consumer configuration", func() {
+			config.Set("extensions.kafkaconsumer.tls.enabled", true)
+			consumer := &Consumer{Config: config}
+			configMap, err := consumer.clientConfig("extensions.kafkaconsumer.")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*configMap).To(HaveKeyWithValue("security.protocol", "SSL"))
+		})
+	})
+})
@@ -0,0 +1,165 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Shopify/sarama"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrNotTransactional is returned when using transactions with a SyncProducer
+// configured without a transactionalID
+var ErrNotTransactional = errors.New("kafka: the producer is not transactional")
+
+// Record is a message to be produced by a SyncProducer
+type Record struct {
+	Topic   string
+	Key     []byte
+	Value   []byte
+	Headers []Header
+}
+
+// TransformFunc transforms a consumed message into the records to be produced
+type TransformFunc func(*Message) ([]*Record, error)
+
+// IsTransactional returns whether the producer was configured with a transactionalID
+func (s *SyncProducer) IsTransactional() bool {
+	return s.Producer.IsTransactional()
+}
+
+// BeginTransaction starts a new transaction, every message produced until the transaction is
+// committed or aborted is part of it, whichever goroutine produces it. BeginTransaction,
+// CommitTransaction and AbortTransaction are not safe for concurrent use, Transaction is
+func (s *SyncProducer) BeginTransaction() error {
+	if !s.IsTransactional() {
+		return ErrNotTransactional
+	}
+	return s.Producer.BeginTxn()
+}
+
+// CommitTransaction commits the current transaction
+func (s *SyncProducer) CommitTransaction() error {
+	if !s.IsTransactional() {
+		return ErrNotTransactional
+	}
+	return s.Producer.CommitTxn()
+}
+
+// AbortTransaction aborts the current transaction, its messages are never seen by
+// consumers reading with the read_committed isolation level
+func (s *SyncProducer) AbortTransaction() error {
+	if !s.IsTransactional() {
+		return ErrNotTransactional
+	}
+	return s.Producer.AbortTxn()
+}
+
+// Transaction runs fn inside a transaction, committing it if fn succeeds and aborting it otherwise.
+// Concurrent calls, such as from the workers of a WorkerPool, run one transaction at a time.
+// Messages produced outside of fn while a transaction is open are still part of it
+func (s *SyncProducer) Transaction(fn func() error) error {
+	s.txnMutex.Lock()
+	defer s.txnMutex.Unlock()
+	err := s.BeginTransaction()
+	if err != nil {
+		return err
+	}
+	err = fn()
+	if err == nil {
+		err = s.CommitTransaction()
+	}
+	if err != nil {
+		s.abort(err)
+		return err
+	}
+	return nil
+}
+
+// ConsumeTransformProduce produces the records returned by transform for m and commits the offset
+// of m for the consumer group of consumer in the same transaction, so m is processed exactly once
+// by consumers reading the produced topics with the read_committed isolation level. The consumer
+// must be in manual commit mode. The transaction commits the offset after every contiguous
+// acknowledged message of the partition of m, so no offset is committed while a previous message
+// is still being processed, and m is acknowledged once the transaction is committed without being
+// committed again by the consumer. Messages of a partition must be processed in order for the
+// exactly once guarantee to hold. It is safe for concurrent use, transactions run one at a time.
+// Pass m.Context() as ctx to trace the produced records as children of the consumed message
+func (s *SyncProducer) ConsumeTransformProduce(
+	ctx context.Context,
+	consumer *Consumer,
+	m *Message,
+	transform TransformFunc,
+) error {
+	if !consumer.ManualCommit {
+		return fmt.Errorf("the consumer must be in manual commit mode to commit offsets in a transaction")
+	}
+	committed := int64(-1)
+	err := s.Transaction(func() error {
+		records, err := transform(m)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			_, _, err = s.ProduceMessageWithContext(ctx, record.Topic, record.Key, record.Value, record.Headers)
+			if err != nil {
+				return err
+			}
+		}
+		next, advanced := m.Offset+1, true
+		if m.partition != nil {
+			next, advanced = m.partition.nextIfAcked(m.Offset)
+		}
+		if !advanced {
+			return nil
+		}
+		offsets := map[string][]*sarama.PartitionOffsetMetadata{
+			m.Topic: {{Partition: m.Partition, Offset: next}},
+		}
+		err = s.Producer.AddOffsetsToTxn(offsets, consumer.ConsumerGroup)
+		if err != nil {
+			return err
+		}
+		committed = next
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	m.ackCommitted(committed)
+	return nil
+}
+
+func (s *SyncProducer) abort(cause error) {
+	l := s.logger.WithFields(log.Fields{
+		"method": "abort",
+	})
+	l.WithError(cause).Warn("aborting kafka transaction")
+	err := s.AbortTransaction()
+	if err != nil {
+		l.WithError(err).Error("error aborting kafka transaction")
+	}
+}
@@ -0,0 +1,296 @@
+/*
+ * Copyright (c) 2016 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package kafka
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+
+	"github.com/Shopify/sarama"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/kafka/mocks"
+)
+
+var _ = Describe("SyncProducer Transactions", func() {
+	var config *viper.Viper
+	var mockProducer *mocks.MockSyncProducer
+	var mockCtrl *gomock.Controller
+	logger, _ := test.NewNullLogger()
+
+	BeforeEach(func() {
+		config = viper.New()
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockProducer = mocks.NewMockSyncProducer(mockCtrl)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Describe("[Unit]", func() {
+		Describe("Configuration", func() {
+			It("should not change the sarama config by default", func() {
+				c := sarama.NewConfig()
+				_, err := NewSyncProducer(config, logger, c, mockProducer)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(c.Producer.Idempotent).To(BeFalse())
+				Expect(c.Producer.Transaction.ID).To(BeEmpty())
+			})
+
+			It("should configure an idempotent producer", func() {
+				config.Set("extensions.kafkaproducer.idempotent", true)
+				producer, err := NewSyncProducer(config, logger, sarama.NewConfig(), mockProducer)
+				Expect(err).NotTo(HaveOccurred())
+				c := producer.kafkaConfig
+				Expect(c.Producer.Idempotent).To(BeTrue())
+				Expect(c.Producer.Transaction.ID).To(BeEmpty())
+				Expect(c.Validate()).To(Succeed())
+			})
+
+			It("should configure a transactional producer", func() {
+				config.Set("extensions.kafkaproducer.transactionalID", "pipeline-1")
+				producer, err := NewSyncProducer(config, logger, sarama.NewConfig(), mockProducer)
+				Expect(err).NotTo(HaveOccurred())
+				c := producer.kafkaConfig
+				Expect(c.Producer.Idempotent).To(BeTrue())
+				Expect(c.Producer.Transaction.ID).To(Equal("pipeline-1"))
+				Expect(c.Validate()).To(Succeed())
+			})
+
+			It("should not modify the caller's sarama config", func() {
+				config.Set("extensions.kafkaproducer.transactionalID", "pipeline-1")
+				c := sarama.NewConfig()
+				producer, err := NewSyncProducer(config, logger, c, mockProducer)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(producer.kafkaConfig.Producer.Idempotent).To(BeTrue())
+				Expect(c.Producer.Idempotent).To(BeFalse())
+				Expect(c.Producer.Transaction.ID).To(BeEmpty())
+			})
+
+			It("should not share the tls config and slices of the caller's sarama config", func() {
+				c := sarama.NewConfig()
+				c.Net.TLS.Config = &tls.Config{ServerName: "kafka"}
+				producer, err := NewSyncProducer(config, logger, c, mockProducer)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(producer.kafkaConfig.Net.TLS.Config).To(Equal(c.Net.TLS.Config))
+				Expect(producer.kafkaConfig.Net.TLS.Config).NotTo(BeIdenticalTo(c.Net.TLS.Config))
+				producer.kafkaConfig.Consumer.Group.Rebalance.GroupStrategies[0] = sarama.BalanceStrategySticky
+				Expect(c.Consumer.Group.Rebalance.GroupStrategies[0]).To(Equal(sarama.BalanceStrategyRange))
+				Expect(producer.kafkaConfig.MetricRegistry).To(BeIdenticalTo(c.MetricRegistry))
+			})
+
+			It("should use the default sarama config if none is given", func() {
+				config.Set("extensions.kafkaproducer.idempotent", true)
+				producer, err := NewSyncProducer(config, logger, nil, mockProducer)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(producer.kafkaConfig.Producer.Idempotent).To(BeTrue())
+				Expect(producer.kafkaConfig.Validate()).To(Succeed())
+			})
+		})
+
+		Describe("Transaction", func() {
+			var producer *SyncProducer
+
+			BeforeEach(func() {
+				var err error
+				producer, err = NewSyncProducer(config, logger, sarama.NewConfig(), mockProducer)
+				Expect(err).NotTo(HaveOccurred())
+				mockProducer.EXPECT().IsTransactional().Return(true).AnyTimes()
+			})
+
+			It("should commit if fn succeeds", func() {
+				gomock.InOrder(
+					mockProducer.EXPECT().BeginTxn().Return(nil),
+					mockProducer.EXPECT().SendMessage(gomock.Any()).Return(int32(0), int64(1), nil),
+					mockProducer.EXPECT().CommitTxn().Return(nil),
+				)
+				err := producer.Transaction(func() error {
+					_, _, err := producer.ProduceMessage("topic", nil, []byte("message"), nil)
+					return err
+				})
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should abort if fn fails", func() {
+				gomock.InOrder(
+					mockProducer.EXPECT().BeginTxn().Return(nil),
+					mockProducer.EXPECT().AbortTxn().Return(nil),
+				)
+				err := producer.Transaction(func() error {
+					return fmt.Errorf("failed")
+				})
+				Expect(err).To(MatchError("failed"))
+			})
+
+			It("should abort if the commit fails", func() {
+				gomock.InOrder(
+					mockProducer.EXPECT().BeginTxn().Return(nil),
+					mockProducer.EXPECT().CommitTxn().Return(fmt.Errorf("commit failed")),
+					mockProducer.EXPECT().AbortTxn().Return(nil),
+				)
+				err := producer.Transaction(func() error {
+					return nil
+				})
+				Expect(err).To(MatchError("commit failed"))
+			})
+
+			It("should run concurrent transactions one at a time", func() {
+				gomock.InOrder(
+					mockProducer.EXPECT().BeginTxn().Return(nil),
+					mockProducer.EXPECT().CommitTxn().Return(nil),
+					mockProducer.EXPECT().BeginTxn().Return(nil),
+					mockProducer.EXPECT().CommitTxn().Return(nil),
+				)
+				started := make(chan bool)
+				release := make(chan bool)
+				first := make(chan error, 1)
+				go func() {
+					first <- producer.Transaction(func() error {
+						close(started)
+						<-release
+						return nil
+					})
+				}()
+				Eventually(started).Should(BeClosed())
+
+				secondRan := make(chan bool, 1)
+				second := make(chan error, 1)
+				go func() {
+					second <- producer.Transaction(func() error {
+						secondRan <- true
+						return nil
+					})
+				}()
+				Consistently(secondRan).ShouldNot(Receive())
+
+				close(release)
+				Eventually(first).Should(Receive(BeNil()))
+				Eventually(second).Should(Receive(BeNil()))
+				Expect(secondRan).To(Receive())
+			})
+
+			It("should fail if the producer is not transactional", func() {
+				producer, err := NewSyncProducer(config, logger, sarama.NewConfig(), mocks.NewMockSyncProducer(mockCtrl))
+				Expect(err).NotTo(HaveOccurred())
+				producer.Producer.(*mocks.MockSyncProducer).EXPECT().IsTransactional().Return(false)
+				Expect(producer.BeginTransaction()).To(Equal(ErrNotTransactional))
+			})
+		})
+
+		Describe("ConsumeTransformProduce", func() {
+			var producer *SyncProducer
+			var consumer *Consumer
+			var message *Message
+			topic := "com.games.input"
+
+			BeforeEach(func() {
+				var err error
+				producer, err = NewSyncProducer(config, logger, sarama.NewConfig(), mockProducer)
+				Expect(err).NotTo(HaveOccurred())
+				mockProducer.EXPECT().IsTransactional().Return(true).AnyTimes()
+
+				consumerConfig := viper.New()
+				consumerConfig.Set("extensions.kafkaconsumer.manualCommit", true)
+				consumerConfig.Set("extensions.kafkaconsumer.group", "pipeline")
+				consumer, err = NewConsumer(consumerConfig, logger, mocks.NewConsumerClientMock())
+				Expect(err).NotTo(HaveOccurred())
+				consumer.receiveMessage(&kafka.Message{
+					TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 2, Offset: 41},
+					Value:          []byte("input"),
+				}, nil)
+				message = <-*consumer.MessageHandlesChannel()
+			})
+
+			transform := func(m *Message) ([]*Record, error) {
+				return []*Record{{Topic: "com.games.output", Value: append([]byte("transformed "), m.Value...)}}, nil
+			}
+
+			It("should produce the records and commit the offset in the transaction", func() {
+				var sent *sarama.ProducerMessage
+				gomock.InOrder(
+					mockProducer.EXPECT().BeginTxn().Return(nil),
+					mockProducer.EXPECT().SendMessage(gomock.Any()).DoAndReturn(func(m *sarama.ProducerMessage) (int32, int64, error) {
+						sent = m
+						return 0, 0, nil
+					}),
+					mockProducer.EXPECT().AddOffsetsToTxn(map[string][]*sarama.PartitionOffsetMetadata{
+						topic: {{Partition: 2, Offset: 42}},
+					}, "pipeline").Return(nil),
+					mockProducer.EXPECT().CommitTxn().Return(nil),
+				)
+				err := producer.ConsumeTransformProduce(context.Background(), consumer, message, transform)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(sent.Topic).To(Equal("com.games.output"))
+				Expect(sent.Value).To(Equal(sarama.ByteEncoder("transformed input")))
+				Expect(consumer.offsets.committable()).To(BeEmpty())
+			})
+
+			It("should only commit offsets after every previous message is acknowledged", func() {
+				consumer.receiveMessage(&kafka.Message{
+					TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 2, Offset: 42},
+					Value:          []byte("next"),
+				}, nil)
+				next := <-*consumer.MessageHandlesChannel()
+
+				gomock.InOrder(
+					mockProducer.EXPECT().BeginTxn().Return(nil),
+					mockProducer.EXPECT().SendMessage(gomock.Any()).Return(int32(0), int64(0), nil),
+					mockProducer.EXPECT().CommitTxn().Return(nil),
+					mockProducer.EXPECT().BeginTxn().Return(nil),
+					mockProducer.EXPECT().SendMessage(gomock.Any()).Return(int32(0), int64(0), nil),
+					mockProducer.EXPECT().AddOffsetsToTxn(map[string][]*sarama.PartitionOffsetMetadata{
+						topic: {{Partition: 2, Offset: 43}},
+					}, "pipeline").Return(nil),
+					mockProducer.EXPECT().CommitTxn().Return(nil),
+				)
+				Expect(producer.ConsumeTransformProduce(context.Background(), consumer, next, transform)).To(Succeed())
+				Expect(consumer.offsets.committable()).To(BeEmpty())
+				Expect(producer.ConsumeTransformProduce(context.Background(), consumer, message, transform)).To(Succeed())
+				Expect(consumer.offsets.committable()).To(BeEmpty())
+			})
+
+			It("should abort and not acknowledge the message if producing fails", func() {
+				gomock.InOrder(
+					mockProducer.EXPECT().BeginTxn().Return(nil),
+					mockProducer.EXPECT().SendMessage(gomock.Any()).Return(int32(0), int64(0), fmt.Errorf("failed")),
+					mockProducer.EXPECT().AbortTxn().Return(nil),
+				)
+				err := producer.ConsumeTransformProduce(context.Background(), consumer, message, transform)
+				Expect(err).To(MatchError("failed"))
+				Expect(consumer.offsets.committable()).To(BeEmpty())
+			})
+
+			It("should require a consumer in manual commit mode", func() {
+				consumer.ManualCommit = false
+				err := producer.ConsumeTransformProduce(context.Background(), consumer, message, transform)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+})
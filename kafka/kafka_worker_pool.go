@@ -0,0 +1,205 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Message ordering strategies of the WorkerPool
+const (
+	OrderByKey       = "key"
+	OrderByPartition = "partition"
+)
+
+// WorkerPool processes the messages of a Consumer with a fixed number of workers.
+// Messages with the same key, or from the same partition, are always processed by the
+// same worker in the order they were consumed, while different keys run in parallel
+type WorkerPool struct {
+	Config      *viper.Viper
+	Consumer    *Consumer
+	Handler     MessageHandler
+	Logger      *logrus.Logger
+	OrderBy     string
+	Workers     int
+	workerChans []chan *Message
+}
+
+// NewWorkerPool for creating a new WorkerPool instance
+func NewWorkerPool(
+	config *viper.Viper,
+	logger *logrus.Logger,
+	consumer *Consumer,
+	handler MessageHandler,
+) (*WorkerPool, error) {
+	return NewWorkerPoolWithPrefix(config, logger, "extensions.kafkaconsumer.pool", consumer, handler)
+}
+
+// NewWorkerPoolWithPrefix for creating a new WorkerPool instance
+func NewWorkerPoolWithPrefix(
+	config *viper.Viper,
+	logger *logrus.Logger,
+	prefix string,
+	consumer *Consumer,
+	handler MessageHandler,
+) (*WorkerPool, error) {
+	if prefix != "" {
+		prefix += "."
+	}
+	p := &WorkerPool{
+		Config:   config,
+		Consumer: consumer,
+		Handler:  handler,
+		Logger:   logger,
+	}
+	err := p.configure(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *WorkerPool) loadConfigurationDefaults(prefix string) {
+	p.Config.SetDefault(prefix+"workers", 10)
+	p.Config.SetDefault(prefix+"orderBy", OrderByKey)
+}
+
+func (p *WorkerPool) configure(prefix string) error {
+	p.loadConfigurationDefaults(prefix)
+	p.Workers = p.Config.GetInt(prefix + "workers")
+	p.OrderBy = p.Config.GetString(prefix + "orderBy")
+
+	if p.Workers < 1 {
+		return fmt.Errorf("%sworkers must be at least 1", prefix)
+	}
+	if p.OrderBy != OrderByKey && p.OrderBy != OrderByPartition {
+		return fmt.Errorf("%sorderBy must be either %q or %q", prefix, OrderByKey, OrderByPartition)
+	}
+	if !p.Consumer.MessageHandles {
+		return fmt.Errorf("the consumer must deliver message handles to be used by a worker pool")
+	}
+
+	// the consumer channel capacity is split among the workers, so a slow key
+	// eventually blocks the consumer instead of buffering messages indefinitely
+	workerChannelSize := p.Consumer.ChannelSize / p.Workers
+	if workerChannelSize < 1 {
+		workerChannelSize = 1
+	}
+	p.workerChans = make([]chan *Message, p.Workers)
+	for i := range p.workerChans {
+		p.workerChans[i] = make(chan *Message, workerChannelSize)
+	}
+	return nil
+}
+
+// Run dispatches the consumed messages to the workers until ctx is cancelled. Messages
+// are acknowledged once the handler succeeds. Failed messages are not acknowledged, so with
+// manual commit the offset of their partition stops advancing and they are consumed again,
+// along with the later messages of the partition, after the next rebalance or restart.
+// Handlers that should retry or dead-letter failed messages instead must be wrapped with a
+// Retrier. When ctx is cancelled the messages already delivered by the consumer are
+// processed and Run returns once every worker is done. Run must only be called once
+func (p *WorkerPool) Run(ctx context.Context) {
+	l := p.Logger.WithFields(logrus.Fields{
+		"method":  "Run",
+		"workers": p.Workers,
+	})
+
+	var wg sync.WaitGroup
+	for i := range p.workerChans {
+		wg.Add(1)
+		go func(messages chan *Message) {
+			defer wg.Done()
+			p.work(messages)
+		}(p.workerChans[i])
+	}
+	l.Info("worker pool started")
+
+	handles := *p.Consumer.MessageHandlesChannel()
+	func() {
+		for {
+			select {
+			case m := <-handles:
+				p.dispatch(m)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	l.Info("draining worker pool")
+	func() {
+		for {
+			select {
+			case m := <-handles:
+				p.dispatch(m)
+			default:
+				return
+			}
+		}
+	}()
+	for _, messages := range p.workerChans {
+		close(messages)
+	}
+	wg.Wait()
+	l.Info("worker pool stopped")
+}
+
+func (p *WorkerPool) dispatch(m *Message) {
+	p.workerChans[p.workerIndex(m)] <- m
+}
+
+func (p *WorkerPool) workerIndex(m *Message) int {
+	h := fnv.New32a()
+	if p.OrderBy == OrderByKey && len(m.Key) > 0 {
+		h.Write(m.Key)
+	} else {
+		h.Write([]byte(m.Topic))
+		h.Write([]byte(strconv.Itoa(int(m.Partition))))
+	}
+	return int(h.Sum32() % uint32(p.Workers))
+}
+
+func (p *WorkerPool) work(messages chan *Message) {
+	for m := range messages {
+		err := p.Handler(m)
+		if err != nil {
+			p.Logger.WithFields(logrus.Fields{
+				"method":    "work",
+				"topic":     m.Topic,
+				"partition": m.Partition,
+				"offset":    m.Offset,
+			}).WithError(err).Error("error handling message")
+			m.release()
+			continue
+		}
+		m.Ack()
+	}
+}
@@ -0,0 +1,198 @@
+/*
+ * Copyright (c) 2016 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/kafka/mocks"
+)
+
+var _ = Describe("WorkerPool", func() {
+	logger, _ := test.NewNullLogger()
+	var config *viper.Viper
+	var consumer *Consumer
+	topic := "com.games.test"
+
+	deliver := func(partition int32, offset int64, key string) {
+		consumer.receiveMessage(&kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: partition, Offset: kafka.Offset(offset)},
+			Key:            []byte(key),
+			Value:          []byte(fmt.Sprintf("%s-%d", key, offset)),
+		}, nil)
+	}
+
+	BeforeEach(func() {
+		config = viper.New()
+		config.Set("extensions.kafkaconsumer.messageHandles", true)
+		config.Set("extensions.kafkaconsumer.pool.workers", 4)
+		var err error
+		consumer, err = NewConsumer(config, logger, mocks.NewConsumerClientMock())
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("[Unit]", func() {
+		Describe("Configuration", func() {
+			It("should configure defaults", func() {
+				pool, err := NewWorkerPool(viper.New(), logger, consumer, nil)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(pool.Workers).To(Equal(10))
+				Expect(pool.OrderBy).To(Equal(OrderByKey))
+				Expect(pool.workerChans).To(HaveLen(10))
+				Expect(cap(pool.workerChans[0])).To(Equal(10))
+			})
+
+			It("should fail if the consumer does not deliver message handles", func() {
+				consumer, err := NewConsumer(viper.New(), logger, mocks.NewConsumerClientMock())
+				Expect(err).NotTo(HaveOccurred())
+				_, err = NewWorkerPool(config, logger, consumer, nil)
+				Expect(err).To(HaveOccurred())
+			})
+
+			It("should fail with an unknown ordering", func() {
+				config.Set("extensions.kafkaconsumer.pool.orderBy", "value")
+				_, err := NewWorkerPool(config, logger, consumer, nil)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+
+		Describe("Run", func() {
+			It("should process messages with the same key sequentially and in order", func() {
+				var mutex sync.Mutex
+				processed := map[string][]int64{}
+				inFlight := map[string]bool{}
+				pool, err := NewWorkerPool(config, logger, consumer, func(m *Message) error {
+					key := string(m.Key)
+					mutex.Lock()
+					Expect(inFlight[key]).To(BeFalse())
+					inFlight[key] = true
+					mutex.Unlock()
+
+					time.Sleep(time.Millisecond)
+
+					mutex.Lock()
+					inFlight[key] = false
+					processed[key] = append(processed[key], m.Offset)
+					mutex.Unlock()
+					return nil
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				ctx, cancel := context.WithCancel(context.Background())
+				done := make(chan bool)
+				go func() {
+					pool.Run(ctx)
+					close(done)
+				}()
+				for offset := int64(0); offset < 10; offset++ {
+					for _, key := range []string{"a", "b", "c"} {
+						deliver(0, offset, key)
+					}
+				}
+				cancel()
+				Eventually(done).Should(BeClosed())
+
+				expected := []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+				Expect(processed).To(Equal(map[string][]int64{"a": expected, "b": expected, "c": expected}))
+			})
+
+			It("should process different keys in parallel", func() {
+				pool, err := NewWorkerPool(config, logger, consumer, nil)
+				Expect(err).NotTo(HaveOccurred())
+				keys := []string{"a"}
+				for i := 0; len(keys) < 2; i++ {
+					key := fmt.Sprintf("key-%d", i)
+					if pool.workerIndex(&Message{Key: []byte(key)}) != pool.workerIndex(&Message{Key: []byte(keys[0])}) {
+						keys = append(keys, key)
+					}
+				}
+
+				unblock := make(chan bool)
+				pool.Handler = func(m *Message) error {
+					if string(m.Key) == keys[0] {
+						<-unblock
+					} else {
+						close(unblock)
+					}
+					return nil
+				}
+
+				ctx, cancel := context.WithCancel(context.Background())
+				done := make(chan bool)
+				go func() {
+					pool.Run(ctx)
+					close(done)
+				}()
+				deliver(0, 0, keys[0])
+				deliver(0, 1, keys[1])
+				Eventually(unblock).Should(BeClosed())
+				cancel()
+				Eventually(done).Should(BeClosed())
+			})
+
+			It("should route messages by partition", func() {
+				config.Set("extensions.kafkaconsumer.pool.orderBy", OrderByPartition)
+				pool, err := NewWorkerPool(config, logger, consumer, nil)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(pool.workerIndex(&Message{Topic: topic, Partition: 1, Key: []byte("a")})).To(
+					Equal(pool.workerIndex(&Message{Topic: topic, Partition: 1, Key: []byte("b")})))
+			})
+
+			It("should not acknowledge messages if the handler fails", func() {
+				config.Set("extensions.kafkaconsumer.manualCommit", true)
+				var err error
+				consumer, err = NewConsumer(config, logger, mocks.NewConsumerClientMock())
+				Expect(err).NotTo(HaveOccurred())
+				pool, err := NewWorkerPool(config, logger, consumer, func(m *Message) error {
+					if m.Offset == 0 {
+						return fmt.Errorf("failed")
+					}
+					return nil
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				deliver(0, 0, "a")
+				deliver(0, 1, "a")
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				pool.Run(ctx)
+				Expect(consumer.offsets.committable()).To(BeEmpty())
+
+				done := make(chan bool)
+				go func() {
+					consumer.PendingMessagesWaitGroup().Wait()
+					close(done)
+				}()
+				Eventually(done).Should(BeClosed())
+			})
+		})
+	})
+})
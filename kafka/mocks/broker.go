@@ -0,0 +1,595 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package mocks
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// BrokerMessage is a message stored by the Broker
+type BrokerMessage struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   map[string][]byte
+	Timestamp time.Time
+}
+
+func (m *BrokerMessage) kafkaHeaders() []kafka.Header {
+	keys := make([]string, 0, len(m.Headers))
+	for key := range m.Headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	headers := make([]kafka.Header, 0, len(keys))
+	for _, key := range keys {
+		headers = append(headers, kafka.Header{Key: key, Value: m.Headers[key]})
+	}
+	return headers
+}
+
+// Broker is an in-memory kafka broker meant for tests. It stores the messages of every
+// topic partition and the offsets committed by consumer groups, and rebalances the
+// partitions of a group whenever a consumer joins or leaves it.
+// Topics are created with DefaultPartitions partitions the first time they are used
+type Broker struct {
+	DefaultPartitions int
+
+	mutex  sync.Mutex
+	topics map[string][][]*BrokerMessage
+	groups map[string]*brokerGroup
+	next   map[string]int
+}
+
+type brokerGroup struct {
+	members   []*BrokerConsumer
+	committed map[string]map[int32]int64
+}
+
+// NewBroker creates a new in-memory Broker
+func NewBroker(defaultPartitions int) *Broker {
+	if defaultPartitions < 1 {
+		defaultPartitions = 1
+	}
+	return &Broker{
+		DefaultPartitions: defaultPartitions,
+		topics:            map[string][][]*BrokerMessage{},
+		groups:            map[string]*brokerGroup{},
+		next:              map[string]int{},
+	}
+}
+
+// CreateTopic creates topic with the given number of partitions if it does not exist
+func (b *Broker) CreateTopic(topic string, partitions int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.createTopic(topic, partitions)
+}
+
+func (b *Broker) createTopic(topic string, partitions int) [][]*BrokerMessage {
+	if log, ok := b.topics[topic]; ok {
+		return log
+	}
+	log := make([][]*BrokerMessage, partitions)
+	b.topics[topic] = log
+	return log
+}
+
+// Messages returns every message stored in topic, ordered by partition and offset
+func (b *Broker) Messages(topic string) []*BrokerMessage {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	messages := []*BrokerMessage{}
+	for _, partition := range b.topics[topic] {
+		messages = append(messages, partition...)
+	}
+	return messages
+}
+
+// CommittedOffset returns the offset committed by group for a partition, or
+// kafka.OffsetInvalid if no offset was committed
+func (b *Broker) CommittedOffset(group, topic string, partition int32) kafka.Offset {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	g, ok := b.groups[group]
+	if !ok {
+		return kafka.OffsetInvalid
+	}
+	offset, ok := g.committed[topic][partition]
+	if !ok {
+		return kafka.OffsetInvalid
+	}
+	return kafka.Offset(offset)
+}
+
+// produce stores a message and returns its partition and offset, partition may be
+// kafka.PartitionAny to pick one by hashing the key, or round robin when there is no key
+func (b *Broker) produce(topic string, partition int32, key, value []byte, headers map[string][]byte) (int32, int64, error) {
+	log := b.createTopic(topic, b.DefaultPartitions)
+	if partition == kafka.PartitionAny {
+		if len(key) > 0 {
+			h := fnv.New32a()
+			h.Write(key)
+			partition = int32(h.Sum32() % uint32(len(log)))
+		} else {
+			partition = int32(b.next[topic] % len(log))
+			b.next[topic]++
+		}
+	}
+	if partition < 0 || int(partition) >= len(log) {
+		return partition, -1, fmt.Errorf("mocks: unknown partition %d of %s", partition, topic)
+	}
+	offset := int64(len(log[partition]))
+	log[partition] = append(log[partition], &BrokerMessage{
+		Topic:     topic,
+		Partition: partition,
+		Offset:    offset,
+		Key:       key,
+		Value:     value,
+		Headers:   headers,
+		Timestamp: time.Now(),
+	})
+	b.notifyConsumers()
+	return partition, offset, nil
+}
+
+func (b *Broker) commit(group string, offsets []kafka.TopicPartition) {
+	g := b.group(group)
+	for _, tp := range offsets {
+		if tp.Topic == nil || tp.Offset < 0 {
+			continue
+		}
+		if g.committed[*tp.Topic] == nil {
+			g.committed[*tp.Topic] = map[int32]int64{}
+		}
+		g.committed[*tp.Topic][tp.Partition] = int64(tp.Offset)
+	}
+}
+
+func (b *Broker) group(name string) *brokerGroup {
+	g, ok := b.groups[name]
+	if !ok {
+		g = &brokerGroup{committed: map[string]map[int32]int64{}}
+		b.groups[name] = g
+	}
+	return g
+}
+
+func (b *Broker) notifyConsumers() {
+	for _, g := range b.groups {
+		for _, member := range g.members {
+			member.cond.Broadcast()
+		}
+	}
+}
+
+// rebalance spreads the partitions of the topics subscribed by the group members among
+// them, round robin, sending the revoked and assigned partitions to each member
+func (b *Broker) rebalance(g *brokerGroup) {
+	assignments := make(map[*BrokerConsumer][]kafka.TopicPartition, len(g.members))
+	topics := []string{}
+	for _, member := range g.members {
+		for _, topic := range member.topics {
+			b.createTopic(topic, b.DefaultPartitions)
+			topics = appendMissing(topics, topic)
+		}
+	}
+	sort.Strings(topics)
+	for _, topic := range topics {
+		subscribers := []*BrokerConsumer{}
+		for _, member := range g.members {
+			if member.subscribed(topic) {
+				subscribers = append(subscribers, member)
+			}
+		}
+		for partition := range b.topics[topic] {
+			member := subscribers[partition%len(subscribers)]
+			t := topic
+			assignments[member] = append(assignments[member], kafka.TopicPartition{
+				Topic:     &t,
+				Partition: int32(partition),
+				Offset:    kafka.OffsetInvalid,
+			})
+		}
+	}
+	for _, member := range g.members {
+		if member.generation > 0 {
+			member.pending = append(member.pending, kafka.RevokedPartitions{Partitions: member.assignedPartitions()})
+		}
+		member.generation++
+		member.pending = append(member.pending, kafka.AssignedPartitions{Partitions: assignments[member]})
+		member.cond.Broadcast()
+	}
+}
+
+func appendMissing(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// ErrConsumerClosed is returned when using a BrokerConsumer after Close
+var ErrConsumerClosed = errors.New("mocks: consumer closed")
+
+// BrokerConsumer is a consumer of a Broker implementing interfaces.KafkaConsumerClient.
+// Assigned partitions without a committed offset are consumed from the beginning.
+// When AutoCommit is set the offset of every message is committed once it is delivered
+type BrokerConsumer struct {
+	AutoCommit bool
+
+	broker     *Broker
+	group      string
+	topics     []string
+	events     chan kafka.Event
+	cond       *sync.Cond
+	pending    []kafka.Event
+	positions  map[string]map[int32]int64
+	paused     map[string]map[int32]bool
+	generation int
+	closed     bool
+	done       chan struct{}
+}
+
+// NewConsumer creates a consumer of the broker belonging to group
+func (b *Broker) NewConsumer(group string) *BrokerConsumer {
+	c := &BrokerConsumer{
+		broker:    b,
+		group:     group,
+		events:    make(chan kafka.Event),
+		cond:      sync.NewCond(&b.mutex),
+		positions: map[string]map[int32]int64{},
+		paused:    map[string]map[int32]bool{},
+		done:      make(chan struct{}),
+	}
+	go c.dispatch()
+	return c
+}
+
+// SubscribeTopics joins the consumer group, rebalancing its partitions
+func (c *BrokerConsumer) SubscribeTopics(topics []string, callback kafka.RebalanceCb) error {
+	c.broker.mutex.Lock()
+	defer c.broker.mutex.Unlock()
+	if c.closed {
+		return ErrConsumerClosed
+	}
+	c.topics = topics
+	g := c.broker.group(c.group)
+	joined := false
+	for _, member := range g.members {
+		joined = joined || member == c
+	}
+	if !joined {
+		g.members = append(g.members, c)
+	}
+	c.broker.rebalance(g)
+	return nil
+}
+
+// Events returns the channel delivering rebalances and messages
+func (c *BrokerConsumer) Events() chan kafka.Event {
+	return c.events
+}
+
+// Assign starts consuming partitions from their offset, or from the offset committed
+// by the consumer group when it is not set
+func (c *BrokerConsumer) Assign(partitions []kafka.TopicPartition) error {
+	c.broker.mutex.Lock()
+	defer c.broker.mutex.Unlock()
+	if c.closed {
+		return ErrConsumerClosed
+	}
+	committed := c.broker.group(c.group).committed
+	c.positions = map[string]map[int32]int64{}
+	c.paused = map[string]map[int32]bool{}
+	for _, tp := range partitions {
+		if tp.Topic == nil {
+			continue
+		}
+		position := int64(tp.Offset)
+		if position < 0 {
+			position = 0
+			if offset, ok := committed[*tp.Topic][tp.Partition]; ok {
+				position = offset
+			}
+		}
+		if c.positions[*tp.Topic] == nil {
+			c.positions[*tp.Topic] = map[int32]int64{}
+		}
+		c.positions[*tp.Topic][tp.Partition] = position
+	}
+	c.cond.Broadcast()
+	return nil
+}
+
+// Unassign stops consuming every partition
+func (c *BrokerConsumer) Unassign() error {
+	c.broker.mutex.Lock()
+	defer c.broker.mutex.Unlock()
+	c.positions = map[string]map[int32]int64{}
+	c.paused = map[string]map[int32]bool{}
+	return nil
+}
+
+// Pause stops delivering messages of partitions until they are resumed
+func (c *BrokerConsumer) Pause(partitions []kafka.TopicPartition) error {
+	return c.setPaused(partitions, true)
+}
+
+// Resume delivers messages of paused partitions again, from where they were paused
+func (c *BrokerConsumer) Resume(partitions []kafka.TopicPartition) error {
+	return c.setPaused(partitions, false)
+}
+
+func (c *BrokerConsumer) setPaused(partitions []kafka.TopicPartition, paused bool) error {
+	c.broker.mutex.Lock()
+	defer c.broker.mutex.Unlock()
+	if c.closed {
+		return ErrConsumerClosed
+	}
+	for _, tp := range partitions {
+		if tp.Topic == nil {
+			continue
+		}
+		if c.paused[*tp.Topic] == nil {
+			c.paused[*tp.Topic] = map[int32]bool{}
+		}
+		c.paused[*tp.Topic][tp.Partition] = paused
+	}
+	c.cond.Broadcast()
+	return nil
+}
+
+// CommitOffsets commits offsets for the consumer group
+func (c *BrokerConsumer) CommitOffsets(offsets []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+	c.broker.mutex.Lock()
+	defer c.broker.mutex.Unlock()
+	if c.closed {
+		return nil, ErrConsumerClosed
+	}
+	c.broker.commit(c.group, offsets)
+	return offsets, nil
+}
+
+// QueryWatermarkOffsets returns the first offset and the offset after the last message of a partition
+func (c *BrokerConsumer) QueryWatermarkOffsets(topic string, partition int32, timeoutMs int) (int64, int64, error) {
+	c.broker.mutex.Lock()
+	defer c.broker.mutex.Unlock()
+	log, ok := c.broker.topics[topic]
+	if !ok || int(partition) >= len(log) {
+		return 0, 0, fmt.Errorf("mocks: unknown partition %d of %s", partition, topic)
+	}
+	return 0, int64(len(log[partition])), nil
+}
+
+// Committed returns the offsets committed by the consumer group for partitions,
+// kafka.OffsetInvalid for partitions without a committed offset
+func (c *BrokerConsumer) Committed(partitions []kafka.TopicPartition, timeoutMs int) ([]kafka.TopicPartition, error) {
+	committed := make([]kafka.TopicPartition, 0, len(partitions))
+	for _, tp := range partitions {
+		if tp.Topic != nil {
+			tp.Offset = c.broker.CommittedOffset(c.group, *tp.Topic, tp.Partition)
+		}
+		committed = append(committed, tp)
+	}
+	return committed, nil
+}
+
+// Close leaves the consumer group, rebalancing its partitions among the remaining members
+func (c *BrokerConsumer) Close() error {
+	c.broker.mutex.Lock()
+	defer c.broker.mutex.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	close(c.done)
+	c.cond.Broadcast()
+	g := c.broker.group(c.group)
+	for i, member := range g.members {
+		if member == c {
+			g.members = append(g.members[:i], g.members[i+1:]...)
+			if len(g.members) > 0 {
+				c.broker.rebalance(g)
+			}
+			break
+		}
+	}
+	return nil
+}
+
+func (c *BrokerConsumer) subscribed(topic string) bool {
+	for _, t := range c.topics {
+		if t == topic {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *BrokerConsumer) assignedPartitions() []kafka.TopicPartition {
+	partitions := []kafka.TopicPartition{}
+	for topic, positions := range c.positions {
+		for partition := range positions {
+			t := topic
+			partitions = append(partitions, kafka.TopicPartition{Topic: &t, Partition: partition, Offset: kafka.OffsetInvalid})
+		}
+	}
+	return partitions
+}
+
+// nextEvent returns the next pending rebalance or the next message of an assigned
+// partition, advancing its position. It must be called with the broker mutex held
+func (c *BrokerConsumer) nextEvent() kafka.Event {
+	if len(c.pending) > 0 {
+		ev := c.pending[0]
+		c.pending = c.pending[1:]
+		return ev
+	}
+	topics := make([]string, 0, len(c.positions))
+	for topic := range c.positions {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	for _, topic := range topics {
+		log := c.broker.topics[topic]
+		for partition := int32(0); int(partition) < len(log); partition++ {
+			position, ok := c.positions[topic][partition]
+			if !ok || c.paused[topic][partition] || position >= int64(len(log[partition])) {
+				continue
+			}
+			stored := log[partition][position]
+			c.positions[topic][partition] = position + 1
+			if c.AutoCommit {
+				t := topic
+				c.broker.commit(c.group, []kafka.TopicPartition{{Topic: &t, Partition: partition, Offset: kafka.Offset(position + 1)}})
+			}
+			t := topic
+			return &kafka.Message{
+				TopicPartition: kafka.TopicPartition{Topic: &t, Partition: partition, Offset: kafka.Offset(stored.Offset)},
+				Key:            stored.Key,
+				Value:          stored.Value,
+				Headers:        stored.kafkaHeaders(),
+				Timestamp:      stored.Timestamp,
+				TimestampType:  kafka.TimestampCreateTime,
+			}
+		}
+	}
+	return nil
+}
+
+func (c *BrokerConsumer) dispatch() {
+	for {
+		c.broker.mutex.Lock()
+		ev := c.nextEvent()
+		for ev == nil && !c.closed {
+			c.cond.Wait()
+			ev = c.nextEvent()
+		}
+		c.broker.mutex.Unlock()
+		if ev == nil {
+			return
+		}
+		select {
+		case c.events <- ev:
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// BrokerProducer is a producer of a Broker implementing interfaces.KafkaProducerClient,
+// delivery reports are sent to the events channel, which must be consumed
+type BrokerProducer struct {
+	broker  *Broker
+	events  chan kafka.Event
+	produce chan *kafka.Message
+	done    chan struct{}
+}
+
+// NewProducer creates a producer of the broker
+func (b *Broker) NewProducer() *BrokerProducer {
+	p := &BrokerProducer{
+		broker:  b,
+		events:  make(chan kafka.Event, 100),
+		produce: make(chan *kafka.Message, 100),
+		done:    make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// flushMarker is sent through the produce channel by Flush, its Opaque channel is
+// closed once every message sent before it was stored
+type flushMarker chan struct{}
+
+func (p *BrokerProducer) run() {
+	defer close(p.done)
+	for m := range p.produce {
+		if marker, ok := m.Opaque.(flushMarker); ok && m.TopicPartition.Topic == nil {
+			close(marker)
+			continue
+		}
+		topic := ""
+		if m.TopicPartition.Topic != nil {
+			topic = *m.TopicPartition.Topic
+		}
+		p.broker.mutex.Lock()
+		headers := make(map[string][]byte, len(m.Headers))
+		for _, header := range m.Headers {
+			headers[header.Key] = header.Value
+		}
+		partition, offset, err := p.broker.produce(topic, m.TopicPartition.Partition, m.Key, m.Value, headers)
+		p.broker.mutex.Unlock()
+		m.TopicPartition.Partition = partition
+		m.TopicPartition.Offset = kafka.Offset(offset)
+		m.TopicPartition.Error = err
+		p.events <- m
+	}
+}
+
+// Events returns the channel delivering the delivery reports
+func (p *BrokerProducer) Events() chan kafka.Event {
+	return p.events
+}
+
+// ProduceChannel returns the channel messages are produced to
+func (p *BrokerProducer) ProduceChannel() chan *kafka.Message {
+	return p.produce
+}
+
+// Flush waits up to timeoutMs for every message sent to the produce channel to be stored,
+// returning the number of messages still waiting
+func (p *BrokerProducer) Flush(timeoutMs int) int {
+	timeout := time.After(time.Duration(timeoutMs) * time.Millisecond)
+	marker := make(flushMarker)
+	select {
+	case p.produce <- &kafka.Message{Opaque: marker}:
+	case <-timeout:
+		return len(p.produce)
+	}
+	select {
+	case <-marker:
+		return 0
+	case <-timeout:
+		return len(p.produce)
+	}
+}
+
+// Close stops the producer, closing the events channel once every message was stored
+func (p *BrokerProducer) Close() {
+	close(p.produce)
+	<-p.done
+	close(p.events)
+}
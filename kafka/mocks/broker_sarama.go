@@ -0,0 +1,202 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package mocks
+
+import (
+	"errors"
+
+	"github.com/Shopify/sarama"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// ErrNoTransaction is returned when ending a transaction that was not started
+var ErrNoTransaction = errors.New("mocks: no transaction in progress")
+
+// BrokerSyncProducer is a producer of a Broker implementing sarama.SyncProducer. When
+// Transactional is set, messages and offsets sent during a transaction are only
+// stored in the broker once the transaction is committed. Partitions are picked like
+// sarama's default hash partitioner does, by hashing the key or round robin
+type BrokerSyncProducer struct {
+	Transactional bool
+
+	broker  *Broker
+	inTxn   bool
+	txn     []*sarama.ProducerMessage
+	offsets map[string][]kafka.TopicPartition
+}
+
+// NewSyncProducer creates a sarama sync producer of the broker
+func (b *Broker) NewSyncProducer(transactional bool) *BrokerSyncProducer {
+	return &BrokerSyncProducer{
+		Transactional: transactional,
+		broker:        b,
+	}
+}
+
+// SendMessage stores msg, setting its partition and offset. Inside a transaction the
+// message is only stored on commit and the returned offset is -1
+func (p *BrokerSyncProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	p.broker.mutex.Lock()
+	defer p.broker.mutex.Unlock()
+	if p.inTxn {
+		p.txn = append(p.txn, msg)
+		return msg.Partition, -1, nil
+	}
+	return p.store(msg)
+}
+
+// SendMessages stores every message of msgs
+func (p *BrokerSyncProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	for _, msg := range msgs {
+		_, _, err := p.SendMessage(msg)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *BrokerSyncProducer) store(msg *sarama.ProducerMessage) (int32, int64, error) {
+	key, err := encode(msg.Key)
+	if err != nil {
+		return -1, -1, err
+	}
+	value, err := encode(msg.Value)
+	if err != nil {
+		return -1, -1, err
+	}
+	headers := make(map[string][]byte, len(msg.Headers))
+	for _, header := range msg.Headers {
+		headers[string(header.Key)] = header.Value
+	}
+	partition, offset, err := p.broker.produce(msg.Topic, kafka.PartitionAny, key, value, headers)
+	if err != nil {
+		return -1, -1, err
+	}
+	msg.Partition = partition
+	msg.Offset = offset
+	return partition, offset, nil
+}
+
+func encode(encoder sarama.Encoder) ([]byte, error) {
+	if encoder == nil {
+		return nil, nil
+	}
+	return encoder.Encode()
+}
+
+// Close does nothing
+func (p *BrokerSyncProducer) Close() error {
+	return nil
+}
+
+// TxnStatus returns whether a transaction is in progress
+func (p *BrokerSyncProducer) TxnStatus() sarama.ProducerTxnStatusFlag {
+	p.broker.mutex.Lock()
+	defer p.broker.mutex.Unlock()
+	if p.inTxn {
+		return sarama.ProducerTxnFlagInTransaction
+	}
+	return sarama.ProducerTxnFlagReady
+}
+
+// IsTransactional returns the Transactional setting
+func (p *BrokerSyncProducer) IsTransactional() bool {
+	return p.Transactional
+}
+
+// BeginTxn starts a transaction
+func (p *BrokerSyncProducer) BeginTxn() error {
+	p.broker.mutex.Lock()
+	defer p.broker.mutex.Unlock()
+	if !p.Transactional {
+		return sarama.ErrNonTransactedProducer
+	}
+	if p.inTxn {
+		return sarama.ErrTransactionNotReady
+	}
+	p.inTxn = true
+	p.txn = nil
+	p.offsets = map[string][]kafka.TopicPartition{}
+	return nil
+}
+
+// CommitTxn stores the messages and commits the offsets of the transaction
+func (p *BrokerSyncProducer) CommitTxn() error {
+	p.broker.mutex.Lock()
+	defer p.broker.mutex.Unlock()
+	if !p.inTxn {
+		return ErrNoTransaction
+	}
+	for _, msg := range p.txn {
+		_, _, err := p.store(msg)
+		if err != nil {
+			return err
+		}
+	}
+	for group, offsets := range p.offsets {
+		p.broker.commit(group, offsets)
+	}
+	p.inTxn = false
+	return nil
+}
+
+// AbortTxn discards the messages and offsets of the transaction
+func (p *BrokerSyncProducer) AbortTxn() error {
+	p.broker.mutex.Lock()
+	defer p.broker.mutex.Unlock()
+	if !p.inTxn {
+		return ErrNoTransaction
+	}
+	p.inTxn = false
+	p.txn = nil
+	p.offsets = nil
+	return nil
+}
+
+// AddOffsetsToTxn commits offsets for groupId when the transaction is committed
+func (p *BrokerSyncProducer) AddOffsetsToTxn(offsets map[string][]*sarama.PartitionOffsetMetadata, groupId string) error {
+	p.broker.mutex.Lock()
+	defer p.broker.mutex.Unlock()
+	if !p.inTxn {
+		return ErrNoTransaction
+	}
+	for topic, partitions := range offsets {
+		for _, partition := range partitions {
+			t := topic
+			p.offsets[groupId] = append(p.offsets[groupId], kafka.TopicPartition{
+				Topic:     &t,
+				Partition: partition.Partition,
+				Offset:    kafka.Offset(partition.Offset),
+			})
+		}
+	}
+	return nil
+}
+
+// AddMessageToTxn commits the offset after msg for groupId when the transaction is committed
+func (p *BrokerSyncProducer) AddMessageToTxn(msg *sarama.ConsumerMessage, groupId string, metadata *string) error {
+	return p.AddOffsetsToTxn(map[string][]*sarama.PartitionOffsetMetadata{
+		msg.Topic: {{Partition: msg.Partition, Offset: msg.Offset + 1, Metadata: metadata}},
+	}, groupId)
+}
@@ -29,6 +29,8 @@ type ProducerClientMock struct {
 	EventsChan   chan kafka.Event
 	ProduceChan  chan *kafka.Message
 	SentMessages int
+	Closed       bool
+	consumeDone  chan bool
 }
 
 // MockEvent implements kafka.Event
@@ -53,7 +55,9 @@ func NewProducerClientMock() *ProducerClientMock {
 
 // StartConsumingMessagesInProduceChannel starts to consume messages in produce channel and incrementing sentMessages
 func (k *ProducerClientMock) StartConsumingMessagesInProduceChannel() {
+	k.consumeDone = make(chan bool)
 	go func() {
+		defer close(k.consumeDone)
 		for msg := range k.ProduceChan {
 			k.SentMessages++
 			k.EventsChan <- msg
@@ -71,11 +75,30 @@ func (k *ProducerClientMock) ProduceChannel() chan *kafka.Message {
 	return k.ProduceChan
 }
 
+// Flush mock
+func (k *ProducerClientMock) Flush(timeoutMs int) int {
+	return len(k.ProduceChan)
+}
+
+// Close mock closes the produce channel and, once every produced message was sent
+// to the events channel, closes the events channel
+func (k *ProducerClientMock) Close() {
+	k.Closed = true
+	close(k.ProduceChan)
+	if k.consumeDone != nil {
+		<-k.consumeDone
+	}
+	close(k.EventsChan)
+}
+
 // ConsumerClientMock  should be used for tests that need to send messages to Kafka
 type ConsumerClientMock struct {
 	SubscribedTopics   map[string]interface{}
 	EventsChan         chan kafka.Event
 	AssignedPartitions []kafka.TopicPartition
+	PausedPartitions   []kafka.TopicPartition
+	CommittedOffsets   []kafka.TopicPartition
+	HighWatermarks     map[string]map[int32]int64
 	Closed             bool
 	Error              error
 }
@@ -90,6 +113,9 @@ func NewConsumerClientMock(errorOrNil ...error) *ConsumerClientMock {
 		SubscribedTopics:   map[string]interface{}{},
 		EventsChan:         make(chan kafka.Event),
 		AssignedPartitions: []kafka.TopicPartition{},
+		PausedPartitions:   []kafka.TopicPartition{},
+		CommittedOffsets:   []kafka.TopicPartition{},
+		HighWatermarks:     map[string]map[int32]int64{},
 		Closed:             false,
 		Error:              err,
 	}
@@ -118,6 +144,7 @@ func (k *ConsumerClientMock) Assign(partitions []kafka.TopicPartition) error {
 		return k.Error
 	}
 	k.AssignedPartitions = partitions
+	k.PausedPartitions = []kafka.TopicPartition{}
 	return nil
 }
 
@@ -127,9 +154,75 @@ func (k *ConsumerClientMock) Unassign() error {
 		return k.Error
 	}
 	k.AssignedPartitions = []kafka.TopicPartition{}
+	k.PausedPartitions = []kafka.TopicPartition{}
+	return nil
+}
+
+//Pause mock adds partitions to PausedPartitions
+func (k *ConsumerClientMock) Pause(partitions []kafka.TopicPartition) error {
+	if k.Error != nil {
+		return k.Error
+	}
+	k.Resume(partitions)
+	k.PausedPartitions = append(k.PausedPartitions, partitions...)
 	return nil
 }
 
+//Resume mock removes partitions from PausedPartitions
+func (k *ConsumerClientMock) Resume(partitions []kafka.TopicPartition) error {
+	if k.Error != nil {
+		return k.Error
+	}
+	paused := []kafka.TopicPartition{}
+	for _, p := range k.PausedPartitions {
+		resumed := false
+		for _, tp := range partitions {
+			resumed = resumed || (p.Topic != nil && tp.Topic != nil && *p.Topic == *tp.Topic && p.Partition == tp.Partition)
+		}
+		if !resumed {
+			paused = append(paused, p)
+		}
+	}
+	k.PausedPartitions = paused
+	return nil
+}
+
+//CommitOffsets mock
+func (k *ConsumerClientMock) CommitOffsets(offsets []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+	if k.Error != nil {
+		return nil, k.Error
+	}
+	k.CommittedOffsets = append(k.CommittedOffsets, offsets...)
+	return offsets, nil
+}
+
+//QueryWatermarkOffsets mock returns the offsets set in HighWatermarks
+func (k *ConsumerClientMock) QueryWatermarkOffsets(topic string, partition int32, timeoutMs int) (int64, int64, error) {
+	if k.Error != nil {
+		return 0, 0, k.Error
+	}
+	return 0, k.HighWatermarks[topic][partition], nil
+}
+
+//Committed mock returns the last offset committed for each partition, or
+//kafka.OffsetInvalid if none was committed
+func (k *ConsumerClientMock) Committed(partitions []kafka.TopicPartition, timeoutMs int) ([]kafka.TopicPartition, error) {
+	if k.Error != nil {
+		return nil, k.Error
+	}
+	committed := make([]kafka.TopicPartition, 0, len(partitions))
+	for _, tp := range partitions {
+		tp.Offset = kafka.OffsetInvalid
+		for _, c := range k.CommittedOffsets {
+			if c.Topic != nil && tp.Topic != nil && *c.Topic == *tp.Topic && c.Partition == tp.Partition {
+				tp.Offset = c.Offset
+			}
+		}
+		committed = append(committed, tp)
+	}
+	return committed, nil
+}
+
 //Close mock
 func (k *ConsumerClientMock) Close() error {
 	if k.Error != nil {
@@ -0,0 +1,250 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+// Package cache implements a read-through and write-through cache on the redis clients,
+// with de-duplication of concurrent misses, negative caching, jittered TTLs, pluggable
+// codecs and an optional in-process LRU tier in front of redis
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/codec"
+)
+
+// ErrNotFound is returned by loaders when the value does not exist. Cache.Get returns it
+// for values that were not found, which are cached for the negative TTL
+var ErrNotFound = errors.New("cache: not found")
+
+// Stored values are prefixed with a flag telling whether the value exists,
+// so that not found values can be cached regardless of the codec
+const (
+	flagNotFound byte = 0
+	flagValue    byte = 1
+)
+
+// Loader loads the value of key from the source of truth on a cache miss.
+// It returns ErrNotFound when there is no value for key
+type Loader func(ctx context.Context, key string) (interface{}, error)
+
+// Options configures a Cache
+type Options struct {
+	// Prefix is prepended to every key stored in redis
+	Prefix string
+	// TTL is how long values are kept in redis
+	TTL time.Duration
+	// NegativeTTL is how long not found values are kept, 0 disables negative caching
+	NegativeTTL time.Duration
+	// Jitter randomly shortens TTLs by up to this fraction, so that keys cached
+	// together do not expire together
+	Jitter float64
+	// LocalSize is the number of values kept in process, 0 disables the local tier
+	LocalSize int
+	// LocalTTL is how long values are kept in process
+	LocalTTL time.Duration
+}
+
+// Cache reads values from an in-process LRU, then from redis and finally from a Loader
+type Cache struct {
+	Options *Options
+	Codec   codec.Codec
+	store   Store
+	local   *lru
+	group   *singleflight
+}
+
+// NewCache creates a Cache with options read from the given config prefix
+func NewCache(prefix string, config *viper.Viper, store Store) (*Cache, error) {
+	loadConfigurationDefaults(prefix, config)
+	valueCodec, err := codec.New(config.GetString(fmt.Sprintf("%s.codec", prefix)))
+	if err != nil {
+		return nil, err
+	}
+	options := &Options{
+		Prefix:      config.GetString(fmt.Sprintf("%s.prefix", prefix)),
+		TTL:         config.GetDuration(fmt.Sprintf("%s.ttl", prefix)),
+		NegativeTTL: config.GetDuration(fmt.Sprintf("%s.negativeTTL", prefix)),
+		Jitter:      config.GetFloat64(fmt.Sprintf("%s.jitter", prefix)),
+		LocalSize:   config.GetInt(fmt.Sprintf("%s.localSize", prefix)),
+		LocalTTL:    config.GetDuration(fmt.Sprintf("%s.localTTL", prefix)),
+	}
+	return NewCacheFromOptions(options, valueCodec, store)
+}
+
+func loadConfigurationDefaults(prefix string, config *viper.Viper) {
+	config.SetDefault(fmt.Sprintf("%s.prefix", prefix), "cache:")
+	config.SetDefault(fmt.Sprintf("%s.codec", prefix), codec.NameJSON)
+	config.SetDefault(fmt.Sprintf("%s.ttl", prefix), "5m")
+	config.SetDefault(fmt.Sprintf("%s.negativeTTL", prefix), "30s")
+	config.SetDefault(fmt.Sprintf("%s.jitter", prefix), 0.1)
+	config.SetDefault(fmt.Sprintf("%s.localSize", prefix), 0)
+	config.SetDefault(fmt.Sprintf("%s.localTTL", prefix), "10s")
+}
+
+// NewCacheFromOptions creates a Cache with the given options
+func NewCacheFromOptions(options *Options, valueCodec codec.Codec, store Store) (*Cache, error) {
+	if options == nil {
+		return nil, fmt.Errorf("NewCacheFromOptions must have non-nil options")
+	}
+	if valueCodec == nil || store == nil {
+		return nil, fmt.Errorf("a codec and a store are required")
+	}
+	if options.TTL <= 0 {
+		return nil, fmt.Errorf("cache ttl must be positive")
+	}
+	if options.NegativeTTL < 0 {
+		return nil, fmt.Errorf("cache negative ttl must not be negative")
+	}
+	if options.Jitter < 0 || options.Jitter >= 1 {
+		return nil, fmt.Errorf("cache jitter must be in [0, 1)")
+	}
+	c := &Cache{
+		Options: options,
+		Codec:   valueCodec,
+		store:   store,
+		group:   newSingleflight(),
+	}
+	if options.LocalSize > 0 {
+		if options.LocalTTL <= 0 {
+			return nil, fmt.Errorf("cache local ttl must be positive")
+		}
+		c.local = newLRU(options.LocalSize)
+	}
+	return c, nil
+}
+
+// Get decodes the value of key into dest, which must be a pointer. On a miss the value is
+// loaded with loader and cached, concurrent misses of the same key share a single load
+// with the context of the first caller. Errors reading from or writing to redis are not
+// fatal, the value is then served from loader. ErrNotFound is returned for missing values
+func (c *Cache) Get(ctx context.Context, key string, dest interface{}, loader Loader) error {
+	data, err := c.get(ctx, key, loader)
+	if err != nil {
+		return err
+	}
+	return c.decode(data, dest)
+}
+
+func (c *Cache) get(ctx context.Context, key string, loader Loader) ([]byte, error) {
+	if c.local != nil {
+		if data, ok := c.local.get(key); ok {
+			return data, nil
+		}
+	}
+
+	data, err, _ := c.group.do(key, func() ([]byte, error) {
+		data, err := c.store.Get(ctx, c.key(key))
+		if err == nil && len(data) > 0 {
+			c.setLocal(key, data)
+			return data, nil
+		}
+
+		value, err := loader(ctx, key)
+		if err == ErrNotFound {
+			if c.Options.NegativeTTL > 0 {
+				data = []byte{flagNotFound}
+				c.setLocal(key, data)
+				_ = c.store.Set(ctx, c.key(key), data, c.jitter(c.Options.NegativeTTL))
+			}
+			return []byte{flagNotFound}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err = c.encode(value)
+		if err != nil {
+			return nil, err
+		}
+		c.setLocal(key, data)
+		_ = c.store.Set(ctx, c.key(key), data, c.jitter(c.Options.TTL))
+		return data, nil
+	})
+	return data, err
+}
+
+// Set encodes value and writes it through to redis and the local tier
+func (c *Cache) Set(ctx context.Context, key string, value interface{}) error {
+	data, err := c.encode(value)
+	if err != nil {
+		return err
+	}
+	err = c.store.Set(ctx, c.key(key), data, c.jitter(c.Options.TTL))
+	if err != nil {
+		return err
+	}
+	c.setLocal(key, data)
+	return nil
+}
+
+// Delete invalidates keys in redis and in the local tier. Other processes keep
+// their local copies until LocalTTL expires
+func (c *Cache) Delete(ctx context.Context, keys ...string) error {
+	storeKeys := make([]string, len(keys))
+	for i, key := range keys {
+		storeKeys[i] = c.key(key)
+		if c.local != nil {
+			c.local.del(key)
+		}
+	}
+	return c.store.Del(ctx, storeKeys...)
+}
+
+func (c *Cache) key(key string) string {
+	return c.Options.Prefix + key
+}
+
+func (c *Cache) setLocal(key string, data []byte) {
+	if c.local != nil {
+		c.local.set(key, data, c.Options.LocalTTL)
+	}
+}
+
+// jitter shortens ttl by a random fraction of up to Options.Jitter
+func (c *Cache) jitter(ttl time.Duration) time.Duration {
+	if c.Options.Jitter == 0 {
+		return ttl
+	}
+	return ttl - time.Duration(rand.Float64()*c.Options.Jitter*float64(ttl))
+}
+
+func (c *Cache) encode(value interface{}) ([]byte, error) {
+	data, err := c.Codec.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{flagValue}, data...), nil
+}
+
+func (c *Cache) decode(data []byte, dest interface{}) error {
+	if len(data) == 0 {
+		return fmt.Errorf("cache: empty value")
+	}
+	if data[0] == flagNotFound {
+		return ErrNotFound
+	}
+	return c.Codec.Unmarshal(data[1:], dest)
+}
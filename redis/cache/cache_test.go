@@ -0,0 +1,295 @@
+/*
+ * Copyright (c) 2016 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/codec"
+)
+
+type storeEntry struct {
+	value []byte
+	ttl   time.Duration
+}
+
+// fakeStore is an in-memory Store that records the TTL of each key
+type fakeStore struct {
+	mutex   sync.Mutex
+	entries map[string]storeEntry
+	err     error
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{entries: map[string]storeEntry{}}
+}
+
+func (s *fakeStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil, ErrMiss
+	}
+	return entry.value, nil
+}
+
+func (s *fakeStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.entries[key] = storeEntry{value: value, ttl: ttl}
+	return nil
+}
+
+func (s *fakeStore) Del(ctx context.Context, keys ...string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, key := range keys {
+		delete(s.entries, key)
+	}
+	return s.err
+}
+
+func (s *fakeStore) entry(key string) (storeEntry, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	entry, ok := s.entries[key]
+	return entry, ok
+}
+
+type player struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+var _ = Describe("Cache", func() {
+	var store *fakeStore
+	var options *Options
+	var loads int32
+	ctx := context.Background()
+
+	loader := func(ctx context.Context, key string) (interface{}, error) {
+		atomic.AddInt32(&loads, 1)
+		if key == "missing" {
+			return nil, ErrNotFound
+		}
+		if key == "broken" {
+			return nil, errors.New("database down")
+		}
+		return &player{ID: key, Name: "name-" + key}, nil
+	}
+
+	newCache := func() *Cache {
+		c, err := NewCacheFromOptions(options, codec.JSON{}, store)
+		Expect(err).NotTo(HaveOccurred())
+		return c
+	}
+
+	BeforeEach(func() {
+		store = newFakeStore()
+		atomic.StoreInt32(&loads, 0)
+		options = &Options{
+			Prefix:      "cache:",
+			TTL:         time.Minute,
+			NegativeTTL: 10 * time.Second,
+		}
+	})
+
+	Describe("[Unit]", func() {
+		It("should load options from config", func() {
+			config := viper.New()
+			config.Set("extensions.cache.ttl", "1h")
+			config.Set("extensions.cache.localSize", 10)
+			c, err := NewCache("extensions.cache", config, store)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.Options.TTL).To(Equal(time.Hour))
+			Expect(c.Options.NegativeTTL).To(Equal(30 * time.Second))
+			Expect(c.Codec).To(Equal(codec.JSON{}))
+			Expect(c.local).NotTo(BeNil())
+		})
+
+		It("should fail on an unknown codec", func() {
+			config := viper.New()
+			config.Set("extensions.cache.codec", "xml")
+			_, err := NewCache("extensions.cache", config, store)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should load a miss and serve the next get from redis", func() {
+			c := newCache()
+			var p player
+			Expect(c.Get(ctx, "1", &p, loader)).To(Succeed())
+			Expect(p).To(Equal(player{ID: "1", Name: "name-1"}))
+
+			entry, ok := store.entry("cache:1")
+			Expect(ok).To(BeTrue())
+			Expect(entry.ttl).To(Equal(time.Minute))
+
+			var cached player
+			Expect(c.Get(ctx, "1", &cached, loader)).To(Succeed())
+			Expect(cached).To(Equal(p))
+			Expect(atomic.LoadInt32(&loads)).To(BeEquivalentTo(1))
+		})
+
+		It("should cache values that were not found", func() {
+			c := newCache()
+			var p player
+			Expect(c.Get(ctx, "missing", &p, loader)).To(Equal(ErrNotFound))
+			Expect(c.Get(ctx, "missing", &p, loader)).To(Equal(ErrNotFound))
+			Expect(atomic.LoadInt32(&loads)).To(BeEquivalentTo(1))
+			entry, _ := store.entry("cache:missing")
+			Expect(entry.ttl).To(Equal(10 * time.Second))
+		})
+
+		It("should not cache values that were not found when negative caching is disabled", func() {
+			options.NegativeTTL = 0
+			c := newCache()
+			var p player
+			Expect(c.Get(ctx, "missing", &p, loader)).To(Equal(ErrNotFound))
+			Expect(c.Get(ctx, "missing", &p, loader)).To(Equal(ErrNotFound))
+			Expect(atomic.LoadInt32(&loads)).To(BeEquivalentTo(2))
+		})
+
+		It("should not cache loader errors", func() {
+			c := newCache()
+			var p player
+			Expect(c.Get(ctx, "broken", &p, loader)).To(MatchError("database down"))
+			_, ok := store.entry("cache:broken")
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should fall back to the loader when redis fails", func() {
+			store.err = errors.New("connection refused")
+			c := newCache()
+			var p player
+			Expect(c.Get(ctx, "1", &p, loader)).To(Succeed())
+			Expect(p.ID).To(Equal("1"))
+		})
+
+		It("should share a single load between concurrent misses", func() {
+			c := newCache()
+			release := make(chan struct{})
+			slowLoader := func(ctx context.Context, key string) (interface{}, error) {
+				<-release
+				return loader(ctx, key)
+			}
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					var p player
+					Expect(c.Get(ctx, "1", &p, slowLoader)).To(Succeed())
+					Expect(p.ID).To(Equal("1"))
+				}()
+			}
+			time.Sleep(20 * time.Millisecond)
+			close(release)
+			wg.Wait()
+			Expect(atomic.LoadInt32(&loads)).To(BeEquivalentTo(1))
+		})
+
+		It("should fail concurrent misses when the shared load panics", func() {
+			c := newCache()
+			release := make(chan struct{})
+			panicLoader := func(ctx context.Context, key string) (interface{}, error) {
+				<-release
+				panic("loader bug")
+			}
+			var wg sync.WaitGroup
+			var panics, failures int32
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					defer func() {
+						if r := recover(); r != nil {
+							Expect(r).To(Equal("loader bug"))
+							atomic.AddInt32(&panics, 1)
+						}
+					}()
+					var p player
+					err := c.Get(ctx, "1", &p, panicLoader)
+					Expect(err).To(MatchError("cache loader panicked: loader bug"))
+					atomic.AddInt32(&failures, 1)
+				}()
+			}
+			time.Sleep(20 * time.Millisecond)
+			close(release)
+			wg.Wait()
+			Expect(atomic.LoadInt32(&panics)).To(BeEquivalentTo(1))
+			Expect(atomic.LoadInt32(&failures)).To(BeEquivalentTo(9))
+		})
+
+		It("should jitter ttls", func() {
+			options.Jitter = 0.5
+			c := newCache()
+			for i := 0; i < 100; i++ {
+				ttl := c.jitter(time.Minute)
+				Expect(ttl).To(BeNumerically(">", 30*time.Second))
+				Expect(ttl).To(BeNumerically("<=", time.Minute))
+			}
+		})
+
+		It("should serve values from the local tier", func() {
+			options.LocalSize = 10
+			options.LocalTTL = time.Minute
+			c := newCache()
+			var p player
+			Expect(c.Get(ctx, "1", &p, loader)).To(Succeed())
+			store.err = errors.New("connection refused")
+			Expect(c.Get(ctx, "1", &p, loader)).To(Succeed())
+			Expect(atomic.LoadInt32(&loads)).To(BeEquivalentTo(1))
+		})
+
+		It("should write through and invalidate", func() {
+			options.LocalSize = 10
+			options.LocalTTL = time.Minute
+			c := newCache()
+			Expect(c.Set(ctx, "1", &player{ID: "1", Name: "written"})).To(Succeed())
+			var p player
+			Expect(c.Get(ctx, "1", &p, loader)).To(Succeed())
+			Expect(p.Name).To(Equal("written"))
+
+			Expect(c.Delete(ctx, "1")).To(Succeed())
+			Expect(c.Get(ctx, "1", &p, loader)).To(Succeed())
+			Expect(p.Name).To(Equal("name-1"))
+			Expect(atomic.LoadInt32(&loads)).To(BeEquivalentTo(1))
+		})
+	})
+})
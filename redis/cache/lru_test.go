@@ -0,0 +1,66 @@
+/*
+ * Copyright (c) 2016 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package cache
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LRU", func() {
+	Describe("[Unit]", func() {
+		It("should evict the least recently used entry", func() {
+			c := newLRU(2)
+			c.set("a", []byte("1"), time.Minute)
+			c.set("b", []byte("2"), time.Minute)
+			_, ok := c.get("a")
+			Expect(ok).To(BeTrue())
+			c.set("c", []byte("3"), time.Minute)
+
+			_, ok = c.get("b")
+			Expect(ok).To(BeFalse())
+			value, ok := c.get("a")
+			Expect(ok).To(BeTrue())
+			Expect(value).To(Equal([]byte("1")))
+		})
+
+		It("should expire entries", func() {
+			c := newLRU(2)
+			c.set("a", []byte("1"), 10*time.Millisecond)
+			time.Sleep(20 * time.Millisecond)
+			_, ok := c.get("a")
+			Expect(ok).To(BeFalse())
+			Expect(c.order.Len()).To(Equal(0))
+		})
+
+		It("should delete entries", func() {
+			c := newLRU(2)
+			c.set("a", []byte("1"), time.Minute)
+			c.del("a")
+			_, ok := c.get("a")
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package cache
+
+import (
+	"fmt"
+	"sync"
+)
+
+// call is an in-flight or completed load
+type call struct {
+	wg    sync.WaitGroup
+	value []byte
+	err   error
+}
+
+// singleflight de-duplicates concurrent loads of the same key, so that only
+// the first caller runs the load and every other caller waits for its result
+type singleflight struct {
+	mutex sync.Mutex
+	calls map[string]*call
+}
+
+func newSingleflight() *singleflight {
+	return &singleflight{calls: map[string]*call{}}
+}
+
+// do runs fn for key unless a call for key is in flight, returning whether the result was shared.
+// If fn panics the waiting callers get an error and the panic is raised again in the caller that ran fn
+func (g *singleflight) do(key string, fn func() ([]byte, error)) ([]byte, error, bool) {
+	g.mutex.Lock()
+	if c, ok := g.calls[key]; ok {
+		g.mutex.Unlock()
+		c.wg.Wait()
+		return c.value, c.err, true
+	}
+	c := &call{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mutex.Unlock()
+
+	defer func() {
+		r := recover()
+		if r != nil {
+			c.err = fmt.Errorf("cache loader panicked: %v", r)
+		}
+		g.mutex.Lock()
+		delete(g.calls, key)
+		g.mutex.Unlock()
+		c.wg.Done()
+		if r != nil {
+			panic(r)
+		}
+	}()
+	c.value, c.err = fn()
+	return c.value, c.err, false
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	redisv8 "github.com/go-redis/redis/v8"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+// ErrMiss is returned by a Store when the key does not exist
+var ErrMiss = errors.New("cache: miss")
+
+// Store is the remote storage a Cache keeps encoded values in. Del is given keys that
+// may belong to different cluster slots
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Del(ctx context.Context, keys ...string) error
+}
+
+// FromV8 creates a Store on a go-redis v8 client, as returned by redis/v8.NewClient
+func FromV8(client redisv8.Cmdable) Store {
+	return &v8Store{client: client}
+}
+
+// FromV9 creates a Store on a go-redis v9 client, such as the Instance of redis/cluster.Client
+func FromV9(client redisv9.Cmdable) Store {
+	return &v9Store{client: client}
+}
+
+type v8Store struct {
+	client redisv8.Cmdable
+}
+
+func (s *v8Store) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := s.client.Get(ctx, key).Bytes()
+	if err == redisv8.Nil {
+		return nil, ErrMiss
+	}
+	return value, err
+}
+
+func (s *v8Store) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, key, value, ttl).Err()
+}
+
+// Del deletes each key with its own command, pipelined, so keys of different slots
+// do not fail with CROSSSLOT on cluster mode
+func (s *v8Store) Del(ctx context.Context, keys ...string) error {
+	_, err := s.client.Pipelined(ctx, func(pipe redisv8.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(ctx, key)
+		}
+		return nil
+	})
+	return err
+}
+
+type v9Store struct {
+	client redisv9.Cmdable
+}
+
+func (s *v9Store) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := s.client.Get(ctx, key).Bytes()
+	if err == redisv9.Nil {
+		return nil, ErrMiss
+	}
+	return value, err
+}
+
+func (s *v9Store) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, key, value, ttl).Err()
+}
+
+// Del deletes each key with its own command, pipelined, so keys of different slots
+// do not fail with CROSSSLOT on cluster mode
+func (s *v9Store) Del(ctx context.Context, keys ...string) error {
+	_, err := s.client.Pipelined(ctx, func(pipe redisv9.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(ctx, key)
+		}
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,367 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+// ClusterSlots is the number of hash slots a redis cluster must cover
+const ClusterSlots = 16384
+
+// Node roles reported by ShardStatus
+const (
+	RoleMaster  = "master"
+	RoleReplica = "slave"
+)
+
+// HealthOptions holds the thresholds used to decide if the client is ready
+type HealthOptions struct {
+	// Timeout bounds the checks made on each node
+	Timeout time.Duration
+	// MaxReplicaLag is the most bytes of the replication stream a replica may be behind its master,
+	// the difference between the master_repl_offset of the master and the offset of the replica.
+	// Zero disables the check
+	MaxReplicaLag int64
+	// RequireReplicas fails readiness when a replica is down or its link to the master is down,
+	// otherwise only masters are required
+	RequireReplicas bool
+}
+
+// HealthOptionsFromConfig reads the health options under prefix.health
+func HealthOptionsFromConfig(config *viper.Viper, prefix string) *HealthOptions {
+	config.SetDefault(fmt.Sprintf("%s.health.timeout", prefix), time.Second)
+	config.SetDefault(fmt.Sprintf("%s.health.maxReplicaLag", prefix), 0)
+	config.SetDefault(fmt.Sprintf("%s.health.requireReplicas", prefix), false)
+
+	return &HealthOptions{
+		Timeout:         config.GetDuration(fmt.Sprintf("%s.health.timeout", prefix)),
+		MaxReplicaLag:   config.GetInt64(fmt.Sprintf("%s.health.maxReplicaLag", prefix)),
+		RequireReplicas: config.GetBool(fmt.Sprintf("%s.health.requireReplicas", prefix)),
+	}
+}
+
+// ReplicaStatus is the replication state of a replica as seen by its master
+type ReplicaStatus struct {
+	Addr      string `json:"addr"`
+	State     string `json:"state"`
+	OffsetLag int64  `json:"offsetLag"`
+}
+
+// ShardStatus is the state of a single node
+type ShardStatus struct {
+	Addr    string        `json:"addr"`
+	Role    string        `json:"role,omitempty"`
+	Healthy bool          `json:"healthy"`
+	Latency time.Duration `json:"latency"`
+	Error   string        `json:"error,omitempty"`
+	// Replicas connected to a master
+	Replicas []ReplicaStatus `json:"replicas,omitempty"`
+	// MasterLinkUp is the state of the link of a replica to its master
+	MasterLinkUp bool `json:"masterLinkUp,omitempty"`
+}
+
+// SlotCoverage is the hash slot state reported by CLUSTER INFO
+type SlotCoverage struct {
+	Assigned int `json:"assigned"`
+	OK       int `json:"ok"`
+	PFail    int `json:"pfail"`
+	Fail     int `json:"fail"`
+}
+
+// Covered reports whether every hash slot is served
+func (s SlotCoverage) Covered() bool {
+	return s.OK == ClusterSlots
+}
+
+// PoolStats is the connection pool usage of the client
+type PoolStats struct {
+	Hits       uint32 `json:"hits"`
+	Misses     uint32 `json:"misses"`
+	Timeouts   uint32 `json:"timeouts"`
+	TotalConns uint32 `json:"totalConns"`
+	IdleConns  uint32 `json:"idleConns"`
+	StaleConns uint32 `json:"staleConns"`
+}
+
+// Health is a report of the state of the client and the nodes it talks to
+type Health struct {
+	Ready        bool          `json:"ready"`
+	ClusterMode  bool          `json:"clusterMode"`
+	ClusterState string        `json:"clusterState,omitempty"`
+	KnownNodes   int           `json:"knownNodes,omitempty"`
+	Slots        *SlotCoverage `json:"slots,omitempty"`
+	Shards       []ShardStatus `json:"shards"`
+	Pool         PoolStats     `json:"pool"`
+	// Problems lists the reasons the client is not ready
+	Problems []string `json:"problems,omitempty"`
+}
+
+type shardIterator interface {
+	ForEachMaster(ctx context.Context, fn func(ctx context.Context, client *redis.Client) error) error
+	ForEachSlave(ctx context.Context, fn func(ctx context.Context, client *redis.Client) error) error
+}
+
+// Health checks every node the client talks to. In cluster mode it reads CLUSTER INFO for the
+// slot coverage and checks each master and replica, otherwise it checks the single node.
+// A nil options uses the defaults of HealthOptionsFromConfig
+func (c *Client) Health(ctx context.Context, options *HealthOptions) *Health {
+	opts := HealthOptions{Timeout: time.Second}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = time.Second
+	}
+	options = &opts
+
+	health := &Health{ClusterMode: c.ClusterMode}
+	if stats := c.Instance.PoolStats(); stats != nil {
+		health.Pool = PoolStats{
+			Hits:       stats.Hits,
+			Misses:     stats.Misses,
+			Timeouts:   stats.Timeouts,
+			TotalConns: stats.TotalConns,
+			IdleConns:  stats.IdleConns,
+			StaleConns: stats.StaleConns,
+		}
+	}
+
+	shards, ok := c.Instance.(shardIterator)
+	if c.ClusterMode && ok {
+		c.clusterHealth(ctx, options, shards, health)
+	} else {
+		health.Shards = []ShardStatus{checkNode(ctx, options, "", RoleMaster, c.Instance)}
+	}
+
+	for _, shard := range health.Shards {
+		health.Problems = append(health.Problems, shardProblems(options, shard)...)
+	}
+	health.Ready = len(health.Problems) == 0
+	return health
+}
+
+// Ready returns an error describing why the client is not ready, if it is not
+func (c *Client) Ready(ctx context.Context, options *HealthOptions) error {
+	health := c.Health(ctx, options)
+	if !health.Ready {
+		return fmt.Errorf("redis not ready: %s", strings.Join(health.Problems, "; "))
+	}
+	return nil
+}
+
+// HealthHandler serves the health report as JSON, with status 503 when the client is not ready,
+// to be used as a readiness endpoint
+func (c *Client) HealthHandler(options *HealthOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		health := c.Health(r.Context(), options)
+		w.Header().Set("Content-Type", "application/json")
+		if health.Ready {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(health)
+	})
+}
+
+func (c *Client) clusterHealth(ctx context.Context, options *HealthOptions, shards shardIterator, health *Health) {
+	infoCtx, cancel := context.WithTimeout(ctx, options.Timeout)
+	info, err := c.Instance.ClusterInfo(infoCtx).Result()
+	cancel()
+	if err != nil {
+		health.Problems = append(health.Problems, fmt.Sprintf("cluster info: %s", err))
+	} else {
+		fields := parseInfo(info)
+		health.ClusterState = fields["cluster_state"]
+		health.KnownNodes = atoi(fields["cluster_known_nodes"])
+		health.Slots = &SlotCoverage{
+			Assigned: atoi(fields["cluster_slots_assigned"]),
+			OK:       atoi(fields["cluster_slots_ok"]),
+			PFail:    atoi(fields["cluster_slots_pfail"]),
+			Fail:     atoi(fields["cluster_slots_fail"]),
+		}
+		if health.ClusterState != "ok" {
+			health.Problems = append(health.Problems, fmt.Sprintf("cluster state is %s", health.ClusterState))
+		}
+		if !health.Slots.Covered() {
+			health.Problems = append(health.Problems, fmt.Sprintf("%d of %d slots covered", health.Slots.OK, ClusterSlots))
+		}
+	}
+
+	var mutex sync.Mutex
+	check := func(role string) func(ctx context.Context, client *redis.Client) error {
+		return func(ctx context.Context, client *redis.Client) error {
+			status := checkNode(ctx, options, client.Options().Addr, role, client)
+			mutex.Lock()
+			health.Shards = append(health.Shards, status)
+			mutex.Unlock()
+			return nil
+		}
+	}
+	if err := shards.ForEachMaster(ctx, check(RoleMaster)); err != nil {
+		health.Problems = append(health.Problems, fmt.Sprintf("cluster masters: %s", err))
+	}
+	if err := shards.ForEachSlave(ctx, check(RoleReplica)); err != nil {
+		health.Problems = append(health.Problems, fmt.Sprintf("cluster replicas: %s", err))
+	}
+	sort.Slice(health.Shards, func(i, j int) bool {
+		return health.Shards[i].Addr < health.Shards[j].Addr
+	})
+}
+
+type nodeClient interface {
+	Ping(ctx context.Context) *redis.StatusCmd
+	Info(ctx context.Context, section ...string) *redis.StringCmd
+}
+
+// checkNode pings a node and reads its replication state, role is the role the node is
+// expected to have and is replaced by the one it reports
+func checkNode(ctx context.Context, options *HealthOptions, addr, role string, client nodeClient) ShardStatus {
+	ctx, cancel := context.WithTimeout(ctx, options.Timeout)
+	defer cancel()
+
+	status := ShardStatus{Addr: addr, Role: role}
+	start := time.Now()
+	if err := client.Ping(ctx).Err(); err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Latency = time.Since(start)
+	status.Healthy = true
+
+	info, err := client.Info(ctx, "replication").Result()
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	fields := parseInfo(info)
+	if role, ok := fields["role"]; ok {
+		status.Role = role
+	}
+	switch status.Role {
+	case RoleMaster:
+		offset := atoi64(fields["master_repl_offset"])
+		for i := 0; ; i++ {
+			replica, ok := fields[fmt.Sprintf("slave%d", i)]
+			if !ok {
+				break
+			}
+			status.Replicas = append(status.Replicas, parseReplica(replica, offset))
+		}
+	case RoleReplica:
+		status.MasterLinkUp = fields["master_link_status"] == "up"
+	}
+	return status
+}
+
+func shardProblems(options *HealthOptions, shard ShardStatus) []string {
+	name := shard.Addr
+	if name == "" {
+		name = "redis"
+	}
+	if shard.Role != RoleReplica {
+		if !shard.Healthy {
+			return []string{fmt.Sprintf("%s: %s", name, shard.Error)}
+		}
+		return replicaLagProblems(options, name, shard.Replicas)
+	}
+
+	problems := []string{}
+	if options.RequireReplicas {
+		if !shard.Healthy {
+			return []string{fmt.Sprintf("%s: %s", name, shard.Error)}
+		}
+		if !shard.MasterLinkUp {
+			problems = append(problems, fmt.Sprintf("%s: master link down", name))
+		}
+	}
+	return problems
+}
+
+// replicaLagProblems checks the replication offset lag of the replicas of a master, as the
+// master reports it, since a replica does not know how far behind the master it is
+func replicaLagProblems(options *HealthOptions, name string, replicas []ReplicaStatus) []string {
+	if options.MaxReplicaLag <= 0 {
+		return nil
+	}
+	var problems []string
+	for _, replica := range replicas {
+		if replica.OffsetLag > options.MaxReplicaLag {
+			problems = append(problems, fmt.Sprintf("%s: replica %s lag %d over %d", name, replica.Addr, replica.OffsetLag, options.MaxReplicaLag))
+		}
+	}
+	return problems
+}
+
+// parseReplica parses a slaveN line of INFO replication, such as
+// ip=10.0.0.2,port=6379,state=online,offset=42,lag=0
+func parseReplica(line string, masterOffset int64) ReplicaStatus {
+	fields := map[string]string{}
+	for _, field := range strings.Split(line, ",") {
+		if kv := strings.SplitN(field, "=", 2); len(kv) == 2 {
+			fields[kv[0]] = kv[1]
+		}
+	}
+	return ReplicaStatus{
+		Addr:      fmt.Sprintf("%s:%s", fields["ip"], fields["port"]),
+		State:     fields["state"],
+		OffsetLag: masterOffset - atoi64(fields["offset"]),
+	}
+}
+
+// parseInfo parses the key:value lines of INFO and CLUSTER INFO
+func parseInfo(info string) map[string]string {
+	fields := map[string]string{}
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if kv := strings.SplitN(line, ":", 2); len(kv) == 2 {
+			fields[kv[0]] = kv[1]
+		}
+	}
+	return fields
+}
+
+func atoi(s string) int {
+	i, _ := strconv.Atoi(s)
+	return i
+}
+
+func atoi64(s string) int64 {
+	i, _ := strconv.ParseInt(s, 10, 64)
+	return i
+}
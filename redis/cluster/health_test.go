@@ -0,0 +1,241 @@
+/*
+ * Copyright (c) 2016 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/redis/cluster/mocks"
+)
+
+// fakeNode answers commands without a server
+type fakeNode struct {
+	pingErr error
+	info    string
+}
+
+func (n *fakeNode) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, errors.New("unexpected dial")
+	}
+}
+
+func (n *fakeNode) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		switch cmd := cmd.(type) {
+		case *redis.StatusCmd:
+			if n.pingErr != nil {
+				cmd.SetErr(n.pingErr)
+				return n.pingErr
+			}
+			cmd.SetVal("PONG")
+		case *redis.StringCmd:
+			cmd.SetVal(n.info)
+		}
+		return nil
+	}
+}
+
+func (n *fakeNode) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func newNode(addr string, node *fakeNode) *redis.Client {
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	client.AddHook(node)
+	return client
+}
+
+type fakeCluster struct {
+	*mocks.MockUniversalClient
+	masters []*redis.Client
+	slaves  []*redis.Client
+}
+
+func (c *fakeCluster) ForEachMaster(ctx context.Context, fn func(ctx context.Context, client *redis.Client) error) error {
+	for _, client := range c.masters {
+		fn(ctx, client)
+	}
+	return nil
+}
+
+func (c *fakeCluster) ForEachSlave(ctx context.Context, fn func(ctx context.Context, client *redis.Client) error) error {
+	for _, client := range c.slaves {
+		fn(ctx, client)
+	}
+	return nil
+}
+
+const clusterInfoOK = "cluster_state:ok\r\ncluster_slots_assigned:16384\r\ncluster_slots_ok:16384\r\n" +
+	"cluster_slots_pfail:0\r\ncluster_slots_fail:0\r\ncluster_known_nodes:4\r\n"
+
+const masterInfo = "# Replication\r\nrole:master\r\nconnected_slaves:1\r\n" +
+	"slave0:ip=10.0.0.2,port=6379,state=online,offset=90,lag=0\r\nmaster_repl_offset:100\r\n"
+
+const replicaInfo = "# Replication\r\nrole:slave\r\nmaster_link_status:up\r\n"
+
+var _ = Describe("Health", func() {
+	var mockCtrl *gomock.Controller
+	var instance *mocks.MockUniversalClient
+	var ctx context.Context
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		instance = mocks.NewMockUniversalClient(mockCtrl)
+		ctx = context.Background()
+		instance.EXPECT().PoolStats().Return(&redis.PoolStats{Hits: 5, TotalConns: 2, IdleConns: 1}).AnyTimes()
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	newCluster := func(info string, masters, slaves []*redis.Client) *Client {
+		instance.EXPECT().ClusterInfo(gomock.Any()).Return(redis.NewStringResult(info, nil))
+		return &Client{
+			Instance:    &fakeCluster{MockUniversalClient: instance, masters: masters, slaves: slaves},
+			ClusterMode: true,
+		}
+	}
+
+	Describe("[Unit]", func() {
+		It("should read options from config", func() {
+			config := viper.New()
+			config.Set("redis.health.maxReplicaLag", 1024)
+			options := HealthOptionsFromConfig(config, "redis")
+			Expect(options.Timeout).To(Equal(time.Second))
+			Expect(options.MaxReplicaLag).To(BeEquivalentTo(1024))
+			Expect(options.RequireReplicas).To(BeFalse())
+		})
+
+		It("should report a single node", func() {
+			instance.EXPECT().Ping(gomock.Any()).Return(redis.NewStatusResult("PONG", nil))
+			instance.EXPECT().Info(gomock.Any(), "replication").Return(redis.NewStringResult(masterInfo, nil))
+			client := &Client{Instance: instance}
+
+			health := client.Health(ctx, nil)
+			Expect(health.Ready).To(BeTrue())
+			Expect(health.ClusterMode).To(BeFalse())
+			Expect(health.Slots).To(BeNil())
+			Expect(health.Pool).To(Equal(PoolStats{Hits: 5, TotalConns: 2, IdleConns: 1}))
+			Expect(health.Shards).To(HaveLen(1))
+			Expect(health.Shards[0].Healthy).To(BeTrue())
+			Expect(health.Shards[0].Replicas).To(Equal([]ReplicaStatus{{Addr: "10.0.0.2:6379", State: "online", OffsetLag: 10}}))
+		})
+
+		It("should not be ready when the single node is down", func() {
+			instance.EXPECT().Ping(gomock.Any()).Return(redis.NewStatusResult("", errors.New("connection refused")))
+			client := &Client{Instance: instance}
+
+			Expect(client.Ready(ctx, nil)).To(MatchError("redis not ready: redis: connection refused"))
+		})
+
+		It("should report every shard of a cluster", func() {
+			client := newCluster(clusterInfoOK,
+				[]*redis.Client{newNode("10.0.0.1:6379", &fakeNode{info: masterInfo})},
+				[]*redis.Client{newNode("10.0.0.2:6379", &fakeNode{info: replicaInfo})},
+			)
+
+			health := client.Health(ctx, nil)
+			Expect(health.Ready).To(BeTrue())
+			Expect(health.ClusterState).To(Equal("ok"))
+			Expect(health.KnownNodes).To(Equal(4))
+			Expect(*health.Slots).To(Equal(SlotCoverage{Assigned: ClusterSlots, OK: ClusterSlots}))
+			Expect(health.Shards).To(HaveLen(2))
+			Expect(health.Shards[0].Addr).To(Equal("10.0.0.1:6379"))
+			Expect(health.Shards[0].Role).To(Equal(RoleMaster))
+			Expect(health.Shards[1].Role).To(Equal(RoleReplica))
+			Expect(health.Shards[1].MasterLinkUp).To(BeTrue())
+		})
+
+		It("should not be ready without full slot coverage", func() {
+			info := "cluster_state:fail\r\ncluster_slots_assigned:16384\r\ncluster_slots_ok:16000\r\ncluster_slots_fail:384\r\n"
+			client := newCluster(info, []*redis.Client{newNode("10.0.0.1:6379", &fakeNode{info: masterInfo})}, nil)
+
+			health := client.Health(ctx, nil)
+			Expect(health.Ready).To(BeFalse())
+			Expect(health.Slots.Covered()).To(BeFalse())
+			Expect(health.Problems).To(ConsistOf("cluster state is fail", "16000 of 16384 slots covered"))
+		})
+
+		It("should not be ready when a master is down", func() {
+			client := newCluster(clusterInfoOK, []*redis.Client{
+				newNode("10.0.0.1:6379", &fakeNode{pingErr: errors.New("i/o timeout")}),
+			}, nil)
+
+			health := client.Health(ctx, nil)
+			Expect(health.Ready).To(BeFalse())
+			Expect(health.Shards[0].Healthy).To(BeFalse())
+			Expect(health.Shards[0].Role).To(Equal(RoleMaster))
+			Expect(health.Problems).To(ConsistOf("10.0.0.1:6379: i/o timeout"))
+		})
+
+		It("should only require replicas when configured", func() {
+			slaves := []*redis.Client{newNode("10.0.0.2:6379", &fakeNode{pingErr: errors.New("i/o timeout")})}
+			masters := []*redis.Client{newNode("10.0.0.1:6379", &fakeNode{info: masterInfo})}
+
+			Expect(newCluster(clusterInfoOK, masters, slaves).Health(ctx, nil).Ready).To(BeTrue())
+
+			health := newCluster(clusterInfoOK, masters, slaves).Health(ctx, &HealthOptions{RequireReplicas: true})
+			Expect(health.Ready).To(BeFalse())
+			Expect(health.Problems).To(ConsistOf("10.0.0.2:6379: i/o timeout"))
+		})
+
+		It("should not be ready when a replica lags behind", func() {
+			masters := []*redis.Client{newNode("10.0.0.1:6379", &fakeNode{info: masterInfo})}
+			slaves := []*redis.Client{newNode("10.0.0.2:6379", &fakeNode{info: replicaInfo})}
+
+			Expect(newCluster(clusterInfoOK, masters, slaves).Health(ctx, &HealthOptions{MaxReplicaLag: 10}).Ready).To(BeTrue())
+
+			health := newCluster(clusterInfoOK, masters, slaves).Health(ctx, &HealthOptions{MaxReplicaLag: 5})
+			Expect(health.Ready).To(BeFalse())
+			Expect(health.Problems).To(ConsistOf("10.0.0.1:6379: replica 10.0.0.2:6379 lag 10 over 5"))
+		})
+
+		It("should serve the report with the readiness status", func() {
+			instance.EXPECT().Ping(gomock.Any()).Return(redis.NewStatusResult("", errors.New("connection refused")))
+			client := &Client{Instance: instance}
+
+			recorder := httptest.NewRecorder()
+			client.HealthHandler(nil).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/ready", nil))
+			Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+
+			health := &Health{}
+			Expect(json.Unmarshal(recorder.Body.Bytes(), health)).To(Succeed())
+			Expect(health.Ready).To(BeFalse())
+			Expect(health.Problems).To(ConsistOf("redis: connection refused"))
+		})
+	})
+})
@@ -30,6 +30,7 @@ import (
 	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/redis/scripting"
 )
 
 // Client identifies uniquely one redis client with a pool of connections
@@ -38,11 +39,22 @@ type Client struct {
 	ClusterMode bool
 }
 
+// ClientArgs holds the settings of a Client. Setting SentinelMasterName connects through
+// sentinel, using Url only for credentials and timeouts. ReplicaReads routes read-only
+// commands to replicas: the cluster replicas in cluster mode, the replicas discovered by
+// sentinel or the node at ReplicaUrl. Scripts registered in Scripts are preloaded once
+// connected, on every shard in cluster mode
 type ClientArgs struct {
-	Url           string
-	ClusterMode   bool
-	EnableMetrics bool
-	EnableTracing bool
+	Url                string
+	ClusterMode        bool
+	EnableMetrics      bool
+	EnableTracing      bool
+	SentinelMasterName string
+	SentinelAddrs      []string
+	SentinelPassword   string
+	ReplicaReads       bool
+	ReplicaUrl         string
+	Scripts            *scripting.Registry
 }
 
 // NewClient creates and returns a new redis client based on the given settings. It only supports redis 7 engine and uses go-redis v9.
@@ -55,14 +67,19 @@ func NewClient(args *ClientArgs) (*Client, error) {
 		ClusterMode: args.ClusterMode,
 	}
 
-	if args.ClusterMode {
-		if err := client.ConnectCluster(args.Url); err != nil {
-			return nil, err
-		}
-	} else {
-		if err := client.Connect(args.Url); err != nil {
-			return nil, err
-		}
+	var err error
+	switch {
+	case args.SentinelMasterName != "":
+		err = client.ConnectSentinel(args)
+	case args.ClusterMode:
+		err = client.connectCluster(args.Url, args.ReplicaReads)
+	case args.ReplicaReads:
+		err = client.ConnectReplica(args.Url, args.ReplicaUrl)
+	default:
+		err = client.Connect(args.Url)
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	if args.EnableTracing {
@@ -77,16 +94,27 @@ func NewClient(args *ClientArgs) (*Client, error) {
 		}
 	}
 
+	if args.Scripts != nil {
+		if err := args.Scripts.Connect(context.Background(), scripting.FromV9(client.Instance)); err != nil {
+			client.Instance.Close()
+			return nil, fmt.Errorf("failed to load redis scripts: %w", err)
+		}
+	}
+
 	return client, nil
 }
 
 // NewClientFromConfig creates and returns a new redis client based on the given settings from predefined env vars. It only supports redis 7 engine and uses go-redis v9.
-func NewClientFromConfig(config *viper.Viper, prefix string) (*Client, error) {
+// The given script registry is connected to the client and its scripts preloaded
+func NewClientFromConfig(config *viper.Viper, prefix string, scripts ...*scripting.Registry) (*Client, error) {
 	if config == nil {
 		return nil, fmt.Errorf("NewClientFromConfig must have a non-nil config")
 	}
 
 	args := CreateClientArgs(config, prefix)
+	if len(scripts) > 0 {
+		args.Scripts = scripts[0]
+	}
 
 	return NewClient(args)
 }
@@ -112,6 +140,11 @@ func CreateClientArgs(config *viper.Viper, prefix string) *ClientArgs {
 	}
 
 	clusterMode := config.GetBool(fmt.Sprintf("%s.clusterMode", prefix))
+	sentinelMasterName := config.GetString(fmt.Sprintf("%s.sentinel.masterName", prefix))
+	sentinelAddrs := config.GetStringSlice(fmt.Sprintf("%s.sentinel.addrs", prefix))
+	sentinelPassword := config.GetString(fmt.Sprintf("%s.sentinel.password", prefix))
+	replicaReads := config.GetBool(fmt.Sprintf("%s.replicaReads", prefix))
+	replicaUrl := config.GetString(fmt.Sprintf("%s.replicaUrl", prefix))
 	enableMetrics := config.GetBool(fmt.Sprintf("%s.enableMetrics", prefix))
 	enableTracing := config.GetBool(fmt.Sprintf("%s.enableTracing", prefix))
 
@@ -137,10 +170,15 @@ func CreateClientArgs(config *viper.Viper, prefix string) *ClientArgs {
 	}
 
 	return &ClientArgs{
-		ClusterMode:   clusterMode,
-		Url:           url,
-		EnableMetrics: enableMetrics,
-		EnableTracing: enableTracing,
+		ClusterMode:        clusterMode,
+		Url:                url,
+		EnableMetrics:      enableMetrics,
+		EnableTracing:      enableTracing,
+		SentinelMasterName: sentinelMasterName,
+		SentinelAddrs:      sentinelAddrs,
+		SentinelPassword:   sentinelPassword,
+		ReplicaReads:       replicaReads,
+		ReplicaUrl:         replicaUrl,
 	}
 }
 
@@ -158,16 +196,100 @@ func (c *Client) Connect(url string) error {
 
 // ConnectCluster to Redis cluster
 func (c *Client) ConnectCluster(url string) error {
+	return c.connectCluster(url, false)
+}
+
+func (c *Client) connectCluster(url string, readOnly bool) error {
 	opts, err := redis.ParseClusterURL(url)
 	if err != nil {
 		return err
 	}
+	if readOnly {
+		opts.ReadOnly = true
+	}
 
 	c.Instance = redis.NewClusterClient(opts)
 
 	return nil
 }
 
+// ConnectSentinel to the Redis master named in args through sentinel. With replica reads,
+// read-only commands go to a random node among the master and its replicas
+func (c *Client) ConnectSentinel(args *ClientArgs) error {
+	opts := &redis.Options{}
+	if args.Url != "" {
+		var err error
+		opts, err = redis.ParseURL(args.Url)
+		if err != nil {
+			return err
+		}
+	}
+
+	failoverOpts := &redis.FailoverOptions{
+		MasterName:       args.SentinelMasterName,
+		SentinelAddrs:    args.SentinelAddrs,
+		SentinelPassword: args.SentinelPassword,
+		Username:         opts.Username,
+		Password:         opts.Password,
+		DB:               opts.DB,
+		MaxRetries:       opts.MaxRetries,
+		DialTimeout:      opts.DialTimeout,
+		ReadTimeout:      opts.ReadTimeout,
+		WriteTimeout:     opts.WriteTimeout,
+		PoolSize:         opts.PoolSize,
+		TLSConfig:        opts.TLSConfig,
+	}
+	if args.ReplicaReads {
+		failoverOpts.RouteRandomly = true
+		c.Instance = redis.NewFailoverClusterClient(failoverOpts)
+	} else {
+		c.Instance = redis.NewFailoverClient(failoverOpts)
+	}
+
+	return nil
+}
+
+// ConnectReplica to a Redis primary and its replica, sending read-only commands to the
+// replica. The pair is described as a single shard cluster, which is how go-redis routes
+// commands to replicas without sentinel
+func (c *Client) ConnectReplica(url, replicaUrl string) error {
+	if replicaUrl == "" {
+		return fmt.Errorf("replica reads need a sentinel master name or a replica url")
+	}
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return err
+	}
+	replicaOpts, err := redis.ParseURL(replicaUrl)
+	if err != nil {
+		return err
+	}
+	if opts.DB != 0 {
+		return fmt.Errorf("replica reads only support db 0")
+	}
+
+	c.Instance = redis.NewClusterClient(&redis.ClusterOptions{
+		ClusterSlots: func(ctx context.Context) ([]redis.ClusterSlot, error) {
+			return []redis.ClusterSlot{{
+				Start: 0,
+				End:   ClusterSlots - 1,
+				Nodes: []redis.ClusterNode{{Addr: opts.Addr}, {Addr: replicaOpts.Addr}},
+			}}, nil
+		},
+		ReadOnly:     true,
+		Username:     opts.Username,
+		Password:     opts.Password,
+		MaxRetries:   opts.MaxRetries,
+		DialTimeout:  opts.DialTimeout,
+		ReadTimeout:  opts.ReadTimeout,
+		WriteTimeout: opts.WriteTimeout,
+		PoolSize:     opts.PoolSize,
+		TLSConfig:    opts.TLSConfig,
+	})
+
+	return nil
+}
+
 // IsConnected determines if the client is connected to redis
 func (c *Client) IsConnected(ctx context.Context) (bool, error) {
 	result := c.Instance.Ping(ctx)
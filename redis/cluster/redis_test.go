@@ -0,0 +1,125 @@
+/*
+ * Copyright (c) 2016 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package cluster
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/redis/scripting"
+)
+
+var _ = Describe("Client", func() {
+	Describe("[Unit]", func() {
+		It("should read sentinel and replica settings", func() {
+			config := viper.New()
+			config.Set("redis.url", "redis://:pass@localhost:6379")
+			config.Set("redis.sentinel.masterName", "mymaster")
+			config.Set("redis.sentinel.addrs", []string{"localhost:26379"})
+			config.Set("redis.sentinel.password", "sentinelpass")
+			config.Set("redis.replicaReads", true)
+			config.Set("redis.replicaUrl", "redis://replica:6379")
+
+			args := CreateClientArgs(config, "redis")
+			Expect(args.SentinelMasterName).To(Equal("mymaster"))
+			Expect(args.SentinelAddrs).To(Equal([]string{"localhost:26379"}))
+			Expect(args.SentinelPassword).To(Equal("sentinelpass"))
+			Expect(args.ReplicaReads).To(BeTrue())
+			Expect(args.ReplicaUrl).To(Equal("redis://replica:6379"))
+		})
+
+		It("should connect through sentinel", func() {
+			client, err := NewClient(&ClientArgs{
+				Url:                "redis://:pass@localhost:6379/2",
+				SentinelMasterName: "mymaster",
+				SentinelAddrs:      []string{"localhost:26379"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			defer client.Instance.Close()
+			Expect(client.Instance).To(BeAssignableToTypeOf(&redis.Client{}))
+			Expect(client.Instance.(*redis.Client).Options().DB).To(Equal(2))
+		})
+
+		It("should route reads to replicas discovered by sentinel", func() {
+			client, err := NewClient(&ClientArgs{
+				SentinelMasterName: "mymaster",
+				SentinelAddrs:      []string{"localhost:26379"},
+				ReplicaReads:       true,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			defer client.Instance.Close()
+			Expect(client.Instance.(*redis.ClusterClient).Options().RouteRandomly).To(BeTrue())
+		})
+
+		It("should route reads to the replicas of a cluster", func() {
+			client, err := NewClient(&ClientArgs{
+				Url:          "redis://localhost:7000",
+				ClusterMode:  true,
+				ReplicaReads: true,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			defer client.Instance.Close()
+			Expect(client.Instance.(*redis.ClusterClient).Options().ReadOnly).To(BeTrue())
+		})
+
+		It("should route reads to the replica url", func() {
+			client, err := NewClient(&ClientArgs{
+				Url:          "redis://localhost:6379",
+				ReplicaReads: true,
+				ReplicaUrl:   "redis://replica:6379",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			defer client.Instance.Close()
+
+			options := client.Instance.(*redis.ClusterClient).Options()
+			Expect(options.ReadOnly).To(BeTrue())
+			slots, err := options.ClusterSlots(context.Background())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(slots).To(Equal([]redis.ClusterSlot{{
+				Start: 0,
+				End:   ClusterSlots - 1,
+				Nodes: []redis.ClusterNode{{Addr: "localhost:6379"}, {Addr: "replica:6379"}},
+			}}))
+		})
+
+		It("should preload the scripts of a registry", func() {
+			scripts := scripting.NewRegistry(nil)
+			scripts.Register("one", "return 1")
+			_, err := NewClient(&ClientArgs{Url: "redis://localhost:1", Scripts: scripts})
+			Expect(err).To(MatchError(ContainSubstring("failed to load redis scripts")))
+		})
+
+		It("should need a replica url for replica reads without sentinel or cluster", func() {
+			_, err := NewClient(&ClientArgs{Url: "redis://localhost:6379", ReplicaReads: true})
+			Expect(err).To(MatchError("replica reads need a sentinel master name or a replica url"))
+		})
+
+		It("should only support db 0 with a replica url", func() {
+			_, err := NewClient(&ClientArgs{Url: "redis://localhost:6379/1", ReplicaReads: true, ReplicaUrl: "redis://replica:6379"})
+			Expect(err).To(MatchError("replica reads only support db 0"))
+		})
+	})
+})
@@ -37,6 +37,7 @@ type TraceWrapper interface {
 //RedisClient represents the contract for a redis client
 type RedisClient interface {
 	BLPop(timeout time.Duration, keys ...string) *redis.StringSliceCmd
+	BRPopLPush(source, destination string, timeout time.Duration) *redis.StringCmd
 	Close() error
 	Context() context.Context
 	Del(keys ...string) *redis.IntCmd
@@ -51,7 +52,9 @@ type RedisClient interface {
 	HMSet(string, map[string]interface{}) *redis.StatusCmd
 	HSet(key, field string, value interface{}) *redis.BoolCmd
 	MGet(keys ...string) *redis.SliceCmd
+	LPush(key string, values ...interface{}) *redis.IntCmd
 	LRange(key string, start, stop int64) *redis.StringSliceCmd
+	LRem(key string, count int64, value interface{}) *redis.IntCmd
 	Ping() *redis.StatusCmd
 	RPopLPush(source string, destination string) *redis.StringCmd
 	RPush(key string, values ...interface{}) *redis.IntCmd
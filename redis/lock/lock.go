@@ -0,0 +1,391 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+// Package lock implements distributed locks on redis with fencing tokens, lease renewal
+// and a Redlock quorum mode spanning several independent redis nodes. Fencing tokens
+// are only issued by single node lockers, Redlock gives no ordering between locks
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/redis/scripting"
+)
+
+var (
+	// ErrNotObtained is returned when a lock could not be obtained before the context was done
+	ErrNotObtained = errors.New("lock not obtained")
+	// ErrNotHeld is returned when extending or releasing a lock that is no longer held
+	ErrNotHeld = errors.New("lock not held")
+	// ErrNoFencingToken is returned for the fencing token of a lock obtained on several
+	// nodes, their counters are independent so no token would be ordered across locks
+	ErrNoFencingToken = errors.New("fencing tokens require a single redis node")
+)
+
+// obtainScript sets the lock key if it is free and increments the fencing counter when
+// one is given, expiring it after ARGV[3] milliseconds if positive. Both keys share a hash
+// tag so the script also runs on cluster mode
+var obtainScript = scripting.NewScript("lock.obtain", `
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	if #KEYS > 1 then
+		local token = redis.call("INCR", KEYS[2])
+		if tonumber(ARGV[3]) > 0 then
+			redis.call("PEXPIRE", KEYS[2], ARGV[3])
+		end
+		return token
+	end
+	return 1
+end
+return false
+`)
+
+var extendScript = scripting.NewScript("lock.extend", `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+var releaseScript = scripting.NewScript("lock.release", `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Options configures how locks are obtained and kept
+type Options struct {
+	// Prefix is prepended to every lock key
+	Prefix string
+	// TTL is the lease of the lock, it expires if not extended or renewed before that
+	TTL time.Duration
+	// RetryInterval is the time waited between attempts to obtain a lock
+	RetryInterval time.Duration
+	// AutoRenew extends the lease in background every RenewInterval until the lock is released
+	AutoRenew     bool
+	RenewInterval time.Duration
+	// DriftFactor is the fraction of the TTL reserved for clock drift between nodes
+	DriftFactor float64
+	// FenceTTL expires the fencing counter of a key when it is not locked for that long.
+	// Tokens restart from 1 once the counter expires, so it must outlive the last token any
+	// guarded resource still compares against. Zero keeps every counter forever
+	FenceTTL time.Duration
+}
+
+// Locker obtains locks on one redis node, or on a quorum of independent nodes (Redlock)
+// when created with several clients
+type Locker struct {
+	Options *Options
+	clients []scripting.Evaler
+}
+
+// NewLocker creates a Locker with options read from the given config prefix
+func NewLocker(prefix string, config *viper.Viper, clients ...scripting.Evaler) (*Locker, error) {
+	loadConfigurationDefaults(prefix, config)
+	options := &Options{
+		Prefix:        config.GetString(fmt.Sprintf("%s.prefix", prefix)),
+		TTL:           config.GetDuration(fmt.Sprintf("%s.ttl", prefix)),
+		RetryInterval: config.GetDuration(fmt.Sprintf("%s.retryInterval", prefix)),
+		AutoRenew:     config.GetBool(fmt.Sprintf("%s.autoRenew", prefix)),
+		RenewInterval: config.GetDuration(fmt.Sprintf("%s.renewInterval", prefix)),
+		DriftFactor:   config.GetFloat64(fmt.Sprintf("%s.driftFactor", prefix)),
+		FenceTTL:      config.GetDuration(fmt.Sprintf("%s.fenceTTL", prefix)),
+	}
+	return NewLockerFromOptions(options, clients...)
+}
+
+func loadConfigurationDefaults(prefix string, config *viper.Viper) {
+	config.SetDefault(fmt.Sprintf("%s.prefix", prefix), "lock:")
+	config.SetDefault(fmt.Sprintf("%s.ttl", prefix), "10s")
+	config.SetDefault(fmt.Sprintf("%s.retryInterval", prefix), "100ms")
+	config.SetDefault(fmt.Sprintf("%s.autoRenew", prefix), false)
+	config.SetDefault(fmt.Sprintf("%s.renewInterval", prefix), 0)
+	config.SetDefault(fmt.Sprintf("%s.driftFactor", prefix), 0.01)
+	config.SetDefault(fmt.Sprintf("%s.fenceTTL", prefix), 0)
+}
+
+// NewLockerFromOptions creates a Locker with the given options. RenewInterval defaults to a third of the TTL
+func NewLockerFromOptions(options *Options, clients ...scripting.Evaler) (*Locker, error) {
+	if options == nil {
+		return nil, fmt.Errorf("NewLockerFromOptions must have non-nil options")
+	}
+	if len(clients) == 0 {
+		return nil, fmt.Errorf("at least one redis client is required")
+	}
+	if options.TTL <= 0 {
+		return nil, fmt.Errorf("lock ttl must be positive")
+	}
+	if options.RetryInterval <= 0 {
+		return nil, fmt.Errorf("lock retry interval must be positive")
+	}
+	if options.RenewInterval <= 0 {
+		options.RenewInterval = options.TTL / 3
+	}
+	if options.RenewInterval >= options.TTL {
+		return nil, fmt.Errorf("lock renew interval must be shorter than the ttl")
+	}
+	if options.FenceTTL < 0 {
+		return nil, fmt.Errorf("lock fence ttl must not be negative")
+	}
+	return &Locker{Options: options, clients: clients}, nil
+}
+
+// Quorum is the number of nodes that must agree for a lock to be obtained
+func (l *Locker) Quorum() int {
+	return len(l.clients)/2 + 1
+}
+
+func (l *Locker) keys(key string) []string {
+	lockKey := fmt.Sprintf("%s{%s}", l.Options.Prefix, key)
+	return []string{lockKey, lockKey + ":fence"}
+}
+
+// Obtain blocks until key is locked or ctx is done, in which case ErrNotObtained is returned.
+// ctx only bounds the wait, the lock is kept until released or until its lease expires
+func (l *Locker) Obtain(ctx context.Context, key string) (*Lock, error) {
+	for {
+		lock, err := l.TryObtain(ctx, key)
+		if err != nil || lock != nil {
+			return lock, err
+		}
+		timer := time.NewTimer(l.Options.RetryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ErrNotObtained
+		case <-timer.C:
+		}
+	}
+}
+
+// TryObtain makes a single attempt to lock key, returning a nil lock if it is held elsewhere
+func (l *Locker) TryObtain(ctx context.Context, key string) (*Lock, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, ErrNotObtained
+	}
+	value, err := randomValue()
+	if err != nil {
+		return nil, err
+	}
+	keys := l.keys(key)
+	obtainKeys := keys
+	if len(l.clients) > 1 {
+		obtainKeys = keys[:1]
+	}
+	start := time.Now()
+
+	var tokens []int64
+	var lastErr error
+	for _, client := range l.clients {
+		res, err := obtainScript.Run(ctx, client, obtainKeys, value, l.Options.TTL.Milliseconds(), l.Options.FenceTTL.Milliseconds())
+		if err != nil {
+			if !scripting.IsNil(err) {
+				lastErr = err
+			}
+			continue
+		}
+		if token, ok := res.(int64); ok {
+			tokens = append(tokens, token)
+		}
+	}
+
+	until := start.Add(l.Options.TTL - l.drift())
+	if len(tokens) < l.Quorum() || !time.Now().Before(until) {
+		l.release(context.Background(), keys, value)
+		if len(tokens) == 0 && len(l.clients) == 1 && lastErr != nil {
+			return nil, lastErr
+		}
+		return nil, nil
+	}
+
+	lock := &Lock{
+		Key:    key,
+		locker: l,
+		keys:   keys,
+		value:  value,
+		until:  until,
+		lost:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if len(l.clients) == 1 {
+		lock.token = tokens[0]
+	}
+	if l.Options.AutoRenew {
+		go lock.renew()
+	}
+	return lock, nil
+}
+
+// drift is the time reserved for clock drift, as in the Redlock algorithm
+func (l *Locker) drift() time.Duration {
+	return time.Duration(float64(l.Options.TTL)*l.Options.DriftFactor) + 2*time.Millisecond
+}
+
+// extend resets the lease on every node holding value, returning whether a quorum still holds it
+func (l *Locker) extend(ctx context.Context, keys []string, value string) (bool, error) {
+	held := 0
+	var lastErr error
+	for _, client := range l.clients {
+		res, err := extendScript.Run(ctx, client, keys[:1], value, l.Options.TTL.Milliseconds())
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if n, ok := res.(int64); ok && n == 1 {
+			held++
+		}
+	}
+	if held >= l.Quorum() {
+		return true, nil
+	}
+	return false, lastErr
+}
+
+// release deletes the lock key on every node where it still holds value
+func (l *Locker) release(ctx context.Context, keys []string, value string) (int, error) {
+	released := 0
+	var lastErr error
+	for _, client := range l.clients {
+		res, err := releaseScript.Run(ctx, client, keys[:1], value)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if n, ok := res.(int64); ok && n == 1 {
+			released++
+		}
+	}
+	return released, lastErr
+}
+
+// Lock is a lock held on a key
+type Lock struct {
+	// Key is the locked key, without the locker prefix
+	Key string
+
+	locker *Locker
+	token  int64
+	keys   []string
+	value  string
+
+	mutex    sync.Mutex
+	until    time.Time
+	released bool
+	lost     chan struct{}
+	done     chan struct{}
+}
+
+// FencingToken returns a token that increases every time Key is locked. Resources guarded
+// by the lock should reject writes carrying a token lower than the last one they accepted.
+// ErrNoFencingToken is returned for locks obtained on several nodes
+func (k *Lock) FencingToken() (int64, error) {
+	if len(k.locker.clients) > 1 {
+		return 0, ErrNoFencingToken
+	}
+	return k.token, nil
+}
+
+// Until returns the time the lease is guaranteed to be held until
+func (k *Lock) Until() time.Time {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	return k.until
+}
+
+// Lost is closed when automatic renewal fails and the lease can no longer be guaranteed
+func (k *Lock) Lost() <-chan struct{} {
+	return k.lost
+}
+
+// Extend resets the lease of the lock to the locker TTL
+func (k *Lock) Extend(ctx context.Context) error {
+	start := time.Now()
+	held, err := k.locker.extend(ctx, k.keys, k.value)
+	if !held {
+		if err != nil {
+			return err
+		}
+		return ErrNotHeld
+	}
+	k.mutex.Lock()
+	k.until = start.Add(k.locker.Options.TTL - k.locker.drift())
+	k.mutex.Unlock()
+	return nil
+}
+
+// Release unlocks the key and stops automatic renewal. ErrNotHeld is returned
+// if the lease had already expired
+func (k *Lock) Release(ctx context.Context) error {
+	k.mutex.Lock()
+	if k.released {
+		k.mutex.Unlock()
+		return ErrNotHeld
+	}
+	k.released = true
+	close(k.done)
+	k.mutex.Unlock()
+
+	released, err := k.locker.release(ctx, k.keys, k.value)
+	if released > 0 {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return ErrNotHeld
+}
+
+// renew extends the lease every RenewInterval until the lock is released, or closes
+// lost when the lease could not be extended before it expired
+func (k *Lock) renew() {
+	ticker := time.NewTicker(k.locker.Options.RenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-k.done:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithDeadline(context.Background(), k.Until())
+		err := k.Extend(ctx)
+		cancel()
+		if err == ErrNotHeld || (err != nil && !time.Now().Before(k.Until())) {
+			close(k.lost)
+			return
+		}
+	}
+}
+
+func randomValue() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,296 @@
+/*
+ * Copyright (c) 2016 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/redis/scripting"
+)
+
+// fakeNode is an in-memory redis node that understands the lock scripts
+type fakeNode struct {
+	mutex    sync.Mutex
+	values   map[string]string
+	expires  map[string]time.Time
+	counters map[string]int64
+	down     bool
+}
+
+func newFakeNode() *fakeNode {
+	return &fakeNode{
+		values:   map[string]string{},
+		expires:  map[string]time.Time{},
+		counters: map[string]int64{},
+	}
+}
+
+func (n *fakeNode) get(key string) (string, bool) {
+	if exp, ok := n.expires[key]; ok && !time.Now().Before(exp) {
+		delete(n.values, key)
+		delete(n.expires, key)
+	}
+	v, ok := n.values[key]
+	return v, ok
+}
+
+func (n *fakeNode) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.down {
+		return nil, errors.New("connection refused")
+	}
+	switch script {
+	case obtainScript.Source:
+		if _, ok := n.get(keys[0]); ok {
+			return nil, scripting.ErrNil
+		}
+		n.values[keys[0]] = args[0].(string)
+		n.expires[keys[0]] = time.Now().Add(time.Duration(args[1].(int64)) * time.Millisecond)
+		if len(keys) == 1 {
+			return int64(1), nil
+		}
+		n.counters[keys[1]]++
+		if ttl := args[2].(int64); ttl > 0 {
+			n.expires[keys[1]] = time.Now().Add(time.Duration(ttl) * time.Millisecond)
+		}
+		return n.counters[keys[1]], nil
+	case extendScript.Source:
+		if v, ok := n.get(keys[0]); ok && v == args[0].(string) {
+			n.expires[keys[0]] = time.Now().Add(time.Duration(args[1].(int64)) * time.Millisecond)
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case releaseScript.Source:
+		if v, ok := n.get(keys[0]); ok && v == args[0].(string) {
+			delete(n.values, keys[0])
+			delete(n.expires, keys[0])
+			return int64(1), nil
+		}
+		return int64(0), nil
+	}
+	return nil, errors.New("unknown script")
+}
+
+func (n *fakeNode) EvalSha(ctx context.Context, sha1 string, keys []string, args ...interface{}) (interface{}, error) {
+	for _, script := range []*scripting.Script{obtainScript, extendScript, releaseScript} {
+		if script.SHA1 == sha1 {
+			return n.Eval(ctx, script.Source, keys, args...)
+		}
+	}
+	return nil, errors.New("NOSCRIPT No matching script. Please use EVAL.")
+}
+
+func (n *fakeNode) ScriptExists(ctx context.Context, hashes ...string) ([]bool, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (n *fakeNode) ScriptLoad(ctx context.Context, script string) (string, error) {
+	return "", errors.New("not implemented")
+}
+
+func (n *fakeNode) setDown(down bool) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.down = down
+}
+
+var _ = Describe("Lock", func() {
+	var node *fakeNode
+	var options *Options
+	ctx := context.Background()
+
+	BeforeEach(func() {
+		node = newFakeNode()
+		options = &Options{
+			Prefix:        "lock:",
+			TTL:           200 * time.Millisecond,
+			RetryInterval: 10 * time.Millisecond,
+			DriftFactor:   0.01,
+		}
+	})
+
+	Describe("[Unit]", func() {
+		It("should load options from config", func() {
+			config := viper.New()
+			config.Set("extensions.lock.ttl", "3s")
+			locker, err := NewLocker("extensions.lock", config, node)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(locker.Options.TTL).To(Equal(3 * time.Second))
+			Expect(locker.Options.RenewInterval).To(Equal(time.Second))
+			Expect(locker.Options.Prefix).To(Equal("lock:"))
+		})
+
+		It("should validate options", func() {
+			_, err := NewLockerFromOptions(options)
+			Expect(err).To(HaveOccurred())
+			options.TTL = 0
+			_, err = NewLockerFromOptions(options, node)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should expire the fencing counter after the fence ttl", func() {
+			locker, err := NewLockerFromOptions(options, node)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = locker.Obtain(ctx, "key")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(node.expires).NotTo(HaveKey("lock:{key}:fence"))
+
+			options.FenceTTL = time.Hour
+			_, err = locker.Obtain(ctx, "other")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(node.expires).To(HaveKey("lock:{other}:fence"))
+			Expect(node.expires["lock:{other}:fence"]).To(BeTemporally("~", time.Now().Add(time.Hour), time.Second))
+		})
+
+		It("should use a hash tag so both keys share a cluster slot", func() {
+			locker, err := NewLockerFromOptions(options, node)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(locker.keys("player:1")).To(Equal([]string{"lock:{player:1}", "lock:{player:1}:fence"}))
+		})
+
+		It("should obtain and release a lock with increasing fencing tokens", func() {
+			locker, err := NewLockerFromOptions(options, node)
+			Expect(err).NotTo(HaveOccurred())
+
+			first, err := locker.Obtain(ctx, "key")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(first.FencingToken()).To(BeEquivalentTo(1))
+
+			other, err := locker.TryObtain(ctx, "key")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(other).To(BeNil())
+
+			Expect(first.Release(ctx)).To(Succeed())
+			Expect(first.Release(ctx)).To(Equal(ErrNotHeld))
+
+			second, err := locker.Obtain(ctx, "key")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(second.FencingToken()).To(BeEquivalentTo(2))
+		})
+
+		It("should wait for the lock until the context is done", func() {
+			locker, err := NewLockerFromOptions(options, node)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = locker.Obtain(ctx, "key")
+			Expect(err).NotTo(HaveOccurred())
+
+			timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+			defer cancel()
+			_, err = locker.Obtain(timeout, "key")
+			Expect(err).To(Equal(ErrNotObtained))
+
+			lock, err := locker.Obtain(ctx, "key")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lock.FencingToken()).To(BeEquivalentTo(2))
+		})
+
+		It("should extend the lease", func() {
+			locker, err := NewLockerFromOptions(options, node)
+			Expect(err).NotTo(HaveOccurred())
+			lock, err := locker.Obtain(ctx, "key")
+			Expect(err).NotTo(HaveOccurred())
+			until := lock.Until()
+			time.Sleep(10 * time.Millisecond)
+			Expect(lock.Extend(ctx)).To(Succeed())
+			Expect(lock.Until()).To(BeTemporally(">", until))
+
+			time.Sleep(options.TTL)
+			Expect(lock.Extend(ctx)).To(Equal(ErrNotHeld))
+		})
+
+		It("should renew the lease in background", func() {
+			options.AutoRenew = true
+			locker, err := NewLockerFromOptions(options, node)
+			Expect(err).NotTo(HaveOccurred())
+			lock, err := locker.Obtain(ctx, "key")
+			Expect(err).NotTo(HaveOccurred())
+
+			time.Sleep(2 * options.TTL)
+			other, err := locker.TryObtain(ctx, "key")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(other).To(BeNil())
+			Expect(lock.Release(ctx)).To(Succeed())
+			Consistently(lock.Lost(), 100*time.Millisecond).ShouldNot(BeClosed())
+		})
+
+		It("should signal a lost lease", func() {
+			options.AutoRenew = true
+			locker, err := NewLockerFromOptions(options, node)
+			Expect(err).NotTo(HaveOccurred())
+			lock, err := locker.Obtain(ctx, "key")
+			Expect(err).NotTo(HaveOccurred())
+
+			node.setDown(true)
+			Eventually(lock.Lost(), time.Second).Should(BeClosed())
+		})
+
+		Describe("Redlock", func() {
+			var nodes []*fakeNode
+			var locker *Locker
+
+			BeforeEach(func() {
+				nodes = []*fakeNode{newFakeNode(), newFakeNode(), newFakeNode()}
+				var err error
+				locker, err = NewLockerFromOptions(options, nodes[0], nodes[1], nodes[2])
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should obtain the lock on a quorum of nodes", func() {
+				nodes[2].setDown(true)
+				Expect(locker.Quorum()).To(Equal(2))
+				lock, err := locker.Obtain(ctx, "key")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(lock.Release(ctx)).To(Succeed())
+			})
+
+			It("should not obtain the lock without a quorum", func() {
+				nodes[1].setDown(true)
+				nodes[2].setDown(true)
+				lock, err := locker.TryObtain(ctx, "key")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(lock).To(BeNil())
+				_, held := nodes[0].get("lock:{key}")
+				Expect(held).To(BeFalse())
+			})
+
+			It("should not issue fencing tokens from diverging node counters", func() {
+				nodes[0].counters["lock:{key}:fence"] = 7
+				nodes[1].counters["lock:{key}:fence"] = 2
+				lock, err := locker.Obtain(ctx, "key")
+				Expect(err).NotTo(HaveOccurred())
+				_, err = lock.FencingToken()
+				Expect(err).To(Equal(ErrNoFencingToken))
+				Expect(nodes[0].counters["lock:{key}:fence"]).To(BeEquivalentTo(7))
+				Expect(nodes[1].counters["lock:{key}:fence"]).To(BeEquivalentTo(2))
+				Expect(nodes[2].counters).To(BeEmpty())
+			})
+		})
+	})
+})
@@ -93,6 +93,20 @@ func (mr *MockRedisClientMockRecorder) BLPop(timeout interface{}, keys ...interf
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BLPop", reflect.TypeOf((*MockRedisClient)(nil).BLPop), varargs...)
 }
 
+// BRPopLPush mocks base method.
+func (m *MockRedisClient) BRPopLPush(source, destination string, timeout time.Duration) *redis.StringCmd {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BRPopLPush", source, destination, timeout)
+	ret0, _ := ret[0].(*redis.StringCmd)
+	return ret0
+}
+
+// BRPopLPush indicates an expected call of BRPopLPush.
+func (mr *MockRedisClientMockRecorder) BRPopLPush(source, destination, timeout interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BRPopLPush", reflect.TypeOf((*MockRedisClient)(nil).BRPopLPush), source, destination, timeout)
+}
+
 // Close mocks base method.
 func (m *MockRedisClient) Close() error {
 	m.ctrl.T.Helper()
@@ -303,6 +317,25 @@ func (mr *MockRedisClientMockRecorder) HSet(key, field, value interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HSet", reflect.TypeOf((*MockRedisClient)(nil).HSet), key, field, value)
 }
 
+// LPush mocks base method.
+func (m *MockRedisClient) LPush(key string, values ...interface{}) *redis.IntCmd {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{key}
+	for _, a := range values {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "LPush", varargs...)
+	ret0, _ := ret[0].(*redis.IntCmd)
+	return ret0
+}
+
+// LPush indicates an expected call of LPush.
+func (mr *MockRedisClientMockRecorder) LPush(key interface{}, values ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{key}, values...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LPush", reflect.TypeOf((*MockRedisClient)(nil).LPush), varargs...)
+}
+
 // LRange mocks base method.
 func (m *MockRedisClient) LRange(key string, start, stop int64) *redis.StringSliceCmd {
 	m.ctrl.T.Helper()
@@ -317,6 +350,20 @@ func (mr *MockRedisClientMockRecorder) LRange(key, start, stop interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LRange", reflect.TypeOf((*MockRedisClient)(nil).LRange), key, start, stop)
 }
 
+// LRem mocks base method.
+func (m *MockRedisClient) LRem(key string, count int64, value interface{}) *redis.IntCmd {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LRem", key, count, value)
+	ret0, _ := ret[0].(*redis.IntCmd)
+	return ret0
+}
+
+// LRem indicates an expected call of LRem.
+func (mr *MockRedisClientMockRecorder) LRem(key, count, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LRem", reflect.TypeOf((*MockRedisClient)(nil).LRem), key, count, value)
+}
+
 // MGet mocks base method.
 func (m *MockRedisClient) MGet(keys ...string) *redis.SliceCmd {
 	m.ctrl.T.Helper()
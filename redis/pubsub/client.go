@@ -0,0 +1,193 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package pubsub
+
+import (
+	"context"
+
+	redisv6 "github.com/go-redis/redis"
+	redisv8 "github.com/go-redis/redis/v8"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+// Message is a message received from a channel
+type Message struct {
+	Channel string
+	// Pattern is the subscribed pattern the channel matched, empty for channel subscriptions
+	Pattern string
+	Payload string
+}
+
+// Subscription receives the messages of subscribed channels and patterns
+type Subscription interface {
+	ReceiveMessage(ctx context.Context) (*Message, error)
+	Close() error
+}
+
+// Client is the contract for publishing and subscribing on any of the supported redis clients
+type Client interface {
+	Publish(ctx context.Context, channel string, message interface{}) (int64, error)
+	Subscribe(ctx context.Context, channels, patterns []string) (Subscription, error)
+}
+
+// V6Client is the part of a go-redis v6 client used for pub/sub, such as *redis.Client
+type V6Client interface {
+	Publish(channel string, message interface{}) *redisv6.IntCmd
+	Subscribe(channels ...string) *redisv6.PubSub
+}
+
+// V8Client is the part of a go-redis v8 client used for pub/sub, such as redis.UniversalClient
+type V8Client interface {
+	Publish(ctx context.Context, channel string, message interface{}) *redisv8.IntCmd
+	Subscribe(ctx context.Context, channels ...string) *redisv8.PubSub
+}
+
+// V9Client is the part of a go-redis v9 client used for pub/sub, such as redis.UniversalClient
+type V9Client interface {
+	Publish(ctx context.Context, channel string, message interface{}) *redisv9.IntCmd
+	Subscribe(ctx context.Context, channels ...string) *redisv9.PubSub
+}
+
+// FromV6 adapts a go-redis v6 client to a Client. The v6 client has no per command context,
+// receiving is interrupted by closing the subscription instead
+func FromV6(client V6Client) Client {
+	return &v6Client{client: client}
+}
+
+// FromV8 adapts a go-redis v8 client, as returned by redis/v8.NewClient, to a Client
+func FromV8(client V8Client) Client {
+	return &v8Client{client: client}
+}
+
+// FromV9 adapts a go-redis v9 client, such as the Instance of redis/cluster.Client, to a Client
+func FromV9(client V9Client) Client {
+	return &v9Client{client: client}
+}
+
+type v6Client struct {
+	client V6Client
+}
+
+func (c *v6Client) Publish(ctx context.Context, channel string, message interface{}) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.client.Publish(channel, message).Result()
+}
+
+func (c *v6Client) Subscribe(ctx context.Context, channels, patterns []string) (Subscription, error) {
+	ps := c.client.Subscribe(channels...)
+	if len(patterns) > 0 {
+		if err := ps.PSubscribe(patterns...); err != nil {
+			ps.Close()
+			return nil, err
+		}
+	}
+	return &v6Subscription{ps: ps}, nil
+}
+
+type v6Subscription struct {
+	ps *redisv6.PubSub
+}
+
+func (s *v6Subscription) ReceiveMessage(ctx context.Context) (*Message, error) {
+	m, err := s.ps.ReceiveMessage()
+	if err != nil {
+		return nil, err
+	}
+	return &Message{Channel: m.Channel, Pattern: m.Pattern, Payload: m.Payload}, nil
+}
+
+func (s *v6Subscription) Close() error {
+	return s.ps.Close()
+}
+
+type v8Client struct {
+	client V8Client
+}
+
+func (c *v8Client) Publish(ctx context.Context, channel string, message interface{}) (int64, error) {
+	return c.client.Publish(ctx, channel, message).Result()
+}
+
+func (c *v8Client) Subscribe(ctx context.Context, channels, patterns []string) (Subscription, error) {
+	ps := c.client.Subscribe(ctx, channels...)
+	if len(patterns) > 0 {
+		if err := ps.PSubscribe(ctx, patterns...); err != nil {
+			ps.Close()
+			return nil, err
+		}
+	}
+	return &v8Subscription{ps: ps}, nil
+}
+
+type v8Subscription struct {
+	ps *redisv8.PubSub
+}
+
+func (s *v8Subscription) ReceiveMessage(ctx context.Context) (*Message, error) {
+	m, err := s.ps.ReceiveMessage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{Channel: m.Channel, Pattern: m.Pattern, Payload: m.Payload}, nil
+}
+
+func (s *v8Subscription) Close() error {
+	return s.ps.Close()
+}
+
+type v9Client struct {
+	client V9Client
+}
+
+func (c *v9Client) Publish(ctx context.Context, channel string, message interface{}) (int64, error) {
+	return c.client.Publish(ctx, channel, message).Result()
+}
+
+func (c *v9Client) Subscribe(ctx context.Context, channels, patterns []string) (Subscription, error) {
+	ps := c.client.Subscribe(ctx, channels...)
+	if len(patterns) > 0 {
+		if err := ps.PSubscribe(ctx, patterns...); err != nil {
+			ps.Close()
+			return nil, err
+		}
+	}
+	return &v9Subscription{ps: ps}, nil
+}
+
+type v9Subscription struct {
+	ps *redisv9.PubSub
+}
+
+func (s *v9Subscription) ReceiveMessage(ctx context.Context) (*Message, error) {
+	m, err := s.ps.ReceiveMessage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{Channel: m.Channel, Pattern: m.Pattern, Payload: m.Payload}, nil
+}
+
+func (s *v9Subscription) Close() error {
+	return s.ps.Close()
+}
@@ -0,0 +1,197 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+// Package pubsub implements traced publishing and resilient subscriptions on the redis clients
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/tracing"
+	tredis "github.com/topfreegames/extensions/v9/tracing/redis"
+)
+
+// Handler processes a message received from a subscription
+type Handler func(ctx context.Context, m *Message) error
+
+// Publish publishes message to channel within a span, returning how many subscribers received it
+func Publish(ctx context.Context, client Client, channel string, message interface{}) (int64, error) {
+	span, ctx := tredis.StartPublishSpan(ctx, channel)
+	defer span.Finish()
+	defer tracing.LogPanic(span)
+
+	receivers, err := client.Publish(ctx, channel, message)
+	if err != nil {
+		tracing.LogError(span, err.Error())
+	}
+	return receivers, err
+}
+
+// Subscriber delivers the messages of channels and patterns to a handler, subscribing
+// again with exponential backoff whenever the subscription fails
+type Subscriber struct {
+	Config     *viper.Viper
+	Logger     *logrus.Logger
+	Client     Client
+	Channels   []string
+	Patterns   []string
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// NewSubscriber for creating a new Subscriber instance
+func NewSubscriber(
+	config *viper.Viper,
+	logger *logrus.Logger,
+	client Client,
+) (*Subscriber, error) {
+	return NewSubscriberWithPrefix(config, logger, "extensions.redispubsub", client)
+}
+
+// NewSubscriberWithPrefix for creating a new Subscriber instance
+func NewSubscriberWithPrefix(
+	config *viper.Viper,
+	logger *logrus.Logger,
+	prefix string,
+	client Client,
+) (*Subscriber, error) {
+	if prefix != "" {
+		prefix += "."
+	}
+	if client == nil {
+		return nil, fmt.Errorf("a redis client is required")
+	}
+	s := &Subscriber{
+		Config: config,
+		Logger: logger,
+		Client: client,
+	}
+	err := s.configure(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Subscriber) loadConfigurationDefaults(prefix string) {
+	s.Config.SetDefault(prefix+"channels", []string{})
+	s.Config.SetDefault(prefix+"patterns", []string{})
+	s.Config.SetDefault(prefix+"backoff", 100)
+	s.Config.SetDefault(prefix+"maxBackoff", 5000)
+}
+
+func (s *Subscriber) configure(prefix string) error {
+	s.loadConfigurationDefaults(prefix)
+	s.Channels = s.Config.GetStringSlice(prefix + "channels")
+	s.Patterns = s.Config.GetStringSlice(prefix + "patterns")
+	s.Backoff = time.Duration(s.Config.GetInt(prefix+"backoff")) * time.Millisecond
+	s.MaxBackoff = time.Duration(s.Config.GetInt(prefix+"maxBackoff")) * time.Millisecond
+
+	if len(s.Channels) == 0 && len(s.Patterns) == 0 {
+		return fmt.Errorf("%schannels or %spatterns must be set", prefix, prefix)
+	}
+	if s.Backoff <= 0 || s.MaxBackoff < s.Backoff {
+		return fmt.Errorf("%sbackoff must be positive and not greater than %smaxBackoff", prefix, prefix)
+	}
+	return nil
+}
+
+// Run subscribes and delivers every message to handler, each within its own span, until
+// ctx is done. Messages published while the subscription is being restored are lost, as
+// redis pub/sub does not keep messages. Handler errors are logged and the message dropped
+func (s *Subscriber) Run(ctx context.Context, handler Handler) error {
+	l := s.Logger.WithFields(logrus.Fields{
+		"method":   "Run",
+		"channels": s.Channels,
+		"patterns": s.Patterns,
+	})
+
+	backoff := s.Backoff
+	for ctx.Err() == nil {
+		received, err := s.subscribe(ctx, handler)
+		if ctx.Err() != nil {
+			break
+		}
+		if received {
+			backoff = s.Backoff
+		}
+		l.WithError(err).Warnf("subscription failed, subscribing again in %s", backoff)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+		backoff *= 2
+		if backoff > s.MaxBackoff {
+			backoff = s.MaxBackoff
+		}
+	}
+	l.Info("subscriber stopped")
+	return nil
+}
+
+// subscribe receives messages until the subscription fails, returning whether any message was received
+func (s *Subscriber) subscribe(ctx context.Context, handler Handler) (bool, error) {
+	sub, err := s.Client.Subscribe(ctx, s.Channels, s.Patterns)
+	if err != nil {
+		return false, err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		sub.Close()
+	}()
+
+	received := false
+	for {
+		m, err := sub.ReceiveMessage(ctx)
+		if err != nil {
+			return received, err
+		}
+		received = true
+		s.handle(ctx, handler, m)
+	}
+}
+
+func (s *Subscriber) handle(ctx context.Context, handler Handler, m *Message) {
+	span, ctx := tredis.StartReceiveSpan(ctx, m.Channel, m.Pattern)
+	defer span.Finish()
+	defer tracing.LogPanic(span)
+
+	err := handler(ctx, m)
+	if err != nil {
+		tracing.LogError(span, err.Error())
+		s.Logger.WithFields(logrus.Fields{
+			"channel": m.Channel,
+			"pattern": m.Pattern,
+		}).WithError(err).Error("error handling message")
+	}
+}
@@ -0,0 +1,270 @@
+/*
+ * Copyright (c) 2016 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/spf13/viper"
+)
+
+type fakeSubscription struct {
+	messages chan *Message
+	closed   chan struct{}
+	once     sync.Once
+}
+
+func (s *fakeSubscription) ReceiveMessage(ctx context.Context) (*Message, error) {
+	select {
+	case m, ok := <-s.messages:
+		if !ok {
+			return nil, errors.New("connection reset")
+		}
+		return m, nil
+	case <-s.closed:
+		return nil, errors.New("pubsub: closed")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *fakeSubscription) Close() error {
+	s.once.Do(func() { close(s.closed) })
+	return nil
+}
+
+type fakeClient struct {
+	mutex         sync.Mutex
+	subscriptions chan *fakeSubscription
+	subscribeErrs []error
+	channels      []string
+	patterns      []string
+	published     map[string][]interface{}
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		subscriptions: make(chan *fakeSubscription, 10),
+		published:     map[string][]interface{}{},
+	}
+}
+
+func (c *fakeClient) Publish(ctx context.Context, channel string, message interface{}) (int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if channel == "broken" {
+		return 0, errors.New("connection refused")
+	}
+	c.published[channel] = append(c.published[channel], message)
+	return 1, nil
+}
+
+func (c *fakeClient) Subscribe(ctx context.Context, channels, patterns []string) (Subscription, error) {
+	c.mutex.Lock()
+	c.channels, c.patterns = channels, patterns
+	if len(c.subscribeErrs) > 0 {
+		err := c.subscribeErrs[0]
+		c.subscribeErrs = c.subscribeErrs[1:]
+		c.mutex.Unlock()
+		return nil, err
+	}
+	c.mutex.Unlock()
+	s := &fakeSubscription{messages: make(chan *Message, 10), closed: make(chan struct{})}
+	c.subscriptions <- s
+	return s, nil
+}
+
+var _ = Describe("PubSub", func() {
+	logger, _ := test.NewNullLogger()
+	var tracer *mocktracer.MockTracer
+	var client *fakeClient
+	var config *viper.Viper
+
+	newSubscriber := func() *Subscriber {
+		s, err := NewSubscriber(config, logger, client)
+		Expect(err).NotTo(HaveOccurred())
+		return s
+	}
+
+	run := func(ctx context.Context, s *Subscriber, handler Handler) chan error {
+		done := make(chan error, 1)
+		go func() {
+			defer GinkgoRecover()
+			done <- s.Run(ctx, handler)
+		}()
+		return done
+	}
+
+	BeforeEach(func() {
+		tracer = mocktracer.New()
+		opentracing.SetGlobalTracer(tracer)
+		client = newFakeClient()
+		config = viper.New()
+		config.Set("extensions.redispubsub.channels", []string{"events"})
+		config.Set("extensions.redispubsub.patterns", []string{"news.*"})
+		config.Set("extensions.redispubsub.backoff", 1)
+		config.Set("extensions.redispubsub.maxBackoff", 4)
+	})
+
+	AfterEach(func() {
+		opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+	})
+
+	Describe("[Unit]", func() {
+		It("should configure defaults", func() {
+			config = viper.New()
+			config.Set("extensions.redispubsub.channels", []string{"events"})
+			s := newSubscriber()
+			Expect(s.Channels).To(Equal([]string{"events"}))
+			Expect(s.Patterns).To(BeEmpty())
+			Expect(s.Backoff).To(Equal(100 * time.Millisecond))
+			Expect(s.MaxBackoff).To(Equal(5 * time.Second))
+		})
+
+		It("should fail without channels or patterns", func() {
+			_, err := NewSubscriber(viper.New(), logger, client)
+			Expect(err).To(MatchError(ContainSubstring("channels or extensions.redispubsub.patterns")))
+		})
+
+		It("should fail without a client", func() {
+			_, err := NewSubscriber(config, logger, nil)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should use a custom prefix", func() {
+			config.Set("custom.channels", []string{"other"})
+			s, err := NewSubscriberWithPrefix(config, logger, "custom", client)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(s.Channels).To(Equal([]string{"other"}))
+		})
+
+		It("should deliver messages within a receive span", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			received := make(chan *Message, 1)
+			done := run(ctx, newSubscriber(), func(ctx context.Context, m *Message) error {
+				Expect(opentracing.SpanFromContext(ctx)).NotTo(BeNil())
+				received <- m
+				return nil
+			})
+
+			var sub *fakeSubscription
+			Eventually(client.subscriptions).Should(Receive(&sub))
+			client.mutex.Lock()
+			Expect(client.channels).To(Equal([]string{"events"}))
+			Expect(client.patterns).To(Equal([]string{"news.*"}))
+			client.mutex.Unlock()
+
+			sub.messages <- &Message{Channel: "news.sports", Pattern: "news.*", Payload: "goal"}
+			Eventually(received).Should(Receive(Equal(&Message{Channel: "news.sports", Pattern: "news.*", Payload: "goal"})))
+
+			cancel()
+			Eventually(done).Should(Receive(BeNil()))
+			spans := tracer.FinishedSpans()
+			Expect(spans).To(HaveLen(1))
+			Expect(spans[0].OperationName).To(Equal("redis receive news.sports"))
+			Expect(spans[0].Tag("redis.pattern")).To(Equal("news.*"))
+		})
+
+		It("should log handler errors on the span and keep receiving", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			handled := make(chan string, 2)
+			done := run(ctx, newSubscriber(), func(ctx context.Context, m *Message) error {
+				handled <- m.Payload
+				return errors.New("failed")
+			})
+
+			var sub *fakeSubscription
+			Eventually(client.subscriptions).Should(Receive(&sub))
+			sub.messages <- &Message{Channel: "events", Payload: "a"}
+			sub.messages <- &Message{Channel: "events", Payload: "b"}
+			Eventually(handled).Should(Receive(Equal("a")))
+			Eventually(handled).Should(Receive(Equal("b")))
+
+			cancel()
+			Eventually(done).Should(Receive(BeNil()))
+			Expect(tracer.FinishedSpans()[0].Tag("error")).To(Equal(true))
+		})
+
+		It("should resubscribe when the subscription fails", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			client.subscribeErrs = []error{errors.New("connection refused")}
+			received := make(chan string, 1)
+			done := run(ctx, newSubscriber(), func(ctx context.Context, m *Message) error {
+				received <- m.Payload
+				return nil
+			})
+
+			var sub *fakeSubscription
+			Eventually(client.subscriptions).Should(Receive(&sub))
+			close(sub.messages)
+			Eventually(sub.closed).Should(BeClosed())
+
+			Eventually(client.subscriptions).Should(Receive(&sub))
+			sub.messages <- &Message{Channel: "events", Payload: "again"}
+			Eventually(received).Should(Receive(Equal("again")))
+
+			cancel()
+			Eventually(done).Should(Receive(BeNil()))
+			Eventually(sub.closed).Should(BeClosed())
+		})
+
+		It("should stop while waiting to resubscribe", func() {
+			config.Set("extensions.redispubsub.backoff", 60000)
+			config.Set("extensions.redispubsub.maxBackoff", 60000)
+			client.subscribeErrs = []error{errors.New("connection refused")}
+			ctx, cancel := context.WithCancel(context.Background())
+			done := run(ctx, newSubscriber(), func(ctx context.Context, m *Message) error {
+				return nil
+			})
+
+			Consistently(done, 50*time.Millisecond).ShouldNot(Receive())
+			cancel()
+			Eventually(done).Should(Receive(BeNil()))
+		})
+
+		It("should publish within a publish span", func() {
+			receivers, err := Publish(context.Background(), client, "events", "payload")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(receivers).To(Equal(int64(1)))
+			Expect(client.published["events"]).To(Equal([]interface{}{"payload"}))
+
+			spans := tracer.FinishedSpans()
+			Expect(spans).To(HaveLen(1))
+			Expect(spans[0].OperationName).To(Equal("redis publish events"))
+		})
+
+		It("should log publish errors on the span", func() {
+			_, err := Publish(context.Background(), client, "broken", "payload")
+			Expect(err).To(MatchError("connection refused"))
+			Expect(tracer.FinishedSpans()[0].Tag("error")).To(Equal(true))
+		})
+	})
+})
@@ -0,0 +1,88 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package queue
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Job is a message taken from the queue. It must be acknowledged with Ack once processed,
+// otherwise it is requeued by the reaper after the visibility timeout
+type Job struct {
+	ID         string    `json:"id"`
+	Payload    []byte    `json:"payload"`
+	Attempts   int       `json:"attempts"`
+	EnqueuedAt time.Time `json:"enqueuedAt"`
+
+	// raw is the encoded job as stored in the processing list
+	raw     string
+	queue   *Queue
+	ackOnce sync.Once
+	ackErr  error
+}
+
+func newJob(payload []byte) (*Job, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return &Job{
+		ID:         hex.EncodeToString(b),
+		Payload:    payload,
+		EnqueuedAt: time.Now().UTC(),
+	}, nil
+}
+
+func decodeJob(raw string) (*Job, error) {
+	j := &Job{raw: raw}
+	err := json.Unmarshal([]byte(raw), j)
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func (j *Job) encode() (string, error) {
+	data, err := json.Marshal(j)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Ack removes the job from the processing list, acknowledging more than once is a no-op
+func (j *Job) Ack() error {
+	j.ackOnce.Do(func() {
+		j.ackErr = j.queue.ack(j)
+	})
+	return j.ackErr
+}
+
+// Extend postpones the visibility timeout of the job, for jobs that take longer to process
+func (j *Job) Extend(timeout time.Duration) error {
+	return j.queue.setDeadline(j, time.Now().Add(timeout))
+}
@@ -0,0 +1,399 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+// Package queue implements a reliable work queue on redis lists, with a processing list per
+// consumer, visibility timeouts, a reaper requeueing stalled jobs and delayed jobs
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/redis/interfaces"
+	"github.com/topfreegames/extensions/v9/redis/scripting"
+)
+
+// takeScript moves the next job to the processing list of a consumer and adds it to the
+// inflight set with its visibility deadline, replying nil when the queue is empty
+var takeScript = scripting.NewScript("queue.take", `
+local raw = redis.call("RPOPLPUSH", KEYS[1], KEYS[2])
+if raw then
+	redis.call("ZADD", KEYS[3], ARGV[1], ARGV[2] .. raw)
+end
+return raw
+`)
+
+// Queue is a reliable work queue on redis lists. Jobs are pushed to the queue list and moved
+// atomically to the processing list of the consumer taking them, along with their visibility
+// deadline, where they stay until acknowledged. Jobs not acknowledged within the visibility
+// timeout are requeued by the reaper
+type Queue struct {
+	Config *viper.Viper
+	Logger *logrus.Logger
+	Client interfaces.RedisClient
+	// Name is the key of the queue list, the other keys of the queue are prefixed with it
+	Name string
+	// Consumer identifies this consumer, its processing list is requeued when it starts
+	Consumer    string
+	ChannelSize int
+	// PollTimeout is the time waited before polling an empty queue again
+	PollTimeout       time.Duration
+	VisibilityTimeout time.Duration
+	ReapInterval      time.Duration
+	// MaxAttempts moves jobs that timed out this many times to the dead list, 0 retries forever
+	MaxAttempts int
+	// MessageHandles delivers jobs to JobsChannel to be acknowledged once processed.
+	// Otherwise jobs are acknowledged as soon as their payload is delivered to MessagesChannel
+	MessageHandles                 bool
+	HandleAllMessagesBeforeExiting bool
+
+	msgChan           chan []byte
+	jobsChan          chan *Job
+	pendingMessagesWG *sync.WaitGroup
+	run               bool
+	runMutex          sync.Mutex
+	stopChan          chan struct{}
+}
+
+// NewQueue for creating a new Queue instance
+func NewQueue(
+	config *viper.Viper,
+	logger *logrus.Logger,
+	client interfaces.RedisClient,
+) (*Queue, error) {
+	return NewQueueWithPrefix(config, logger, "extensions.redisqueue", client)
+}
+
+// NewQueueWithPrefix for creating a new Queue instance
+func NewQueueWithPrefix(
+	config *viper.Viper,
+	logger *logrus.Logger,
+	prefix string,
+	client interfaces.RedisClient,
+) (*Queue, error) {
+	if prefix != "" {
+		prefix += "."
+	}
+	if client == nil {
+		return nil, fmt.Errorf("a redis client is required")
+	}
+	q := &Queue{
+		Config: config,
+		Logger: logger,
+		Client: client,
+	}
+	err := q.configure(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *Queue) loadConfigurationDefaults(prefix string) {
+	q.Config.SetDefault(prefix+"name", "queue")
+	q.Config.SetDefault(prefix+"consumer", "")
+	q.Config.SetDefault(prefix+"channelSize", 100)
+	q.Config.SetDefault(prefix+"pollTimeout", 100)
+	q.Config.SetDefault(prefix+"visibilityTimeout", 30000)
+	q.Config.SetDefault(prefix+"reapInterval", 1000)
+	q.Config.SetDefault(prefix+"maxAttempts", 0)
+	q.Config.SetDefault(prefix+"messageHandles", false)
+	q.Config.SetDefault(prefix+"handleAllMessagesBeforeExiting", true)
+}
+
+func (q *Queue) configure(prefix string) error {
+	q.loadConfigurationDefaults(prefix)
+	q.Name = q.Config.GetString(prefix + "name")
+	q.Consumer = q.Config.GetString(prefix + "consumer")
+	q.ChannelSize = q.Config.GetInt(prefix + "channelSize")
+	q.PollTimeout = time.Duration(q.Config.GetInt(prefix+"pollTimeout")) * time.Millisecond
+	q.VisibilityTimeout = time.Duration(q.Config.GetInt(prefix+"visibilityTimeout")) * time.Millisecond
+	q.ReapInterval = time.Duration(q.Config.GetInt(prefix+"reapInterval")) * time.Millisecond
+	q.MaxAttempts = q.Config.GetInt(prefix + "maxAttempts")
+	q.MessageHandles = q.Config.GetBool(prefix + "messageHandles")
+	q.HandleAllMessagesBeforeExiting = q.Config.GetBool(prefix + "handleAllMessagesBeforeExiting")
+
+	if q.Consumer == "" || strings.Contains(q.Consumer, "|") {
+		return fmt.Errorf("%sconsumer must identify the consumer and must not contain |", prefix)
+	}
+	if q.PollTimeout <= 0 || q.VisibilityTimeout <= 0 || q.ReapInterval <= 0 {
+		return fmt.Errorf("%spollTimeout, %svisibilityTimeout and %sreapInterval must be positive", prefix, prefix, prefix)
+	}
+
+	q.msgChan = make(chan []byte, q.ChannelSize)
+	q.jobsChan = make(chan *Job, q.ChannelSize)
+
+	if q.HandleAllMessagesBeforeExiting {
+		var wg sync.WaitGroup
+		q.pendingMessagesWG = &wg
+	}
+	return nil
+}
+
+func (q *Queue) processingKey(consumer string) string {
+	return q.Name + ":processing:" + consumer
+}
+
+func (q *Queue) inflightKey() string {
+	return q.Name + ":inflight"
+}
+
+func (q *Queue) delayedKey() string {
+	return q.Name + ":delayed"
+}
+
+// DeadKey is the list holding jobs that exceeded MaxAttempts
+func (q *Queue) DeadKey() string {
+	return q.Name + ":dead"
+}
+
+// Enqueue pushes a job with payload to the queue
+func (q *Queue) Enqueue(payload []byte) error {
+	raw, err := q.newRawJob(payload)
+	if err != nil {
+		return err
+	}
+	return q.Client.LPush(q.Name, raw).Err()
+}
+
+// EnqueueIn schedules a job with payload to be pushed to the queue after delay
+func (q *Queue) EnqueueIn(payload []byte, delay time.Duration) error {
+	return q.EnqueueAt(payload, time.Now().Add(delay))
+}
+
+// EnqueueAt schedules a job with payload to be pushed to the queue at t, the reaper
+// moves it to the queue once due
+func (q *Queue) EnqueueAt(payload []byte, t time.Time) error {
+	raw, err := q.newRawJob(payload)
+	if err != nil {
+		return err
+	}
+	return q.Client.ZAdd(q.delayedKey(), redis.Z{Score: float64(t.UnixNano() / int64(time.Millisecond)), Member: raw}).Err()
+}
+
+func (q *Queue) newRawJob(payload []byte) (string, error) {
+	j, err := newJob(payload)
+	if err != nil {
+		return "", err
+	}
+	return j.encode()
+}
+
+// PendingMessagesWaitGroup returns the waitGroup that is incremented every time a job is consumed
+func (q *Queue) PendingMessagesWaitGroup() *sync.WaitGroup {
+	return q.pendingMessagesWG
+}
+
+// MessagesChannel returns the channel that will receive the payload of every job
+func (q *Queue) MessagesChannel() *chan []byte {
+	return &q.msgChan
+}
+
+// JobsChannel returns the channel that will receive every job when MessageHandles is enabled.
+// Each job must be acknowledged with Job.Ack
+func (q *Queue) JobsChannel() *chan *Job {
+	return &q.jobsChan
+}
+
+// StopConsuming stops consuming jobs from the queue
+func (q *Queue) StopConsuming() {
+	q.runMutex.Lock()
+	defer q.runMutex.Unlock()
+	if q.run && q.stopChan != nil {
+		close(q.stopChan)
+	}
+	q.run = false
+}
+
+func (q *Queue) startRunning() chan struct{} {
+	q.runMutex.Lock()
+	defer q.runMutex.Unlock()
+	q.run = true
+	q.stopChan = make(chan struct{})
+	return q.stopChan
+}
+
+func (q *Queue) isRunning() bool {
+	q.runMutex.Lock()
+	defer q.runMutex.Unlock()
+	return q.run
+}
+
+// ConsumeLoop takes jobs from the queue until StopConsuming is called. Jobs left in the
+// processing list of this consumer by a previous run are requeued first
+func (q *Queue) ConsumeLoop() error {
+	stop := q.startRunning()
+	l := q.Logger.WithFields(logrus.Fields{
+		"method":   "ConsumeLoop",
+		"queue":    q.Name,
+		"consumer": q.Consumer,
+	})
+
+	err := q.recover()
+	if err != nil {
+		l.WithError(err).Error("error requeueing jobs of a previous run")
+		q.StopConsuming()
+		return err
+	}
+
+	reaperDone := make(chan struct{})
+	go func() {
+		defer close(reaperDone)
+		q.reapLoop(stop)
+	}()
+	defer func() { <-reaperDone }()
+
+	for q.isRunning() {
+		raw, err := q.take()
+		if scripting.IsNil(err) {
+			q.wait(stop, q.PollTimeout)
+			continue
+		}
+		if err != nil {
+			l.WithError(err).Error("error taking job from queue")
+			q.wait(stop, q.PollTimeout)
+			continue
+		}
+		q.receive(raw, stop)
+	}
+	return nil
+}
+
+// take moves the next job to the processing list of the consumer and sets its visibility
+// deadline in the same step, so a consumer crashing in between never leaves a job behind
+func (q *Queue) take() (string, error) {
+	deadline := time.Now().Add(q.VisibilityTimeout)
+	res, err := takeScript.Run(
+		context.Background(),
+		scripting.FromV6(q.Client),
+		[]string{q.Name, q.processingKey(q.Consumer), q.inflightKey()},
+		deadline.UnixNano()/int64(time.Millisecond),
+		q.inflightMember(q.Consumer, ""),
+	)
+	if err != nil {
+		return "", err
+	}
+	raw, ok := res.(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected reply taking a job: %v", res)
+	}
+	return raw, nil
+}
+
+func (q *Queue) receive(raw string, stop chan struct{}) {
+	l := q.Logger.WithFields(logrus.Fields{
+		"method": "receive",
+		"queue":  q.Name,
+	})
+	j, err := decodeJob(raw)
+	if err != nil {
+		l.WithError(err).Error("discarding job that could not be decoded")
+		q.Client.LRem(q.processingKey(q.Consumer), 1, raw)
+		q.Client.ZRem(q.inflightKey(), q.inflightMember(q.Consumer, raw))
+		return
+	}
+	j.queue = q
+
+	if q.pendingMessagesWG != nil {
+		q.pendingMessagesWG.Add(1)
+	}
+	if q.MessageHandles {
+		select {
+		case q.jobsChan <- j:
+		case <-stop:
+			q.dropMessage(l)
+		}
+		return
+	}
+	select {
+	case q.msgChan <- j.Payload:
+	case <-stop:
+		q.dropMessage(l)
+		return
+	}
+	if err := q.remove(j); err != nil {
+		l.WithError(err).Error("error acknowledging job")
+	}
+}
+
+// dropMessage releases a job that could not be delivered because the consumer stopped,
+// it stays in the processing list and is requeued by the reaper or when the consumer restarts
+func (q *Queue) dropMessage(l *logrus.Entry) {
+	if q.pendingMessagesWG != nil {
+		q.pendingMessagesWG.Done()
+	}
+	l.Debug("Consumer stopped before the job was delivered.")
+}
+
+// inflightMember identifies a job in the inflight sorted set along with the consumer holding it
+func (q *Queue) inflightMember(consumer, raw string) string {
+	return consumer + "|" + raw
+}
+
+func (q *Queue) setDeadline(j *Job, deadline time.Time) error {
+	return q.Client.ZAdd(q.inflightKey(), redis.Z{
+		Score:  float64(deadline.UnixNano() / int64(time.Millisecond)),
+		Member: q.inflightMember(q.Consumer, j.raw),
+	}).Err()
+}
+
+func (q *Queue) ack(j *Job) error {
+	err := q.remove(j)
+	if q.pendingMessagesWG != nil {
+		q.pendingMessagesWG.Done()
+	}
+	return err
+}
+
+func (q *Queue) remove(j *Job) error {
+	err := q.Client.LRem(q.processingKey(q.Consumer), 1, j.raw).Err()
+	if err != nil {
+		return err
+	}
+	return q.Client.ZRem(q.inflightKey(), q.inflightMember(q.Consumer, j.raw)).Err()
+}
+
+func (q *Queue) wait(stop chan struct{}, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-stop:
+	case <-timer.C:
+	}
+}
+
+// Cleanup stops consuming, the client is owned by the caller and is not closed
+func (q *Queue) Cleanup() error {
+	q.StopConsuming()
+	return nil
+}
+
+func millis(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+}
@@ -0,0 +1,225 @@
+/*
+ * Copyright (c) 2016 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package queue
+
+import (
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/interfaces"
+	"github.com/topfreegames/extensions/v9/redis/mocks"
+	"github.com/topfreegames/extensions/v9/redis/scripting"
+)
+
+var _ interfaces.Queue = &Queue{}
+
+var _ = Describe("Queue", func() {
+	logger, _ := test.NewNullLogger()
+	var mockCtrl *gomock.Controller
+	var client *mocks.MockRedisClient
+	var config *viper.Viper
+
+	newQueue := func() *Queue {
+		q, err := NewQueue(config, logger, client)
+		Expect(err).NotTo(HaveOccurred())
+		return q
+	}
+
+	rawJob := func(payload string, attempts int) string {
+		j, err := newJob([]byte(payload))
+		Expect(err).NotTo(HaveOccurred())
+		j.Attempts = attempts
+		raw, err := j.encode()
+		Expect(err).NotTo(HaveOccurred())
+		return raw
+	}
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		client = mocks.NewMockRedisClient(mockCtrl)
+		config = viper.New()
+		config.Set("extensions.redisqueue.name", "jobs")
+		config.Set("extensions.redisqueue.consumer", "worker-1")
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Describe("[Unit]", func() {
+		It("should configure defaults", func() {
+			q := newQueue()
+			Expect(q.PollTimeout).To(Equal(100 * time.Millisecond))
+			Expect(q.VisibilityTimeout).To(Equal(30 * time.Second))
+			Expect(q.MaxAttempts).To(Equal(0))
+			Expect(q.DeadKey()).To(Equal("jobs:dead"))
+		})
+
+		It("should require a valid consumer name", func() {
+			config.Set("extensions.redisqueue.consumer", "a|b")
+			_, err := NewQueue(config, logger, client)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should enqueue jobs", func() {
+			q := newQueue()
+			client.EXPECT().LPush("jobs", gomock.Any()).DoAndReturn(func(key string, values ...interface{}) *redis.IntCmd {
+				j, err := decodeJob(values[0].(string))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(j.Payload).To(Equal([]byte("payload")))
+				Expect(j.ID).To(HaveLen(32))
+				return redis.NewIntResult(1, nil)
+			})
+			Expect(q.Enqueue([]byte("payload"))).To(Succeed())
+		})
+
+		It("should schedule delayed jobs", func() {
+			q := newQueue()
+			due := time.Now().Add(time.Minute)
+			client.EXPECT().ZAdd("jobs:delayed", gomock.Any()).DoAndReturn(func(key string, members ...redis.Z) *redis.IntCmd {
+				Expect(members[0].Score).To(BeNumerically("~", float64(due.UnixNano()/int64(time.Millisecond)), 1000))
+				return redis.NewIntResult(1, nil)
+			})
+			Expect(q.EnqueueIn([]byte("payload"), time.Minute)).To(Succeed())
+		})
+
+		It("should take jobs setting their visibility deadline in the same step", func() {
+			q := newQueue()
+			raw := rawJob("payload", 0)
+			deadline := time.Now().Add(q.VisibilityTimeout)
+			client.EXPECT().EvalSha(takeScript.SHA1, []string{"jobs", "jobs:processing:worker-1", "jobs:inflight"}, gomock.Any(), "worker-1|").
+				DoAndReturn(func(sha1 string, keys []string, args ...interface{}) *redis.Cmd {
+					Expect(args[0]).To(BeNumerically("~", deadline.UnixNano()/int64(time.Millisecond), 1000))
+					return redis.NewCmdResult(raw, nil)
+				})
+			Expect(q.take()).To(Equal(raw))
+
+			client.EXPECT().EvalSha(takeScript.SHA1, gomock.Any(), gomock.Any(), gomock.Any()).Return(redis.NewCmdResult(nil, redis.Nil))
+			_, err := q.take()
+			Expect(err).To(Equal(scripting.ErrNil))
+		})
+
+		It("should track jobs in flight until acknowledged", func() {
+			config.Set("extensions.redisqueue.messageHandles", true)
+			q := newQueue()
+			raw := rawJob("payload", 0)
+			q.receive(raw, make(chan struct{}))
+
+			var j *Job
+			Expect(*q.JobsChannel()).To(Receive(&j))
+			Expect(j.Payload).To(Equal([]byte("payload")))
+
+			client.EXPECT().LRem("jobs:processing:worker-1", int64(1), raw).Return(redis.NewIntResult(1, nil))
+			client.EXPECT().ZRem("jobs:inflight", "worker-1|"+raw).Return(redis.NewIntResult(1, nil))
+			Expect(j.Ack()).To(Succeed())
+			Expect(j.Ack()).To(Succeed())
+			q.PendingMessagesWaitGroup().Wait()
+		})
+
+		It("should acknowledge jobs delivered to the messages channel", func() {
+			q := newQueue()
+			raw := rawJob("payload", 0)
+			client.EXPECT().LRem("jobs:processing:worker-1", int64(1), raw).Return(redis.NewIntResult(1, nil))
+			client.EXPECT().ZRem("jobs:inflight", "worker-1|"+raw).Return(redis.NewIntResult(1, nil))
+			q.receive(raw, make(chan struct{}))
+			Expect(*q.MessagesChannel()).To(Receive(Equal([]byte("payload"))))
+		})
+
+		It("should requeue jobs whose visibility timeout expired", func() {
+			q := newQueue()
+			raw := rawJob("payload", 0)
+			client.EXPECT().ZRangeByScore("jobs:inflight", gomock.Any()).
+				Return(redis.NewStringSliceResult([]string{"worker-2|" + raw}, nil))
+			client.EXPECT().EvalSha(requeueScript.SHA1, []string{"jobs:inflight", "jobs:processing:worker-2", "jobs"}, "worker-2|"+raw, raw, gomock.Any()).
+				DoAndReturn(func(sha1 string, keys []string, args ...interface{}) *redis.Cmd {
+					j, err := decodeJob(args[2].(string))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(j.Attempts).To(Equal(1))
+					return redis.NewCmdResult(int64(1), nil)
+				})
+			client.EXPECT().ZRangeByScore("jobs:delayed", gomock.Any()).Return(redis.NewStringSliceResult(nil, nil))
+			q.Reap()
+		})
+
+		It("should not requeue jobs claimed by another reaper", func() {
+			q := newQueue()
+			raw := rawJob("payload", 0)
+			client.EXPECT().ZRangeByScore("jobs:inflight", gomock.Any()).
+				Return(redis.NewStringSliceResult([]string{"worker-2|" + raw}, nil))
+			client.EXPECT().EvalSha(requeueScript.SHA1, gomock.Any(), "worker-2|"+raw, raw, gomock.Any()).Return(redis.NewCmdResult(int64(0), nil))
+			client.EXPECT().ZRangeByScore("jobs:delayed", gomock.Any()).Return(redis.NewStringSliceResult(nil, nil))
+			q.Reap()
+		})
+
+		It("should move jobs to the dead list after max attempts", func() {
+			config.Set("extensions.redisqueue.maxAttempts", 2)
+			q := newQueue()
+			raw := rawJob("payload", 1)
+			client.EXPECT().EvalSha(requeueScript.SHA1, []string{"jobs:inflight", "jobs:processing:worker-2", "jobs:dead"}, "worker-2|"+raw, raw, gomock.Any()).
+				Return(redis.NewCmdResult(int64(1), nil))
+			Expect(q.retry("worker-2|"+raw, "worker-2", raw)).To(Succeed())
+		})
+
+		It("should push delayed jobs that are due", func() {
+			q := newQueue()
+			raw := rawJob("payload", 0)
+			client.EXPECT().ZRangeByScore("jobs:inflight", gomock.Any()).Return(redis.NewStringSliceResult(nil, nil))
+			client.EXPECT().ZRangeByScore("jobs:delayed", gomock.Any()).Return(redis.NewStringSliceResult([]string{raw}, nil))
+			client.EXPECT().EvalSha(promoteScript.SHA1, []string{"jobs:delayed", "jobs"}, raw).Return(redis.NewCmdResult(int64(1), nil))
+			q.Reap()
+		})
+
+		It("should requeue jobs of a previous run and consume", func() {
+			config.Set("extensions.redisqueue.messageHandles", true)
+			config.Set("extensions.redisqueue.reapInterval", 60000)
+			q := newQueue()
+			stale := rawJob("stale", 0)
+			fresh := rawJob("fresh", 0)
+			gomock.InOrder(
+				client.EXPECT().RPopLPush("jobs:processing:worker-1", "jobs").Return(redis.NewStringResult(stale, nil)),
+				client.EXPECT().RPopLPush("jobs:processing:worker-1", "jobs").Return(redis.NewStringResult("", redis.Nil)),
+			)
+			client.EXPECT().ZRem("jobs:inflight", "worker-1|"+stale).Return(redis.NewIntResult(0, nil))
+			gomock.InOrder(
+				client.EXPECT().EvalSha(takeScript.SHA1, gomock.Any(), gomock.Any(), gomock.Any()).Return(redis.NewCmdResult(fresh, nil)),
+				client.EXPECT().EvalSha(takeScript.SHA1, gomock.Any(), gomock.Any(), gomock.Any()).Return(redis.NewCmdResult(nil, redis.Nil)).AnyTimes(),
+			)
+
+			done := make(chan error)
+			go func() {
+				defer GinkgoRecover()
+				done <- q.ConsumeLoop()
+			}()
+			var j *Job
+			Eventually(*q.JobsChannel()).Should(Receive(&j))
+			Expect(j.Payload).To(Equal([]byte("fresh")))
+			q.StopConsuming()
+			Eventually(done, 3*time.Second).Should(Receive(BeNil()))
+		})
+	})
+})
@@ -0,0 +1,187 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package queue
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/sirupsen/logrus"
+	"github.com/topfreegames/extensions/v9/redis/scripting"
+)
+
+// reapBatchSize bounds how many jobs are requeued or promoted on each reap
+const reapBatchSize = 100
+
+// requeueScript removes an expired job from the inflight set and the processing list of
+// its consumer and pushes it to the target list in a single step. A job claimed by another
+// reaper, or acknowledged meanwhile, is left untouched
+var requeueScript = scripting.NewScript("queue.requeue", `
+if redis.call("ZREM", KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+if redis.call("LREM", KEYS[2], 1, ARGV[2]) == 0 then
+	return 0
+end
+redis.call("RPUSH", KEYS[3], ARGV[3])
+return 1
+`)
+
+// promoteScript moves a due job from the delayed set to the queue in a single step
+var promoteScript = scripting.NewScript("queue.promote", `
+if redis.call("ZREM", KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call("LPUSH", KEYS[2], ARGV[1])
+return 1
+`)
+
+func (q *Queue) reapLoop(stop chan struct{}) {
+	ticker := time.NewTicker(q.ReapInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			q.Reap()
+		}
+	}
+}
+
+// Reap requeues jobs whose visibility timeout expired and pushes delayed jobs that are due.
+// It is run by ConsumeLoop every ReapInterval, concurrent reapers never requeue a job twice
+func (q *Queue) Reap() {
+	l := q.Logger.WithFields(logrus.Fields{
+		"method": "Reap",
+		"queue":  q.Name,
+	})
+	now := time.Now()
+	err := q.requeueExpired(now)
+	if err != nil {
+		l.WithError(err).Error("error requeueing expired jobs")
+	}
+	err = q.promoteDelayed(now)
+	if err != nil {
+		l.WithError(err).Error("error pushing delayed jobs")
+	}
+}
+
+func (q *Queue) requeueExpired(now time.Time) error {
+	members, err := q.Client.ZRangeByScore(q.inflightKey(), redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   millis(now),
+		Count: reapBatchSize,
+	}).Result()
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		parts := strings.SplitN(member, "|", 2)
+		if len(parts) != 2 {
+			err = q.Client.ZRem(q.inflightKey(), member).Err()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		err = q.retry(member, parts[0], parts[1])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retry pushes a job held by consumer back to the consuming end of the queue with one
+// more attempt, or to the dead list once MaxAttempts is reached
+func (q *Queue) retry(member, consumer, raw string) error {
+	target, requeued := q.DeadKey(), raw
+	j, err := decodeJob(raw)
+	if err == nil {
+		j.Attempts++
+		requeued, err = j.encode()
+		if err != nil {
+			return err
+		}
+		if q.MaxAttempts <= 0 || j.Attempts < q.MaxAttempts {
+			target = q.Name
+		}
+	}
+	res, err := requeueScript.Run(
+		context.Background(),
+		scripting.FromV6(q.Client),
+		[]string{q.inflightKey(), q.processingKey(consumer), target},
+		member, raw, requeued,
+	)
+	if err != nil {
+		return err
+	}
+	if n, ok := res.(int64); ok && n == 1 && j != nil && target == q.DeadKey() {
+		q.Logger.WithFields(logrus.Fields{
+			"queue":    q.Name,
+			"job":      j.ID,
+			"attempts": j.Attempts,
+		}).Warn("job moved to dead list")
+	}
+	return nil
+}
+
+func (q *Queue) promoteDelayed(now time.Time) error {
+	due, err := q.Client.ZRangeByScore(q.delayedKey(), redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   millis(now),
+		Count: reapBatchSize,
+	}).Result()
+	if err != nil {
+		return err
+	}
+	for _, raw := range due {
+		_, err = promoteScript.Run(
+			context.Background(),
+			scripting.FromV6(q.Client),
+			[]string{q.delayedKey(), q.Name},
+			raw,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recover requeues the jobs left in the processing list of this consumer by a previous run
+func (q *Queue) recover() error {
+	for {
+		raw, err := q.Client.RPopLPush(q.processingKey(q.Consumer), q.Name).Result()
+		if err == redis.Nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		q.Client.ZRem(q.inflightKey(), q.inflightMember(q.Consumer, raw))
+	}
+}
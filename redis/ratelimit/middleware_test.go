@@ -0,0 +1,91 @@
+/*
+ * Copyright (c) 2016 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package ratelimit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/topfreegames/extensions/v9/middleware"
+)
+
+var _ = Describe("Middleware", func() {
+	var client *fakeEvaler
+	var handler http.Handler
+
+	serve := func(player string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if player != "" {
+			r.Header.Set("X-Player-Id", player)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	BeforeEach(func() {
+		client = &fakeEvaler{}
+		limiter, err := NewLimiterFromOptions(&Options{Strategy: TokenBucket, Limit: 10, Window: time.Minute}, client)
+		Expect(err).NotTo(HaveOccurred())
+		ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		handler = middleware.Chain(ok, Middleware(limiter, func(r *http.Request) string {
+			return r.Header.Get("X-Player-Id")
+		}))
+	})
+
+	Describe("[Unit]", func() {
+		It("should let allowed requests through", func() {
+			client.reply = []interface{}{int64(1), int64(9), int64(6000)}
+			w := serve("1")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Header().Get("X-RateLimit-Limit")).To(Equal("10"))
+			Expect(w.Header().Get("X-RateLimit-Remaining")).To(Equal("9"))
+			Expect(w.Header().Get("X-RateLimit-Reset")).To(Equal("6"))
+		})
+
+		It("should reply 429 with Retry-After", func() {
+			client.reply = []interface{}{int64(0), int64(0), int64(2500)}
+			w := serve("1")
+			Expect(w.Code).To(Equal(http.StatusTooManyRequests))
+			Expect(w.Header().Get("Retry-After")).To(Equal("3"))
+		})
+
+		It("should not limit requests without a key", func() {
+			w := serve("")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(client.script).To(BeEmpty())
+		})
+
+		It("should let requests through when redis fails", func() {
+			client.err = errors.New("connection refused")
+			w := serve("1")
+			Expect(w.Code).To(Equal(http.StatusOK))
+		})
+	})
+})
@@ -0,0 +1,196 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+// Package ratelimit implements rate limiters on redis with fixed window, sliding log and
+// token bucket strategies, and an HTTP middleware to rate limit requests
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/redis/scripting"
+)
+
+// Strategy is the algorithm used to count requests
+type Strategy string
+
+// Strategies supported by Limiter
+const (
+	// FixedWindow allows Limit requests per Window, starting at the first request of each window
+	FixedWindow Strategy = "fixedWindow"
+	// SlidingLog allows Limit requests in any interval of Window, keeping a log of every request
+	SlidingLog Strategy = "slidingLog"
+	// TokenBucket allows bursts of up to Limit requests, refilling Limit tokens every Window
+	TokenBucket Strategy = "tokenBucket"
+)
+
+// Options configures a Limiter
+type Options struct {
+	// Prefix is prepended to every rate limit key
+	Prefix   string
+	Strategy Strategy
+	Limit    int64
+	Window   time.Duration
+}
+
+// Result is the outcome of a rate limit check
+type Result struct {
+	Allowed   bool
+	Limit     int64
+	Remaining int64
+	// RetryAfter is how long to wait before a rejected request can be allowed
+	RetryAfter time.Duration
+	// ResetAfter is how long until the limit is fully available again
+	ResetAfter time.Duration
+}
+
+// Limiter rate limits keys, such as player or game ids, on redis
+type Limiter struct {
+	Options *Options
+	client  scripting.Evaler
+	script  *scripting.Script
+}
+
+// NewLimiter creates a Limiter with options read from the given config prefix
+func NewLimiter(prefix string, config *viper.Viper, client scripting.Evaler) (*Limiter, error) {
+	loadConfigurationDefaults(prefix, config)
+	options := &Options{
+		Prefix:   config.GetString(fmt.Sprintf("%s.prefix", prefix)),
+		Strategy: Strategy(config.GetString(fmt.Sprintf("%s.strategy", prefix))),
+		Limit:    config.GetInt64(fmt.Sprintf("%s.limit", prefix)),
+		Window:   config.GetDuration(fmt.Sprintf("%s.window", prefix)),
+	}
+	return NewLimiterFromOptions(options, client)
+}
+
+func loadConfigurationDefaults(prefix string, config *viper.Viper) {
+	config.SetDefault(fmt.Sprintf("%s.prefix", prefix), "ratelimit:")
+	config.SetDefault(fmt.Sprintf("%s.strategy", prefix), string(SlidingLog))
+	config.SetDefault(fmt.Sprintf("%s.limit", prefix), 100)
+	config.SetDefault(fmt.Sprintf("%s.window", prefix), "1m")
+}
+
+// NewLimiterFromOptions creates a Limiter with the given options
+func NewLimiterFromOptions(options *Options, client scripting.Evaler) (*Limiter, error) {
+	if options == nil {
+		return nil, fmt.Errorf("NewLimiterFromOptions must have non-nil options")
+	}
+	if client == nil {
+		return nil, fmt.Errorf("a redis client is required")
+	}
+	if options.Limit <= 0 {
+		return nil, fmt.Errorf("rate limit must be positive")
+	}
+	if options.Window < time.Millisecond {
+		return nil, fmt.Errorf("rate limit window must be at least 1ms")
+	}
+	l := &Limiter{Options: options, client: client}
+	switch options.Strategy {
+	case FixedWindow:
+		l.script = fixedWindowScript
+	case SlidingLog:
+		l.script = slidingLogScript
+	case TokenBucket:
+		l.script = tokenBucketScript
+	default:
+		return nil, fmt.Errorf("unknown rate limit strategy %q", options.Strategy)
+	}
+	return l, nil
+}
+
+// Key returns the redis key of a rate limited key. The key is wrapped in a hash tag
+// so every key used for it lands on the same slot in cluster mode
+func (l *Limiter) Key(key string) string {
+	return fmt.Sprintf("%s{%s}", l.Options.Prefix, key)
+}
+
+// Allow checks and counts a single request for key
+func (l *Limiter) Allow(ctx context.Context, key string) (*Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+// AllowN checks and counts a request of cost n for key. Rejected requests are not counted
+func (l *Limiter) AllowN(ctx context.Context, key string, n int64) (*Result, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("rate limit cost must be positive")
+	}
+	args := []interface{}{l.Options.Limit, l.Options.Window.Milliseconds(), n}
+	if l.Options.Strategy == SlidingLog {
+		id, err := requestID()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, id)
+	}
+	res, err := l.script.Run(ctx, l.client, []string{l.Key(key)}, args...)
+	if err != nil {
+		return nil, err
+	}
+	return l.parseResult(res)
+}
+
+// Reset clears the requests counted for key
+func (l *Limiter) Reset(ctx context.Context, key string) error {
+	_, err := resetScript.Run(ctx, l.client, []string{l.Key(key)})
+	return err
+}
+
+func (l *Limiter) parseResult(res interface{}) (*Result, error) {
+	values, ok := res.([]interface{})
+	if !ok || len(values) != 3 {
+		return nil, fmt.Errorf("unexpected rate limit script reply %v", res)
+	}
+	ints := make([]int64, len(values))
+	for i, value := range values {
+		ints[i], ok = value.(int64)
+		if !ok {
+			return nil, fmt.Errorf("unexpected rate limit script reply %v", res)
+		}
+	}
+	result := &Result{
+		Allowed:   ints[0] == 1,
+		Limit:     l.Options.Limit,
+		Remaining: ints[1],
+	}
+	after := time.Duration(ints[2]) * time.Millisecond
+	if result.Allowed {
+		result.ResetAfter = after
+	} else {
+		result.RetryAfter = after
+		result.ResetAfter = l.Options.Window
+	}
+	return result, nil
+}
+
+// requestID identifies a request in the sliding log
+func requestID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
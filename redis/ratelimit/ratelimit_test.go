@@ -0,0 +1,255 @@
+/*
+ * Copyright (c) 2016 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/redis/scripting"
+	redisextensions "github.com/topfreegames/extensions/v9/redis/v8"
+)
+
+// fakeEvaler records the last script call and replies with a canned result
+type fakeEvaler struct {
+	script string
+	keys   []string
+	args   []interface{}
+	reply  interface{}
+	err    error
+}
+
+func (e *fakeEvaler) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	e.script, e.keys, e.args = script, keys, args
+	return e.reply, e.err
+}
+
+func (e *fakeEvaler) EvalSha(ctx context.Context, sha1 string, keys []string, args ...interface{}) (interface{}, error) {
+	return nil, errors.New("NOSCRIPT No matching script. Please use EVAL.")
+}
+
+func (e *fakeEvaler) ScriptExists(ctx context.Context, hashes ...string) ([]bool, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (e *fakeEvaler) ScriptLoad(ctx context.Context, script string) (string, error) {
+	return "", errors.New("not implemented")
+}
+
+var _ = Describe("Limiter", func() {
+	var client *fakeEvaler
+	var options *Options
+	ctx := context.Background()
+
+	BeforeEach(func() {
+		client = &fakeEvaler{}
+		options = &Options{Prefix: "rl:", Strategy: FixedWindow, Limit: 10, Window: time.Minute}
+	})
+
+	Describe("[Unit]", func() {
+		It("should load options from config", func() {
+			config := viper.New()
+			config.Set("extensions.ratelimit.strategy", "tokenBucket")
+			config.Set("extensions.ratelimit.limit", 5)
+			limiter, err := NewLimiter("extensions.ratelimit", config, client)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(limiter.Options).To(Equal(&Options{
+				Prefix:   "ratelimit:",
+				Strategy: TokenBucket,
+				Limit:    5,
+				Window:   time.Minute,
+			}))
+			Expect(limiter.script).To(Equal(tokenBucketScript))
+		})
+
+		It("should fail on invalid options", func() {
+			options.Strategy = "leakyBucket"
+			_, err := NewLimiterFromOptions(options, client)
+			Expect(err).To(HaveOccurred())
+			options.Strategy = FixedWindow
+			options.Limit = 0
+			_, err = NewLimiterFromOptions(options, client)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should use a hash tag in keys", func() {
+			limiter, err := NewLimiterFromOptions(options, client)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(limiter.Key("player:1")).To(Equal("rl:{player:1}"))
+		})
+
+		It("should allow requests", func() {
+			limiter, err := NewLimiterFromOptions(options, client)
+			Expect(err).NotTo(HaveOccurred())
+			client.reply = []interface{}{int64(1), int64(9), int64(60000)}
+			result, err := limiter.Allow(ctx, "player:1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(&Result{Allowed: true, Limit: 10, Remaining: 9, ResetAfter: time.Minute}))
+			Expect(client.script).To(Equal(fixedWindowScript.Source))
+			Expect(client.keys).To(Equal([]string{"rl:{player:1}"}))
+			Expect(client.args).To(Equal([]interface{}{int64(10), int64(60000), int64(1)}))
+		})
+
+		It("should reject requests over the limit", func() {
+			limiter, err := NewLimiterFromOptions(options, client)
+			Expect(err).NotTo(HaveOccurred())
+			client.reply = []interface{}{int64(0), int64(0), int64(1500)}
+			result, err := limiter.AllowN(ctx, "player:1", 2)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Allowed).To(BeFalse())
+			Expect(result.RetryAfter).To(Equal(1500 * time.Millisecond))
+		})
+
+		It("should send a request id to the sliding log", func() {
+			options.Strategy = SlidingLog
+			limiter, err := NewLimiterFromOptions(options, client)
+			Expect(err).NotTo(HaveOccurred())
+			client.reply = []interface{}{int64(1), int64(9), int64(60000)}
+			_, err = limiter.Allow(ctx, "player:1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(client.script).To(Equal(slidingLogScript.Source))
+			Expect(client.args).To(HaveLen(4))
+			Expect(client.args[3]).To(HaveLen(16))
+		})
+
+		It("should fail on unexpected replies", func() {
+			limiter, err := NewLimiterFromOptions(options, client)
+			Expect(err).NotTo(HaveOccurred())
+			client.reply = "OK"
+			_, err = limiter.Allow(ctx, "player:1")
+			Expect(err).To(HaveOccurred())
+			_, err = limiter.AllowN(ctx, "player:1", 0)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
+
+var _ = Describe("Limiter on redis", func() {
+	var client scripting.Evaler
+	var limiter *Limiter
+	ctx := context.Background()
+	key := "player:1"
+
+	newLimiter := func(strategy Strategy, limit int64, window time.Duration) *Limiter {
+		l, err := NewLimiterFromOptions(&Options{Prefix: "extensions:ratelimit:", Strategy: strategy, Limit: limit, Window: window}, client)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(l.Reset(ctx, key)).To(Succeed())
+		return l
+	}
+
+	allowN := func(n int64) *Result {
+		result, err := limiter.AllowN(ctx, key, n)
+		Expect(err).NotTo(HaveOccurred())
+		return result
+	}
+
+	Describe("[Integration]", func() {
+		BeforeEach(func() {
+			limiter = nil
+			config := viper.New()
+			config.SetConfigFile("../../config/test.yaml")
+			Expect(config.ReadInConfig()).NotTo(HaveOccurred())
+			redisClient, err := redisextensions.NewClient(ctx, "extensions.redisV8", config)
+			Expect(err).NotTo(HaveOccurred())
+			client = scripting.FromV8(redisClient)
+		})
+
+		AfterEach(func() {
+			if limiter != nil {
+				Expect(limiter.Reset(ctx, key)).To(Succeed())
+			}
+		})
+
+		It("should limit requests in a fixed window", func() {
+			limiter = newLimiter(FixedWindow, 3, time.Minute)
+			for remaining := int64(2); remaining >= 0; remaining-- {
+				result := allowN(1)
+				Expect(result.Allowed).To(BeTrue())
+				Expect(result.Remaining).To(Equal(remaining))
+				Expect(result.ResetAfter).To(BeNumerically("~", time.Minute, time.Second))
+			}
+			result := allowN(1)
+			Expect(result.Allowed).To(BeFalse())
+			Expect(result.Remaining).To(BeZero())
+			Expect(result.RetryAfter).To(BeNumerically("~", time.Minute, time.Second))
+		})
+
+		It("should not count rejected requests in a fixed window", func() {
+			limiter = newLimiter(FixedWindow, 3, time.Minute)
+			Expect(allowN(2).Allowed).To(BeTrue())
+			Expect(allowN(2).Allowed).To(BeFalse())
+			Expect(allowN(1).Remaining).To(BeZero())
+		})
+
+		It("should allow requests again once the oldest of the sliding log expires", func() {
+			limiter = newLimiter(SlidingLog, 2, 200*time.Millisecond)
+			Expect(allowN(1).Allowed).To(BeTrue())
+			time.Sleep(50 * time.Millisecond)
+			Expect(allowN(1).Allowed).To(BeTrue())
+
+			result := allowN(1)
+			Expect(result.Allowed).To(BeFalse())
+			Expect(result.Remaining).To(BeZero())
+			Expect(result.RetryAfter).To(BeNumerically(">", 0))
+			Expect(result.RetryAfter).To(BeNumerically("<=", 151*time.Millisecond))
+
+			time.Sleep(result.RetryAfter)
+			result = allowN(1)
+			Expect(result.Allowed).To(BeTrue())
+			Expect(result.Remaining).To(BeZero())
+		})
+
+		It("should reject sliding log requests costing more than the limit", func() {
+			limiter = newLimiter(SlidingLog, 2, time.Minute)
+			result := allowN(3)
+			Expect(result.Allowed).To(BeFalse())
+			Expect(result.RetryAfter).To(Equal(time.Minute))
+			Expect(allowN(2).Allowed).To(BeTrue())
+		})
+
+		It("should refill the token bucket over the window", func() {
+			limiter = newLimiter(TokenBucket, 2, time.Second)
+			Expect(allowN(2).Allowed).To(BeTrue())
+
+			result := allowN(1)
+			Expect(result.Allowed).To(BeFalse())
+			Expect(result.RetryAfter).To(BeNumerically("~", 500*time.Millisecond, 50*time.Millisecond))
+
+			time.Sleep(result.RetryAfter)
+			Expect(allowN(1).Allowed).To(BeTrue())
+			Expect(allowN(3).RetryAfter).To(Equal(time.Second))
+		})
+
+		It("should reset the requests of a key", func() {
+			limiter = newLimiter(FixedWindow, 1, time.Minute)
+			Expect(allowN(1).Allowed).To(BeTrue())
+			Expect(allowN(1).Allowed).To(BeFalse())
+			Expect(limiter.Reset(ctx, key)).To(Succeed())
+			Expect(allowN(1).Allowed).To(BeTrue())
+		})
+	})
+})
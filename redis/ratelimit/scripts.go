@@ -0,0 +1,99 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package ratelimit
+
+import "github.com/topfreegames/extensions/v9/redis/scripting"
+
+// Every script receives the limit, the window in milliseconds and the cost of the
+// request, and replies with {allowed, remaining, retry or reset after in milliseconds}.
+// Time is read from the redis server so that clients with skewed clocks share a window
+
+var fixedWindowScript = scripting.NewScript("ratelimit.fixedWindow", `
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local count = redis.call("INCRBY", KEYS[1], cost)
+local ttl = redis.call("PTTL", KEYS[1])
+if ttl < 0 then
+	redis.call("PEXPIRE", KEYS[1], window)
+	ttl = window
+end
+if count > limit then
+	count = redis.call("DECRBY", KEYS[1], cost)
+	return {0, math.max(limit - count, 0), ttl}
+end
+return {1, limit - count, ttl}
+`)
+
+var slidingLogScript = scripting.NewScript("ratelimit.slidingLog", `
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+local count = redis.call("ZCARD", KEYS[1])
+if count + cost > limit then
+	local retry = window
+	local index = count + cost - limit - 1
+	local entry = redis.call("ZRANGE", KEYS[1], index, index, "WITHSCORES")
+	if cost <= limit and entry[2] then
+		retry = tonumber(entry[2]) + window - now + 1
+	end
+	return {0, limit - count, retry}
+end
+for i = 1, cost do
+	redis.call("ZADD", KEYS[1], now, ARGV[4] .. ":" .. i)
+end
+redis.call("PEXPIRE", KEYS[1], window)
+return {1, limit - count - cost, window}
+`)
+
+var tokenBucketScript = scripting.NewScript("ratelimit.tokenBucket", `
+local capacity = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local rate = capacity / window
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+tokens = math.min(capacity, tokens + math.max(now - ts, 0) * rate)
+local allowed = 0
+local after = math.ceil((capacity - tokens) / rate)
+if tokens >= cost then
+	tokens = tokens - cost
+	allowed = 1
+	after = math.ceil((capacity - tokens) / rate)
+elseif cost <= capacity then
+	after = math.ceil((cost - tokens) / rate)
+else
+	after = window
+end
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], window)
+return {allowed, math.floor(tokens), after}
+`)
+
+var resetScript = scripting.NewScript("ratelimit.reset", `return redis.call("DEL", KEYS[1])`)
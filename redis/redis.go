@@ -31,21 +31,27 @@ import (
 	"github.com/go-redis/redis"
 	"github.com/spf13/viper"
 	"github.com/topfreegames/extensions/v9/redis/interfaces"
+	"github.com/topfreegames/extensions/v9/redis/scripting"
 	tredis "github.com/topfreegames/extensions/v9/tracing/redis"
 )
 
-// ClientConfig holds the input configs for a client.
+// ClientConfig holds the input configs for a client. Sentinel and replica reads are
+// configured under the prefix given to NewClient, prefix.replicaReads only connects the
+// replica: commands are sent to it solely when issued on Client.TraceReplica
 type ClientConfig struct {
 	URL               string
 	ConnectionTimeout int
 }
 
-// Client identifies uniquely one redis client with a pool of connections
+// Client identifies uniquely one redis client with a pool of connections. With replica reads
+// enabled Replica holds a client for the replica, used through TraceReplica
 type Client struct {
-	Client       interfaces.RedisClient
-	TraceWrapper interfaces.TraceWrapper
-	Config       *viper.Viper
-	Options      *redis.Options
+	Client         interfaces.RedisClient
+	Replica        interfaces.RedisClient
+	TraceWrapper   interfaces.TraceWrapper
+	Config         *viper.Viper
+	Options        *redis.Options
+	ReplicaOptions *redis.Options
 }
 
 // TraceWrapper is the struct for the TraceWrapper
@@ -64,7 +70,12 @@ func NewClientFromConfig(config *ClientConfig, ifaces ...interface{}) (*Client,
 	return NewClient("prefix", viperConfig, ifaces...)
 }
 
-// NewClient creates and returns a new redis client based on the given settings
+// NewClient creates and returns a new redis client based on the given settings. Setting
+// prefix.sentinel.masterName and prefix.sentinel.addrs connects through sentinel, and
+// prefix.replicaReads with prefix.replicaUrl connects to a replica for read-only commands.
+// go-redis v6 does not route commands, so only the commands issued on TraceReplica are
+// read from the replica, Trace and Client always use the primary. A *scripting.Registry
+// among ifaces is connected to the client and its scripts preloaded
 func NewClient(prefix string, config *viper.Viper, ifaces ...interface{}) (*Client, error) {
 	client := &Client{
 		Config: config,
@@ -81,10 +92,20 @@ func NewClient(prefix string, config *viper.Viper, ifaces ...interface{}) (*Clie
 			client.TraceWrapper = v
 		}
 	}
+	var replica interfaces.RedisClient
+	if len(ifaces) > 2 && ifaces[2] != nil {
+		if v, ok := ifaces[2].(interfaces.RedisClient); ok {
+			replica = v
+		}
+	}
 	err := client.Connect(prefix, cl)
 	if err != nil {
 		return nil, err
 	}
+	err = client.ConnectReplica(prefix, replica)
+	if err != nil {
+		return nil, err
+	}
 
 	timeout := config.GetInt(fmt.Sprintf("%s.connectionTimeout", prefix))
 	err = client.WaitForConnection(timeout)
@@ -92,6 +113,16 @@ func NewClient(prefix string, config *viper.Viper, ifaces ...interface{}) (*Clie
 		return nil, err
 	}
 
+	for _, i := range ifaces {
+		if scripts, ok := i.(*scripting.Registry); ok && scripts != nil {
+			err = scripts.Connect(context.Background(), scripting.FromV6(client.Client))
+			if err != nil {
+				client.Close()
+				return nil, fmt.Errorf("failed to load redis scripts: %w", err)
+			}
+		}
+	}
+
 	return client, nil
 }
 
@@ -105,18 +136,86 @@ func (c *Client) Trace(ctx context.Context) interfaces.RedisClient {
 	return copy
 }
 
-// Connect to Redis
+// TraceReplica creates a Redis client for read-only commands that sends traces to tracing,
+// connected to the replica when replica reads are enabled and to the primary otherwise
+func (c *Client) TraceReplica(ctx context.Context) interfaces.RedisClient {
+	if c.Replica == nil {
+		return c.Trace(ctx)
+	}
+	if c.TraceWrapper != nil {
+		return c.TraceWrapper.WithContext(ctx, c.Replica)
+	}
+	copy := c.Replica.WithContext(ctx)
+	tredis.Instrument(copy)
+	return copy
+}
+
+// Connect to Redis, through sentinel when prefix.sentinel.masterName is set
 func (c *Client) Connect(prefix string, client interfaces.RedisClient) error {
+	masterName := c.Config.GetString(fmt.Sprintf("%s.sentinel.masterName", prefix))
+	url := c.Config.GetString(fmt.Sprintf("%s.url", prefix))
+
+	if masterName != "" && url == "" {
+		c.Options = &redis.Options{}
+	} else {
+		var err error
+		c.Options, err = redis.ParseURL(url)
+		if err != nil {
+			return err
+		}
+	}
+
+	if client != nil {
+		c.Client = client
+		return nil
+	}
+
+	if masterName == "" {
+		c.Client = redis.NewClient(c.Options)
+		return nil
+	}
+
+	if c.Config.GetString(fmt.Sprintf("%s.sentinel.password", prefix)) != "" {
+		return fmt.Errorf("sentinel password is not supported by go-redis v6")
+	}
+	c.Client = redis.NewFailoverClient(&redis.FailoverOptions{
+		MasterName:    masterName,
+		SentinelAddrs: c.Config.GetStringSlice(fmt.Sprintf("%s.sentinel.addrs", prefix)),
+		Password:      c.Options.Password,
+		DB:            c.Options.DB,
+		MaxRetries:    c.Options.MaxRetries,
+		DialTimeout:   c.Options.DialTimeout,
+		ReadTimeout:   c.Options.ReadTimeout,
+		WriteTimeout:  c.Options.WriteTimeout,
+		PoolSize:      c.Options.PoolSize,
+		TLSConfig:     c.Options.TLSConfig,
+	})
+
+	return nil
+}
+
+// ConnectReplica to the Redis replica at prefix.replicaUrl when prefix.replicaReads is set.
+// go-redis v6 cannot route commands by itself, read-only commands go to the replica when
+// issued on TraceReplica
+func (c *Client) ConnectReplica(prefix string, client interfaces.RedisClient) error {
+	if !c.Config.GetBool(fmt.Sprintf("%s.replicaReads", prefix)) {
+		return nil
+	}
+
+	url := c.Config.GetString(fmt.Sprintf("%s.replicaUrl", prefix))
+	if url == "" {
+		return fmt.Errorf("replica reads need %s.replicaUrl", prefix)
+	}
 	var err error
-	c.Options, err = redis.ParseURL(c.Config.GetString(fmt.Sprintf("%s.url", prefix)))
+	c.ReplicaOptions, err = redis.ParseURL(url)
 	if err != nil {
 		return err
 	}
 
 	if client == nil {
-		c.Client = redis.NewClient(c.Options)
+		c.Replica = redis.NewClient(c.ReplicaOptions)
 	} else {
-		c.Client = client
+		c.Replica = client
 	}
 
 	return nil
@@ -124,7 +223,11 @@ func (c *Client) Connect(prefix string, client interfaces.RedisClient) error {
 
 // IsConnected determines if the client is connected to redis
 func (c *Client) IsConnected() bool {
-	result := c.Client.Ping()
+	return isConnected(c.Client)
+}
+
+func isConnected(client interfaces.RedisClient) bool {
+	result := client.Ping()
 	if result != nil {
 		res, err := result.Result()
 		if err != nil {
@@ -150,7 +253,7 @@ func (c *Client) LeaveCriticalSection(lock *lock.Lock) error {
 	return lock.Unlock()
 }
 
-// WaitForConnection loops until redis is connected
+// WaitForConnection loops until redis is connected, and the replica too when there is one
 func (c *Client) WaitForConnection(timeout int) error {
 	t := time.Duration(timeout) * time.Second
 	timeoutTimer := time.NewTimer(t)
@@ -163,20 +266,27 @@ func (c *Client) WaitForConnection(timeout int) error {
 		case <-timeoutTimer.C:
 			return fmt.Errorf("timed out waiting for Redis to connect")
 		case <-ticker.C:
-			if c.IsConnected() {
+			if c.IsConnected() && (c.Replica == nil || isConnected(c.Replica)) {
 				return nil
 			}
 		}
 	}
 }
 
-// Close the connections to redis
+// Close the connections to redis, to both the primary and the replica when there is one
 func (c *Client) Close() error {
 	err := c.Client.Close()
-	if err != nil {
+	if c.Replica == nil {
 		return err
 	}
-	return nil
+	replicaErr := c.Replica.Close()
+	if err == nil {
+		return replicaErr
+	}
+	if replicaErr != nil {
+		return fmt.Errorf("%v, closing the replica: %v", err, replicaErr)
+	}
+	return err
 }
 
 // Cleanup closes redis connection
@@ -23,6 +23,7 @@
 package redis
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -32,6 +33,7 @@ import (
 	. "github.com/onsi/gomega"
 	"github.com/spf13/viper"
 	"github.com/topfreegames/extensions/v9/redis/mocks"
+	"github.com/topfreegames/extensions/v9/redis/scripting"
 )
 
 var _ = Describe("Redis Extension", func() {
@@ -53,6 +55,28 @@ var _ = Describe("Redis Extension", func() {
 
 	Describe("[Unit]", func() {
 		Describe("Connect", func() {
+			It("should preload the scripts of a registry", func() {
+				scripts := scripting.NewRegistry(nil)
+				script := scripts.Register("one", "return 1")
+				mockClient.EXPECT().Ping()
+				mockClient.EXPECT().ScriptLoad("return 1").Return(redis.NewStringResult(script.SHA1, nil))
+				_, err := NewClient("extensions.redis", config, mockClient, nil, nil, scripts)
+				Expect(err).NotTo(HaveOccurred())
+
+				mockClient.EXPECT().EvalSha(script.SHA1, []string{}).Return(redis.NewCmdResult(int64(1), nil))
+				Expect(scripts.Run(context.Background(), "one", []string{})).To(Equal(int64(1)))
+			})
+
+			It("should fail if the scripts cannot be loaded", func() {
+				scripts := scripting.NewRegistry(nil)
+				scripts.Register("one", "return 1")
+				mockClient.EXPECT().Ping()
+				mockClient.EXPECT().ScriptLoad("return 1").Return(redis.NewStringResult("", fmt.Errorf("NOPERM")))
+				mockClient.EXPECT().Close()
+				_, err := NewClient("extensions.redis", config, mockClient, scripts)
+				Expect(err).To(MatchError(ContainSubstring("failed to load redis scripts")))
+			})
+
 			It("Should use config to load connection details", func() {
 				mockClient.EXPECT().Ping()
 				client, err := NewClient("extensions.redis", config, mockClient)
@@ -130,6 +154,119 @@ var _ = Describe("Redis Extension", func() {
 			})
 		})
 
+		Describe("Sentinel", func() {
+			It("should connect through sentinel", func() {
+				config.Set("extensions.redis.sentinel.masterName", "mymaster")
+				config.Set("extensions.redis.sentinel.addrs", []string{"localhost:26379"})
+				client := &Client{Config: config}
+				Expect(client.Connect("extensions.redis", nil)).To(Succeed())
+				defer client.Close()
+				Expect(client.Client.(*redis.Client).Options().Addr).To(Equal("FailoverClient"))
+				Expect(client.Client.(*redis.Client).Options().DB).To(Equal(0))
+			})
+
+			It("should not support sentinel passwords", func() {
+				config.Set("extensions.redis.sentinel.masterName", "mymaster")
+				config.Set("extensions.redis.sentinel.password", "pass")
+				client := &Client{Config: config}
+				Expect(client.Connect("extensions.redis", nil)).To(MatchError("sentinel password is not supported by go-redis v6"))
+			})
+		})
+
+		Describe("Replica reads", func() {
+			var mockReplica *mocks.MockRedisClient
+
+			BeforeEach(func() {
+				mockReplica = mocks.NewMockRedisClient(mockCtrl)
+				config.Set("extensions.redis.replicaReads", true)
+				config.Set("extensions.redis.replicaUrl", "redis://replica:6379/0")
+			})
+
+			It("should send read-only commands to the replica", func() {
+				mockTraceWrapper := mocks.NewMockTraceWrapper(mockCtrl)
+				mockClient.EXPECT().Ping()
+				mockReplica.EXPECT().Ping()
+				client, err := NewClient("extensions.redis", config, mockClient, mockTraceWrapper, mockReplica)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(client.ReplicaOptions.Addr).To(Equal("replica:6379"))
+
+				ctx := context.Background()
+				mockTraceWrapper.EXPECT().WithContext(ctx, mockReplica).Return(mockReplica)
+				Expect(client.TraceReplica(ctx)).To(Equal(mockReplica))
+			})
+
+			It("should send read-only commands to the primary without replica reads", func() {
+				config.Set("extensions.redis.replicaReads", false)
+				mockTraceWrapper := mocks.NewMockTraceWrapper(mockCtrl)
+				mockClient.EXPECT().Ping()
+				client, err := NewClient("extensions.redis", config, mockClient, mockTraceWrapper, mockReplica)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(client.Replica).To(BeNil())
+
+				ctx := context.Background()
+				mockTraceWrapper.EXPECT().WithContext(ctx, mockClient).Return(mockClient)
+				Expect(client.TraceReplica(ctx)).To(Equal(mockClient))
+			})
+
+			It("should need a replica url", func() {
+				config.Set("extensions.redis.replicaUrl", "")
+				_, err := NewClient("extensions.redis", config, mockClient)
+				Expect(err).To(MatchError("replica reads need extensions.redis.replicaUrl"))
+			})
+
+			It("should wait for the replica to connect", func() {
+				mockClient.EXPECT().Ping().AnyTimes()
+				mockReplica.EXPECT().Ping().Return(redis.NewStatusResult("", fmt.Errorf("connection refused"))).AnyTimes()
+				config.Set("extensions.redis.connectionTimeout", 1)
+				_, err := NewClient("extensions.redis", config, mockClient, nil, mockReplica)
+				Expect(err).To(MatchError("timed out waiting for Redis to connect"))
+			})
+
+			It("should close the replica when the scripts cannot be loaded", func() {
+				scripts := scripting.NewRegistry(nil)
+				scripts.Register("one", "return 1")
+				mockClient.EXPECT().Ping()
+				mockReplica.EXPECT().Ping()
+				mockClient.EXPECT().ScriptLoad("return 1").Return(redis.NewStringResult("", fmt.Errorf("NOPERM")))
+				mockClient.EXPECT().Close()
+				mockReplica.EXPECT().Close()
+				_, err := NewClient("extensions.redis", config, mockClient, nil, mockReplica, scripts)
+				Expect(err).To(MatchError(ContainSubstring("failed to load redis scripts")))
+			})
+
+			It("should close the replica", func() {
+				mockClient.EXPECT().Ping()
+				mockReplica.EXPECT().Ping()
+				client, err := NewClient("extensions.redis", config, mockClient, nil, mockReplica)
+				Expect(err).NotTo(HaveOccurred())
+				mockClient.EXPECT().Close()
+				mockReplica.EXPECT().Close()
+				Expect(client.Close()).To(Succeed())
+			})
+
+			It("should close the replica when closing the primary fails", func() {
+				mockClient.EXPECT().Ping()
+				mockReplica.EXPECT().Ping()
+				client, err := NewClient("extensions.redis", config, mockClient, nil, mockReplica)
+				Expect(err).NotTo(HaveOccurred())
+				mockClient.EXPECT().Close().Return(fmt.Errorf("primary error"))
+				mockReplica.EXPECT().Close().Return(fmt.Errorf("replica error"))
+				err = client.Close()
+				Expect(err).To(MatchError(ContainSubstring("primary error")))
+				Expect(err).To(MatchError(ContainSubstring("replica error")))
+			})
+
+			It("should return the replica close error", func() {
+				mockClient.EXPECT().Ping()
+				mockReplica.EXPECT().Ping()
+				client, err := NewClient("extensions.redis", config, mockClient, nil, mockReplica)
+				Expect(err).NotTo(HaveOccurred())
+				mockClient.EXPECT().Close()
+				mockReplica.EXPECT().Close().Return(fmt.Errorf("replica error"))
+				Expect(client.Close()).To(MatchError("replica error"))
+			})
+		})
+
 		Describe("EnterCriticalSection", func() {
 			It("should lock in redis", func() {
 				mockClient.EXPECT().Ping()
@@ -0,0 +1,110 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package scripting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var errNotConnected = errors.New("the script registry is not connected to a client")
+
+// Registry keeps the scripts used with a client so they can be preloaded at connect time
+// and run by name
+type Registry struct {
+	client  Evaler
+	mutex   sync.RWMutex
+	scripts map[string]*Script
+}
+
+// NewRegistry creates a Registry for the scripts run on client. client may be nil when the
+// registry is given to a client constructor, which connects it once the client is created
+func NewRegistry(client Evaler) *Registry {
+	return &Registry{
+		client:  client,
+		scripts: map[string]*Script{},
+	}
+}
+
+// Register adds a script to the registry, replacing any script with the same name
+func (r *Registry) Register(name, source string) *Script {
+	script := NewScript(name, source)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.scripts[name] = script
+	return script
+}
+
+// Get returns the script registered with name
+func (r *Registry) Get(name string) (*Script, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	script, ok := r.scripts[name]
+	return script, ok
+}
+
+// Connect sets the client the scripts run on and preloads them on it, it is called by the
+// client constructors given the registry
+func (r *Registry) Connect(ctx context.Context, client Evaler) error {
+	r.mutex.Lock()
+	r.client = client
+	r.mutex.Unlock()
+	return r.Load(ctx)
+}
+
+// Load caches every registered script on the server, it should be called once connected.
+// Scripts missing later on, after a failover for instance, are loaded again when run
+func (r *Registry) Load(ctx context.Context) error {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if r.client == nil {
+		return errNotConnected
+	}
+	for name, script := range r.scripts {
+		sha, err := r.client.ScriptLoad(ctx, script.Source)
+		if err != nil {
+			return fmt.Errorf("failed to load script %s: %w", name, err)
+		}
+		if sha != script.SHA1 {
+			return fmt.Errorf("script %s was loaded as %s instead of %s", name, sha, script.SHA1)
+		}
+	}
+	return nil
+}
+
+// Run runs the script registered with name
+func (r *Registry) Run(ctx context.Context, name string, keys []string, args ...interface{}) (interface{}, error) {
+	script, ok := r.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("script %s is not registered", name)
+	}
+	r.mutex.RLock()
+	client := r.client
+	r.mutex.RUnlock()
+	if client == nil {
+		return nil, errNotConnected
+	}
+	return script.Run(ctx, client, keys, args...)
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright (c) 2016 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package scripting
+
+import (
+	"context"
+
+	redisv6 "github.com/go-redis/redis"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/topfreegames/extensions/v9/redis/mocks"
+)
+
+var _ = Describe("Registry", func() {
+	var mockCtrl *gomock.Controller
+	var mockClient *mocks.MockRedisClient
+	var registry *Registry
+	ctx := context.Background()
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockClient = mocks.NewMockRedisClient(mockCtrl)
+		registry = NewRegistry(FromV6(mockClient))
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Describe("[Unit]", func() {
+		It("should preload registered scripts", func() {
+			script := registry.Register("one", "return 1")
+			mockClient.EXPECT().ScriptLoad("return 1").Return(redisv6.NewStringResult(script.SHA1, nil))
+			Expect(registry.Load(ctx)).To(Succeed())
+		})
+
+		It("should fail when the server hashes a script differently", func() {
+			registry.Register("one", "return 1")
+			mockClient.EXPECT().ScriptLoad("return 1").Return(redisv6.NewStringResult("other", nil))
+			Expect(registry.Load(ctx)).To(HaveOccurred())
+		})
+
+		It("should preload the scripts when connected to a client", func() {
+			registry = NewRegistry(nil)
+			script := registry.Register("one", "return 1")
+			_, err := registry.Run(ctx, "one", []string{})
+			Expect(err).To(MatchError(errNotConnected))
+
+			mockClient.EXPECT().ScriptLoad("return 1").Return(redisv6.NewStringResult(script.SHA1, nil))
+			Expect(registry.Connect(ctx, FromV6(mockClient))).To(Succeed())
+			mockClient.EXPECT().EvalSha(script.SHA1, []string{}).Return(redisv6.NewCmdResult(int64(1), nil))
+			Expect(registry.Run(ctx, "one", []string{})).To(Equal(int64(1)))
+		})
+
+		It("should run scripts by name", func() {
+			script := registry.Register("one", "return 1")
+			mockClient.EXPECT().EvalSha(script.SHA1, []string{}).Return(redisv6.NewCmdResult(int64(1), nil))
+			res, err := registry.Run(ctx, "one", []string{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(Equal(int64(1)))
+
+			_, err = registry.Run(ctx, "two", nil)
+			Expect(err).To(MatchError("script two is not registered"))
+		})
+	})
+})
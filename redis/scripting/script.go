@@ -0,0 +1,100 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package scripting
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/topfreegames/extensions/v9/tracing"
+)
+
+// Script is a Lua script run with EVALSHA, falling back to EVAL when the server does
+// not have it cached, as happens after a restart or a failover to a replica
+type Script struct {
+	Name   string
+	Source string
+	SHA1   string
+}
+
+// NewScript creates a Script, name identifies it in traces
+func NewScript(name, source string) *Script {
+	sum := sha1.Sum([]byte(source))
+	return &Script{
+		Name:   name,
+		Source: source,
+		SHA1:   hex.EncodeToString(sum[:]),
+	}
+}
+
+// Load caches the script on the server, in cluster mode the v8 and v9 clients
+// load it on every shard
+func (s *Script) Load(ctx context.Context, client Evaler) error {
+	_, err := client.ScriptLoad(ctx, s.Source)
+	return err
+}
+
+// Run runs the script with EVALSHA, retrying with EVAL if the server replies NOSCRIPT.
+// EVAL caches the script again, so the following runs on that server use EVALSHA
+func (s *Script) Run(ctx context.Context, client Evaler, keys []string, args ...interface{}) (interface{}, error) {
+	span, ctx := s.startSpan(ctx, keys)
+	defer span.Finish()
+	defer tracing.LogPanic(span)
+
+	res, err := client.EvalSha(ctx, s.SHA1, keys, args...)
+	if IsNoScript(err) {
+		span.SetTag("redis.script.fallback", true)
+		res, err = client.Eval(ctx, s.Source, keys, args...)
+	}
+	if err != nil && !IsNil(err) {
+		tracing.LogError(span, err.Error())
+	}
+	return res, err
+}
+
+func (s *Script) startSpan(ctx context.Context, keys []string) (opentracing.Span, context.Context) {
+	var parent opentracing.SpanContext
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		parent = span.Context()
+	}
+
+	operationName := "redis script " + s.Name
+	tags := opentracing.Tags{
+		"db.statement": "evalsha " + s.SHA1 + " " + strings.Join(keys, " "),
+		"db.type":      "redis",
+		"span.kind":    "client",
+	}
+	tags = tracing.RunCustomTracingTagsHooks(ctx, tags)
+
+	span := opentracing.StartSpan(operationName, opentracing.ChildOf(parent), tags)
+	tracing.RunCustomTracingHooks(ctx, operationName, span)
+	return span, opentracing.ContextWithSpan(ctx, span)
+}
+
+// IsNoScript reports whether err is the NOSCRIPT reply of a server missing a script
+func IsNoScript(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "NOSCRIPT")
+}
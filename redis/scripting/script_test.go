@@ -0,0 +1,103 @@
+/*
+ * Copyright (c) 2016 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package scripting
+
+import (
+	"context"
+	"errors"
+
+	redisv6 "github.com/go-redis/redis"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/topfreegames/extensions/v9/redis/mocks"
+)
+
+var _ = Describe("Script", func() {
+	var mockCtrl *gomock.Controller
+	var mockClient *mocks.MockRedisClient
+	var tracer *mocktracer.MockTracer
+	script := NewScript("incr", `return redis.call("INCR", KEYS[1])`)
+	noScript := errors.New("NOSCRIPT No matching script. Please use EVAL.")
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockClient = mocks.NewMockRedisClient(mockCtrl)
+		tracer = mocktracer.New()
+		opentracing.SetGlobalTracer(tracer)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+		opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+	})
+
+	Describe("[Unit]", func() {
+		It("should hash the source", func() {
+			Expect(script.SHA1).To(Equal("f793247de6e1e3c553cd42d39c812df499e679e4"))
+		})
+
+		It("should run with EVALSHA", func() {
+			mockClient.EXPECT().EvalSha(script.SHA1, []string{"key"}).Return(redisv6.NewCmdResult(int64(1), nil))
+			res, err := script.Run(context.Background(), FromV6(mockClient), []string{"key"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(Equal(int64(1)))
+		})
+
+		It("should fall back to EVAL on NOSCRIPT", func() {
+			gomock.InOrder(
+				mockClient.EXPECT().EvalSha(script.SHA1, []string{"key"}, 1).Return(redisv6.NewCmdResult(nil, noScript)),
+				mockClient.EXPECT().Eval(script.Source, []string{"key"}, 1).Return(redisv6.NewCmdResult(int64(2), nil)),
+			)
+			res, err := script.Run(context.Background(), FromV6(mockClient), []string{"key"}, 1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(Equal(int64(2)))
+
+			finished := tracer.FinishedSpans()
+			Expect(finished).To(HaveLen(1))
+			Expect(finished[0].Tag("redis.script.fallback")).To(Equal(true))
+		})
+
+		It("should not fall back on other errors", func() {
+			mockClient.EXPECT().EvalSha(script.SHA1, []string{"key"}).Return(redisv6.NewCmdResult(nil, errors.New("ERR wrong type")))
+			_, err := script.Run(context.Background(), FromV6(mockClient), []string{"key"})
+			Expect(err).To(MatchError("ERR wrong type"))
+		})
+
+		It("should trace runs as children of the active span", func() {
+			parent := tracer.StartSpan("parent")
+			ctx := opentracing.ContextWithSpan(context.Background(), parent)
+			mockClient.EXPECT().EvalSha(script.SHA1, []string{"key"}).Return(redisv6.NewCmdResult(int64(1), nil))
+			_, err := script.Run(ctx, FromV6(mockClient), []string{"key"})
+			Expect(err).NotTo(HaveOccurred())
+
+			finished := tracer.FinishedSpans()
+			Expect(finished).To(HaveLen(1))
+			Expect(finished[0].OperationName).To(Equal("redis script incr"))
+			Expect(finished[0].ParentID).To(Equal(parent.(*mocktracer.MockSpan).SpanContext.SpanID))
+			Expect(finished[0].Tag("db.type")).To(Equal("redis"))
+		})
+	})
+})
@@ -0,0 +1,146 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+// Package scripting runs Lua scripts on any of the redis clients supported by the extensions
+package scripting
+
+import (
+	"context"
+	"errors"
+
+	redisv6 "github.com/go-redis/redis"
+	redisv8 "github.com/go-redis/redis/v8"
+	redisv9 "github.com/redis/go-redis/v9"
+	"github.com/topfreegames/extensions/v9/redis/interfaces"
+)
+
+// ErrNil is returned when a script replies with nil, regardless of the client in use
+var ErrNil = errors.New("redis: nil")
+
+// Evaler is the contract for running scripts on a redis client
+type Evaler interface {
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+	EvalSha(ctx context.Context, sha1 string, keys []string, args ...interface{}) (interface{}, error)
+	ScriptExists(ctx context.Context, hashes ...string) ([]bool, error)
+	ScriptLoad(ctx context.Context, script string) (string, error)
+}
+
+// FromV6 adapts a go-redis v6 client, as used by redis.Client, to an Evaler.
+// The v6 client has no per command context, so ctx is only checked before each command
+func FromV6(client interfaces.RedisClient) Evaler {
+	return &v6Evaler{client: client}
+}
+
+// FromV8 adapts a go-redis v8 client, as returned by redis/v8.NewClient, to an Evaler
+func FromV8(client redisv8.Scripter) Evaler {
+	return &v8Evaler{client: client}
+}
+
+// FromV9 adapts a go-redis v9 client, as held by redis/cluster.Client, to an Evaler
+func FromV9(client redisv9.Scripter) Evaler {
+	return &v9Evaler{client: client}
+}
+
+type v6Evaler struct {
+	client interfaces.RedisClient
+}
+
+func (e *v6Evaler) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return normalize(e.client.Eval(script, keys, args...).Result())
+}
+
+func (e *v6Evaler) EvalSha(ctx context.Context, sha1 string, keys []string, args ...interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return normalize(e.client.EvalSha(sha1, keys, args...).Result())
+}
+
+func (e *v6Evaler) ScriptExists(ctx context.Context, hashes ...string) ([]bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return e.client.ScriptExists(hashes...).Result()
+}
+
+func (e *v6Evaler) ScriptLoad(ctx context.Context, script string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return e.client.ScriptLoad(script).Result()
+}
+
+type v8Evaler struct {
+	client redisv8.Scripter
+}
+
+func (e *v8Evaler) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	return normalize(e.client.Eval(ctx, script, keys, args...).Result())
+}
+
+func (e *v8Evaler) EvalSha(ctx context.Context, sha1 string, keys []string, args ...interface{}) (interface{}, error) {
+	return normalize(e.client.EvalSha(ctx, sha1, keys, args...).Result())
+}
+
+func (e *v8Evaler) ScriptExists(ctx context.Context, hashes ...string) ([]bool, error) {
+	return e.client.ScriptExists(ctx, hashes...).Result()
+}
+
+func (e *v8Evaler) ScriptLoad(ctx context.Context, script string) (string, error) {
+	return e.client.ScriptLoad(ctx, script).Result()
+}
+
+type v9Evaler struct {
+	client redisv9.Scripter
+}
+
+func (e *v9Evaler) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	return normalize(e.client.Eval(ctx, script, keys, args...).Result())
+}
+
+func (e *v9Evaler) EvalSha(ctx context.Context, sha1 string, keys []string, args ...interface{}) (interface{}, error) {
+	return normalize(e.client.EvalSha(ctx, sha1, keys, args...).Result())
+}
+
+func (e *v9Evaler) ScriptExists(ctx context.Context, hashes ...string) ([]bool, error) {
+	return e.client.ScriptExists(ctx, hashes...).Result()
+}
+
+func (e *v9Evaler) ScriptLoad(ctx context.Context, script string) (string, error) {
+	return e.client.ScriptLoad(ctx, script).Result()
+}
+
+// normalize replaces the nil error of each client version with ErrNil
+func normalize(result interface{}, err error) (interface{}, error) {
+	if IsNil(err) {
+		return nil, ErrNil
+	}
+	return result, err
+}
+
+// IsNil reports whether err is a nil reply from any of the supported clients
+func IsNil(err error) bool {
+	return err == ErrNil || err == redisv6.Nil || err == redisv8.Nil || err == redisv9.Nil
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright (c) 2016 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package scripting
+
+import (
+	"context"
+
+	redisv6 "github.com/go-redis/redis"
+	redisv8 "github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	redisv9 "github.com/redis/go-redis/v9"
+	"github.com/topfreegames/extensions/v9/redis/mocks"
+)
+
+var _ = Describe("Scripting", func() {
+	var mockCtrl *gomock.Controller
+	var mockClient *mocks.MockRedisClient
+	ctx := context.Background()
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockClient = mocks.NewMockRedisClient(mockCtrl)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Describe("[Unit]", func() {
+		It("should run scripts on a v6 client", func() {
+			cmd := redisv6.NewCmdResult(int64(1), nil)
+			mockClient.EXPECT().Eval("return 1", []string{"key"}, "arg").Return(cmd)
+			res, err := FromV6(mockClient).Eval(ctx, "return 1", []string{"key"}, "arg")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(Equal(int64(1)))
+		})
+
+		It("should normalize nil replies", func() {
+			cmd := redisv6.NewCmdResult(nil, redisv6.Nil)
+			mockClient.EXPECT().EvalSha("sha", []string{"key"}).Return(cmd)
+			_, err := FromV6(mockClient).EvalSha(ctx, "sha", []string{"key"})
+			Expect(err).To(Equal(ErrNil))
+		})
+
+		It("should not run v6 commands after the context is done", func() {
+			canceled, cancel := context.WithCancel(ctx)
+			cancel()
+			_, err := FromV6(mockClient).Eval(canceled, "return 1", nil)
+			Expect(err).To(Equal(context.Canceled))
+		})
+
+		It("should recognize nil errors of every client", func() {
+			Expect(IsNil(ErrNil)).To(BeTrue())
+			Expect(IsNil(redisv6.Nil)).To(BeTrue())
+			Expect(IsNil(redisv8.Nil)).To(BeTrue())
+			Expect(IsNil(redisv9.Nil)).To(BeTrue())
+			Expect(IsNil(context.Canceled)).To(BeFalse())
+		})
+	})
+})
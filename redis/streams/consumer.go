@@ -0,0 +1,476 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+// Package streams implements interfaces.Queue on redis streams consumer groups
+package streams
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Consumer reads entries of a stream as a member of a consumer group with XREADGROUP,
+// reclaiming with XAUTOCLAIM the entries left pending by consumers that died
+type Consumer struct {
+	Config *viper.Viper
+	Logger *logrus.Logger
+	Client redis.UniversalClient
+	// Stream is the key of the stream, Group the consumer group and Name this consumer in the group
+	Stream string
+	Group  string
+	Name   string
+	// Field is the entry field holding the message payload
+	Field string
+	// StartID is where a new group starts reading, "$" for new entries only or "0" for the whole stream
+	StartID     string
+	ChannelSize int
+	BatchSize   int64
+	Block       time.Duration
+	// MinIdle is how long an entry stays pending before it is reclaimed from its consumer
+	MinIdle       time.Duration
+	ClaimInterval time.Duration
+	// MaxDeliveries moves entries delivered this many times to DeadLetterStream, 0 disables it
+	MaxDeliveries    int64
+	DeadLetterStream string
+	RetryInterval    time.Duration
+	// MessageHandles delivers messages to MessageHandlesChannel to be acknowledged once processed.
+	// Otherwise entries are acknowledged as soon as they are delivered to MessagesChannel
+	MessageHandles                 bool
+	HandleAllMessagesBeforeExiting bool
+
+	msgChan           chan []byte
+	handlesChan       chan *Message
+	pendingMessagesWG *sync.WaitGroup
+	run               bool
+	runMutex          sync.Mutex
+	stopChan          chan struct{}
+}
+
+// NewConsumer for creating a new Consumer instance
+func NewConsumer(
+	config *viper.Viper,
+	logger *logrus.Logger,
+	client redis.UniversalClient,
+) (*Consumer, error) {
+	return NewConsumerWithPrefix(config, logger, "extensions.redisstreams", client)
+}
+
+// NewConsumerWithPrefix for creating a new Consumer instance
+func NewConsumerWithPrefix(
+	config *viper.Viper,
+	logger *logrus.Logger,
+	prefix string,
+	client redis.UniversalClient,
+) (*Consumer, error) {
+	if prefix != "" {
+		prefix += "."
+	}
+	if client == nil {
+		return nil, fmt.Errorf("a redis client is required")
+	}
+	q := &Consumer{
+		Config: config,
+		Logger: logger,
+		Client: client,
+	}
+	err := q.configure(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *Consumer) loadConfigurationDefaults(prefix string) {
+	q.Config.SetDefault(prefix+"stream", "stream")
+	q.Config.SetDefault(prefix+"group", "test")
+	q.Config.SetDefault(prefix+"name", "")
+	q.Config.SetDefault(prefix+"field", "data")
+	q.Config.SetDefault(prefix+"startID", "$")
+	q.Config.SetDefault(prefix+"channelSize", 100)
+	q.Config.SetDefault(prefix+"batchSize", 10)
+	q.Config.SetDefault(prefix+"block", 1000)
+	q.Config.SetDefault(prefix+"minIdle", 60000)
+	q.Config.SetDefault(prefix+"claimInterval", 30000)
+	q.Config.SetDefault(prefix+"maxDeliveries", 0)
+	q.Config.SetDefault(prefix+"deadLetterStream", "")
+	q.Config.SetDefault(prefix+"retryInterval", 1000)
+	q.Config.SetDefault(prefix+"messageHandles", false)
+	q.Config.SetDefault(prefix+"handleAllMessagesBeforeExiting", true)
+}
+
+func (q *Consumer) configure(prefix string) error {
+	q.loadConfigurationDefaults(prefix)
+	q.Stream = q.Config.GetString(prefix + "stream")
+	q.Group = q.Config.GetString(prefix + "group")
+	q.Name = q.Config.GetString(prefix + "name")
+	q.Field = q.Config.GetString(prefix + "field")
+	q.StartID = q.Config.GetString(prefix + "startID")
+	q.ChannelSize = q.Config.GetInt(prefix + "channelSize")
+	q.BatchSize = q.Config.GetInt64(prefix + "batchSize")
+	q.Block = time.Duration(q.Config.GetInt(prefix+"block")) * time.Millisecond
+	q.MinIdle = time.Duration(q.Config.GetInt(prefix+"minIdle")) * time.Millisecond
+	q.ClaimInterval = time.Duration(q.Config.GetInt(prefix+"claimInterval")) * time.Millisecond
+	q.MaxDeliveries = q.Config.GetInt64(prefix + "maxDeliveries")
+	q.DeadLetterStream = q.Config.GetString(prefix + "deadLetterStream")
+	q.RetryInterval = time.Duration(q.Config.GetInt(prefix+"retryInterval")) * time.Millisecond
+	q.MessageHandles = q.Config.GetBool(prefix + "messageHandles")
+	q.HandleAllMessagesBeforeExiting = q.Config.GetBool(prefix + "handleAllMessagesBeforeExiting")
+
+	if q.Name == "" {
+		return fmt.Errorf("%sname must identify the consumer in the group", prefix)
+	}
+	if q.BatchSize < 1 {
+		return fmt.Errorf("%sbatchSize must be at least 1", prefix)
+	}
+	if q.MaxDeliveries > 0 && q.DeadLetterStream == "" {
+		return fmt.Errorf("%sdeadLetterStream is required when maxDeliveries is set", prefix)
+	}
+
+	q.msgChan = make(chan []byte, q.ChannelSize)
+	q.handlesChan = make(chan *Message, q.ChannelSize)
+
+	if q.HandleAllMessagesBeforeExiting {
+		var wg sync.WaitGroup
+		q.pendingMessagesWG = &wg
+	}
+	return nil
+}
+
+// PendingMessagesWaitGroup returns the waitGroup that is incremented every time a message is consumed
+func (q *Consumer) PendingMessagesWaitGroup() *sync.WaitGroup {
+	return q.pendingMessagesWG
+}
+
+// MessagesChannel returns the channel that will receive the payload of every entry
+func (q *Consumer) MessagesChannel() *chan []byte {
+	return &q.msgChan
+}
+
+// MessageHandlesChannel returns the channel that will receive every entry when MessageHandles
+// is enabled. Each message must be acknowledged with Message.Ack
+func (q *Consumer) MessageHandlesChannel() *chan *Message {
+	return &q.handlesChan
+}
+
+// StopConsuming stops consuming messages from the stream
+func (q *Consumer) StopConsuming() {
+	q.runMutex.Lock()
+	defer q.runMutex.Unlock()
+	if q.run && q.stopChan != nil {
+		close(q.stopChan)
+	}
+	q.run = false
+}
+
+func (q *Consumer) startRunning() chan struct{} {
+	q.runMutex.Lock()
+	defer q.runMutex.Unlock()
+	q.run = true
+	q.stopChan = make(chan struct{})
+	return q.stopChan
+}
+
+func (q *Consumer) isRunning() bool {
+	q.runMutex.Lock()
+	defer q.runMutex.Unlock()
+	return q.run
+}
+
+// ConsumeLoop creates the consumer group if needed and delivers entries until StopConsuming is called.
+// Entries this consumer left pending in a previous run are delivered first
+func (q *Consumer) ConsumeLoop() error {
+	stop := q.startRunning()
+	l := q.Logger.WithFields(logrus.Fields{
+		"method": "ConsumeLoop",
+		"stream": q.Stream,
+		"group":  q.Group,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	err := q.createGroup(ctx)
+	if err != nil {
+		l.WithError(err).Error("error creating consumer group")
+		q.StopConsuming()
+		return err
+	}
+	l.Info("successfully joined consumer group")
+
+	id := "0"
+	lastClaim := time.Now()
+	for q.isRunning() {
+		if time.Since(lastClaim) >= q.ClaimInterval {
+			lastClaim = time.Now()
+			err = q.reclaim(ctx, stop)
+			if err != nil && ctx.Err() == nil {
+				l.WithError(err).Error("error reclaiming pending entries")
+			}
+		}
+
+		messages, err := q.read(ctx, id)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			l.WithError(err).Error("error reading from stream")
+			q.wait(stop, q.RetryInterval)
+			continue
+		}
+		if id != ">" && len(messages) == 0 {
+			id = ">"
+			continue
+		}
+		for _, m := range messages {
+			q.deliver(ctx, m, stop)
+		}
+		if id != ">" && len(messages) > 0 {
+			id = messages[len(messages)-1].ID
+		}
+	}
+	return nil
+}
+
+func (q *Consumer) createGroup(ctx context.Context) error {
+	err := q.Client.XGroupCreateMkStream(ctx, q.Stream, q.Group, q.StartID).Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+// read reads new entries with the ">" id, or the entries pending for this consumer after id
+func (q *Consumer) read(ctx context.Context, id string) ([]*Message, error) {
+	args := &redis.XReadGroupArgs{
+		Group:    q.Group,
+		Consumer: q.Name,
+		Streams:  []string{q.Stream, id},
+		Count:    q.BatchSize,
+		Block:    q.Block,
+	}
+	if id != ">" {
+		args.Block = -1
+	}
+	streams, err := q.Client.XReadGroup(ctx, args).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	messages := []*Message{}
+	for _, stream := range streams {
+		for _, entry := range stream.Messages {
+			messages = append(messages, q.newMessage(entry))
+		}
+	}
+	return messages, nil
+}
+
+// reclaim moves the entries delivered too many times to the dead-letter stream and
+// claims the entries idle for longer than MinIdle, delivering them to this consumer
+func (q *Consumer) reclaim(ctx context.Context, stop chan struct{}) error {
+	if q.MaxDeliveries > 0 {
+		err := q.deadLetter(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	start := "0-0"
+	for q.isRunning() {
+		entries, next, err := q.Client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   q.Stream,
+			Group:    q.Group,
+			Consumer: q.Name,
+			MinIdle:  q.MinIdle,
+			Start:    start,
+			Count:    q.BatchSize,
+		}).Result()
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			q.deliver(ctx, q.newMessage(entry), stop)
+		}
+		if next == "0-0" || next == "" {
+			return nil
+		}
+		start = next
+	}
+	return nil
+}
+
+// deadLetter moves idle entries delivered MaxDeliveries times to DeadLetterStream, paging
+// through the pending entries list BatchSize entries at a time
+func (q *Consumer) deadLetter(ctx context.Context) error {
+	start := "-"
+	for {
+		pending, err := q.Client.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream: q.Stream,
+			Group:  q.Group,
+			Idle:   q.MinIdle,
+			Start:  start,
+			End:    "+",
+			Count:  q.BatchSize,
+		}).Result()
+		if err != nil {
+			return err
+		}
+		for _, entry := range pending {
+			if entry.RetryCount < q.MaxDeliveries {
+				continue
+			}
+			err = q.moveToDeadLetter(ctx, entry)
+			if err != nil {
+				return err
+			}
+		}
+		if int64(len(pending)) < q.BatchSize {
+			return nil
+		}
+		start = "(" + pending[len(pending)-1].ID
+	}
+}
+
+func (q *Consumer) moveToDeadLetter(ctx context.Context, entry redis.XPendingExt) error {
+	entries, err := q.Client.XRange(ctx, q.Stream, entry.ID, entry.ID).Result()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		values := map[string]interface{}{
+			"stream":     q.Stream,
+			"id":         e.ID,
+			"deliveries": entry.RetryCount,
+		}
+		for k, v := range e.Values {
+			values[k] = v
+		}
+		err = q.Client.XAdd(ctx, &redis.XAddArgs{Stream: q.DeadLetterStream, Values: values}).Err()
+		if err != nil {
+			return err
+		}
+	}
+	err = q.Client.XAck(ctx, q.Stream, q.Group, entry.ID).Err()
+	if err != nil {
+		return err
+	}
+	q.Logger.WithFields(logrus.Fields{
+		"stream":     q.Stream,
+		"id":         entry.ID,
+		"deliveries": entry.RetryCount,
+	}).Warn("entry moved to dead-letter stream")
+	return nil
+}
+
+// Pending returns a summary of the entries delivered and not yet acknowledged in the group
+func (q *Consumer) Pending(ctx context.Context) (*redis.XPending, error) {
+	return q.Client.XPending(ctx, q.Stream, q.Group).Result()
+}
+
+func (q *Consumer) newMessage(entry redis.XMessage) *Message {
+	m := &Message{
+		ID:       entry.ID,
+		Stream:   q.Stream,
+		Values:   entry.Values,
+		consumer: q,
+	}
+	switch v := entry.Values[q.Field].(type) {
+	case string:
+		m.Value = []byte(v)
+	case []byte:
+		m.Value = v
+	}
+	return m
+}
+
+func (q *Consumer) deliver(ctx context.Context, m *Message, stop chan struct{}) {
+	l := q.Logger.WithFields(logrus.Fields{
+		"method": "deliver",
+		"id":     m.ID,
+	})
+	if q.pendingMessagesWG != nil {
+		q.pendingMessagesWG.Add(1)
+	}
+	if q.MessageHandles {
+		select {
+		case q.handlesChan <- m:
+		case <-stop:
+			q.dropMessage(l)
+		}
+		return
+	}
+	select {
+	case q.msgChan <- m.Value:
+	case <-stop:
+		q.dropMessage(l)
+		return
+	}
+	err := q.Client.XAck(ctx, q.Stream, q.Group, m.ID).Err()
+	if err != nil {
+		l.WithError(err).Error("error acknowledging entry")
+	}
+}
+
+// dropMessage releases an entry that could not be delivered because the consumer stopped,
+// it stays pending and is delivered again once reclaimed or when this consumer restarts
+func (q *Consumer) dropMessage(l *logrus.Entry) {
+	if q.pendingMessagesWG != nil {
+		q.pendingMessagesWG.Done()
+	}
+	l.Debug("Consumer stopped before the message was delivered.")
+}
+
+func (q *Consumer) ack(ctx context.Context, m *Message) error {
+	err := q.Client.XAck(ctx, q.Stream, q.Group, m.ID).Err()
+	if q.pendingMessagesWG != nil {
+		q.pendingMessagesWG.Done()
+	}
+	return err
+}
+
+func (q *Consumer) wait(stop chan struct{}, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-stop:
+	case <-timer.C:
+	}
+}
+
+// Cleanup stops consuming, the client is owned by the caller and is not closed
+func (q *Consumer) Cleanup() error {
+	q.StopConsuming()
+	return nil
+}
@@ -0,0 +1,241 @@
+/*
+ * Copyright (c) 2016 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package streams
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/interfaces"
+	"github.com/topfreegames/extensions/v9/redis/cluster/mocks"
+)
+
+var _ interfaces.Queue = &Consumer{}
+
+var _ = Describe("Consumer", func() {
+	logger, _ := test.NewNullLogger()
+	var mockCtrl *gomock.Controller
+	var client *mocks.MockUniversalClient
+	var config *viper.Viper
+
+	entry := func(id, data string) redis.XMessage {
+		return redis.XMessage{ID: id, Values: map[string]interface{}{"data": data}}
+	}
+
+	readArgs := func(id string, block time.Duration) *redis.XReadGroupArgs {
+		return &redis.XReadGroupArgs{
+			Group:    "group",
+			Consumer: "consumer-1",
+			Streams:  []string{"events", id},
+			Count:    10,
+			Block:    block,
+		}
+	}
+
+	newConsumer := func() *Consumer {
+		consumer, err := NewConsumer(config, logger, client)
+		Expect(err).NotTo(HaveOccurred())
+		return consumer
+	}
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		client = mocks.NewMockUniversalClient(mockCtrl)
+		config = viper.New()
+		config.Set("extensions.redisstreams.stream", "events")
+		config.Set("extensions.redisstreams.group", "group")
+		config.Set("extensions.redisstreams.name", "consumer-1")
+		config.Set("extensions.redisstreams.block", 10)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Describe("[Unit]", func() {
+		It("should configure defaults", func() {
+			consumer := newConsumer()
+			Expect(consumer.Field).To(Equal("data"))
+			Expect(consumer.StartID).To(Equal("$"))
+			Expect(consumer.BatchSize).To(BeEquivalentTo(10))
+			Expect(consumer.MinIdle).To(Equal(time.Minute))
+			Expect(consumer.PendingMessagesWaitGroup()).NotTo(BeNil())
+		})
+
+		It("should require a consumer name", func() {
+			config.Set("extensions.redisstreams.name", "")
+			_, err := NewConsumer(config, logger, client)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should require a dead-letter stream with max deliveries", func() {
+			config.Set("extensions.redisstreams.maxDeliveries", 3)
+			_, err := NewConsumer(config, logger, client)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should fail when the group cannot be created", func() {
+			client.EXPECT().XGroupCreateMkStream(gomock.Any(), "events", "group", "$").
+				Return(redis.NewStatusResult("", errors.New("WRONGTYPE")))
+			consumer := newConsumer()
+			Expect(consumer.ConsumeLoop()).To(MatchError("WRONGTYPE"))
+		})
+
+		It("should deliver pending and new entries and acknowledge them", func() {
+			consumer := newConsumer()
+			client.EXPECT().XGroupCreateMkStream(gomock.Any(), "events", "group", "$").
+				Return(redis.NewStatusResult("", errors.New("BUSYGROUP Consumer Group name already exists")))
+			gomock.InOrder(
+				client.EXPECT().XReadGroup(gomock.Any(), readArgs("0", -1)).
+					Return(redis.NewXStreamSliceCmdResult([]redis.XStream{{Stream: "events", Messages: []redis.XMessage{entry("1-0", "pending")}}}, nil)),
+				client.EXPECT().XReadGroup(gomock.Any(), readArgs("1-0", -1)).
+					Return(redis.NewXStreamSliceCmdResult([]redis.XStream{{Stream: "events"}}, nil)),
+				client.EXPECT().XReadGroup(gomock.Any(), readArgs(">", 10*time.Millisecond)).
+					Return(redis.NewXStreamSliceCmdResult([]redis.XStream{{Stream: "events", Messages: []redis.XMessage{entry("2-0", "new")}}}, nil)),
+				client.EXPECT().XReadGroup(gomock.Any(), readArgs(">", 10*time.Millisecond)).
+					Return(redis.NewXStreamSliceCmdResult(nil, redis.Nil)).AnyTimes(),
+			)
+			client.EXPECT().XAck(gomock.Any(), "events", "group", "1-0").Return(redis.NewIntResult(1, nil))
+			client.EXPECT().XAck(gomock.Any(), "events", "group", "2-0").Return(redis.NewIntResult(1, nil))
+
+			done := make(chan error)
+			go func() {
+				defer GinkgoRecover()
+				done <- consumer.ConsumeLoop()
+			}()
+			defer func() {
+				consumer.StopConsuming()
+				Eventually(done).Should(Receive(BeNil()))
+			}()
+			Eventually(*consumer.MessagesChannel()).Should(Receive(Equal([]byte("pending"))))
+			Eventually(*consumer.MessagesChannel()).Should(Receive(Equal([]byte("new"))))
+		})
+
+		It("should acknowledge message handles when processed", func() {
+			config.Set("extensions.redisstreams.messageHandles", true)
+			consumer := newConsumer()
+			client.EXPECT().XGroupCreateMkStream(gomock.Any(), "events", "group", "$").Return(redis.NewStatusResult("OK", nil))
+			gomock.InOrder(
+				client.EXPECT().XReadGroup(gomock.Any(), readArgs("0", -1)).
+					Return(redis.NewXStreamSliceCmdResult(nil, redis.Nil)),
+				client.EXPECT().XReadGroup(gomock.Any(), readArgs(">", 10*time.Millisecond)).
+					Return(redis.NewXStreamSliceCmdResult([]redis.XStream{{Stream: "events", Messages: []redis.XMessage{entry("3-0", "handle")}}}, nil)),
+				client.EXPECT().XReadGroup(gomock.Any(), readArgs(">", 10*time.Millisecond)).
+					Return(redis.NewXStreamSliceCmdResult(nil, redis.Nil)).AnyTimes(),
+			)
+
+			done := make(chan error)
+			go func() {
+				defer GinkgoRecover()
+				done <- consumer.ConsumeLoop()
+			}()
+			defer func() {
+				consumer.StopConsuming()
+				Eventually(done).Should(Receive(BeNil()))
+			}()
+			var m *Message
+			Eventually(*consumer.MessageHandlesChannel()).Should(Receive(&m))
+			Expect(m.ID).To(Equal("3-0"))
+			Expect(m.Value).To(Equal([]byte("handle")))
+
+			client.EXPECT().XAck(gomock.Any(), "events", "group", "3-0").Return(redis.NewIntResult(1, nil))
+			Expect(m.Ack()).To(Succeed())
+			Expect(m.Ack()).To(Succeed())
+			consumer.PendingMessagesWaitGroup().Wait()
+		})
+
+		It("should reclaim idle entries", func() {
+			config.Set("extensions.redisstreams.messageHandles", true)
+			consumer := newConsumer()
+			consumer.run = true
+			claimed := redis.NewXAutoClaimCmd(context.Background())
+			claimed.SetVal([]redis.XMessage{entry("4-0", "claimed")}, "0-0")
+			client.EXPECT().XAutoClaim(gomock.Any(), &redis.XAutoClaimArgs{
+				Stream:   "events",
+				Group:    "group",
+				Consumer: "consumer-1",
+				MinIdle:  time.Minute,
+				Start:    "0-0",
+				Count:    10,
+			}).Return(claimed)
+
+			Expect(consumer.reclaim(context.Background(), make(chan struct{}))).To(Succeed())
+			var m *Message
+			Expect(*consumer.MessageHandlesChannel()).To(Receive(&m))
+			Expect(m.ID).To(Equal("4-0"))
+		})
+
+		It("should move entries delivered too many times to the dead-letter stream", func() {
+			config.Set("extensions.redisstreams.maxDeliveries", 3)
+			config.Set("extensions.redisstreams.deadLetterStream", "events.dlq")
+			consumer := newConsumer()
+			pending := redis.NewXPendingExtCmd(context.Background())
+			pending.SetVal([]redis.XPendingExt{{ID: "5-0", RetryCount: 3}, {ID: "6-0", RetryCount: 1}})
+			client.EXPECT().XPendingExt(gomock.Any(), gomock.Any()).Return(pending)
+			client.EXPECT().XRange(gomock.Any(), "events", "5-0", "5-0").
+				Return(redis.NewXMessageSliceCmdResult([]redis.XMessage{entry("5-0", "poison")}, nil))
+			client.EXPECT().XAdd(gomock.Any(), &redis.XAddArgs{
+				Stream: "events.dlq",
+				Values: map[string]interface{}{"stream": "events", "id": "5-0", "deliveries": int64(3), "data": "poison"},
+			}).Return(redis.NewStringCmd(context.Background()))
+			client.EXPECT().XAck(gomock.Any(), "events", "group", "5-0").Return(redis.NewIntResult(1, nil))
+
+			Expect(consumer.deadLetter(context.Background())).To(Succeed())
+		})
+
+		It("should page through the pending entries to find entries to dead-letter", func() {
+			config.Set("extensions.redisstreams.maxDeliveries", 3)
+			config.Set("extensions.redisstreams.deadLetterStream", "events.dlq")
+			consumer := newConsumer()
+			firstPage := make([]redis.XPendingExt, 0, 10)
+			for i := 1; i <= 10; i++ {
+				firstPage = append(firstPage, redis.XPendingExt{ID: fmt.Sprintf("%d-0", i), RetryCount: 1})
+			}
+			first := redis.NewXPendingExtCmd(context.Background())
+			first.SetVal(firstPage)
+			second := redis.NewXPendingExtCmd(context.Background())
+			second.SetVal([]redis.XPendingExt{{ID: "11-0", RetryCount: 3}})
+			args := func(start string) *redis.XPendingExtArgs {
+				return &redis.XPendingExtArgs{Stream: "events", Group: "group", Idle: time.Minute, Start: start, End: "+", Count: 10}
+			}
+			gomock.InOrder(
+				client.EXPECT().XPendingExt(gomock.Any(), args("-")).Return(first),
+				client.EXPECT().XPendingExt(gomock.Any(), args("(10-0")).Return(second),
+			)
+			client.EXPECT().XRange(gomock.Any(), "events", "11-0", "11-0").
+				Return(redis.NewXMessageSliceCmdResult([]redis.XMessage{entry("11-0", "poison")}, nil))
+			client.EXPECT().XAdd(gomock.Any(), gomock.Any()).Return(redis.NewStringCmd(context.Background()))
+			client.EXPECT().XAck(gomock.Any(), "events", "group", "11-0").Return(redis.NewIntResult(1, nil))
+
+			Expect(consumer.deadLetter(context.Background())).To(Succeed())
+		})
+	})
+})
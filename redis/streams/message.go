@@ -0,0 +1,55 @@
+/*
+ * Copyright (c) 2026 TFG Co
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package streams
+
+import (
+	"context"
+	"sync"
+)
+
+// Message is an entry read from a stream, it must be acknowledged with Ack once processed
+// or it is delivered again to this or another consumer after MinIdle
+type Message struct {
+	ID     string
+	Stream string
+	Values map[string]interface{}
+	// Value is the payload held in the consumer Field
+	Value []byte
+
+	consumer *Consumer
+	ackOnce  sync.Once
+	ackErr   error
+}
+
+// Ack acknowledges the message with XACK, removing it from the pending entries of the group
+func (m *Message) Ack() error {
+	return m.AckWithContext(context.Background())
+}
+
+// AckWithContext acknowledges the message with XACK, acknowledging more than once is a no-op
+func (m *Message) AckWithContext(ctx context.Context) error {
+	m.ackOnce.Do(func() {
+		m.ackErr = m.consumer.ack(ctx, m)
+	})
+	return m.ackErr
+}
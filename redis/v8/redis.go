@@ -30,30 +30,62 @@ import (
 
 	"github.com/go-redis/redis/v8"
 	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/redis/scripting"
 	redistracing "github.com/topfreegames/extensions/v9/tracing/redis/v8"
 )
 
+// ClientConfig holds the input configs for a client. Setting SentinelMasterName connects
+// through sentinel, using URL only for credentials and timeouts. ReplicaReads routes
+// read-only commands to the replicas found by sentinel or to ReplicaURL. Scripts registered in
+// Scripts are preloaded once connected
 type ClientConfig struct {
-	URL               string
-	ConnectionTimeout time.Duration
+	URL                string
+	ConnectionTimeout  time.Duration
+	SentinelMasterName string
+	SentinelAddrs      []string
+	SentinelPassword   string
+	ReplicaReads       bool
+	ReplicaURL         string
+	Scripts            *scripting.Registry
 }
 
-// NewClient creates and returns a new redis client based on the given settings
-func NewClient(ctx context.Context, prefix string, config *viper.Viper) (*redis.Client, error) {
-	clientConfig := &ClientConfig{
-		URL:               config.GetString(fmt.Sprintf("%s.url", prefix)),
-		ConnectionTimeout: config.GetDuration(fmt.Sprintf("%s.connectionTimeout", prefix)),
+// CreateClientConfig reads a ClientConfig under prefix
+func CreateClientConfig(prefix string, config *viper.Viper) *ClientConfig {
+	return &ClientConfig{
+		URL:                config.GetString(fmt.Sprintf("%s.url", prefix)),
+		ConnectionTimeout:  config.GetDuration(fmt.Sprintf("%s.connectionTimeout", prefix)),
+		SentinelMasterName: config.GetString(fmt.Sprintf("%s.sentinel.masterName", prefix)),
+		SentinelAddrs:      config.GetStringSlice(fmt.Sprintf("%s.sentinel.addrs", prefix)),
+		SentinelPassword:   config.GetString(fmt.Sprintf("%s.sentinel.password", prefix)),
+		ReplicaReads:       config.GetBool(fmt.Sprintf("%s.replicaReads", prefix)),
+		ReplicaURL:         config.GetString(fmt.Sprintf("%s.replicaUrl", prefix)),
 	}
-	return NewClientFromConfig(ctx, clientConfig)
 }
 
-// NewClientFromConfig creates a Client with a ClientConfig.
+// NewClient creates and returns a new redis client based on the given settings, connecting
+// the given script registry and preloading its scripts
+func NewClient(ctx context.Context, prefix string, config *viper.Viper, scripts ...*scripting.Registry) (*redis.Client, error) {
+	return NewClientFromConfig(ctx, withScripts(CreateClientConfig(prefix, config), scripts))
+}
+
+// NewClientFromConfig creates a Client with a ClientConfig. Replica reads need more than one
+// connection pool and are only supported by NewUniversalClientFromConfig
 func NewClientFromConfig(ctx context.Context, config *ClientConfig) (*redis.Client, error) {
-	options, err := redis.ParseURL(config.URL)
+	if config.ReplicaReads {
+		return nil, errors.New("replica reads are only supported by NewUniversalClient")
+	}
+
+	options, err := parseURL(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse redis url: %w", err)
+		return nil, err
+	}
+
+	var client *redis.Client
+	if config.SentinelMasterName != "" {
+		client = redis.NewFailoverClient(failoverOptions(config, options))
+	} else {
+		client = redis.NewClient(options)
 	}
-	client := redis.NewClient(options)
 
 	err = waitForConnection(ctx, client, config.ConnectionTimeout)
 	if err != nil {
@@ -62,10 +94,143 @@ func NewClientFromConfig(ctx context.Context, config *ClientConfig) (*redis.Clie
 
 	redistracing.Instrument(client)
 
+	err = loadScripts(ctx, client, config.Scripts)
+	if err != nil {
+		return nil, err
+	}
+
 	return client, nil
 }
 
-func waitForConnection(ctx context.Context, client *redis.Client, timeout time.Duration) error {
+// NewUniversalClient creates and returns a new redis client based on the given settings,
+// supporting replica reads
+func NewUniversalClient(ctx context.Context, prefix string, config *viper.Viper, scripts ...*scripting.Registry) (redis.UniversalClient, error) {
+	return NewUniversalClientFromConfig(ctx, withScripts(CreateClientConfig(prefix, config), scripts))
+}
+
+// NewUniversalClientFromConfig creates a client with a ClientConfig. With replica reads it
+// returns a cluster client that sends writes to the primary and read-only commands either to
+// the replica set by ReplicaURL or to a random node among the primary and the replicas
+// discovered by sentinel
+func NewUniversalClientFromConfig(ctx context.Context, config *ClientConfig) (redis.UniversalClient, error) {
+	if !config.ReplicaReads {
+		return NewClientFromConfig(ctx, config)
+	}
+
+	options, err := parseURL(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var client *redis.ClusterClient
+	switch {
+	case config.SentinelMasterName != "":
+		failover := failoverOptions(config, options)
+		failover.RouteRandomly = true
+		client = redis.NewFailoverClusterClient(failover)
+	case config.ReplicaURL != "":
+		replica, err := redis.ParseURL(config.ReplicaURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse redis replica url: %w", err)
+		}
+		client, err = replicaClusterClient(options, replica)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("replica reads need a sentinel master name or a replica url")
+	}
+
+	err = waitForConnection(ctx, client, config.ConnectionTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	redistracing.InstrumentUniversal(client)
+
+	err = loadScripts(ctx, client, config.Scripts)
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+func withScripts(config *ClientConfig, scripts []*scripting.Registry) *ClientConfig {
+	if len(scripts) > 0 {
+		config.Scripts = scripts[0]
+	}
+	return config
+}
+
+// loadScripts connects scripts to client, closing the client if they could not be loaded
+func loadScripts(ctx context.Context, client redis.UniversalClient, scripts *scripting.Registry) error {
+	if scripts == nil {
+		return nil
+	}
+	err := scripts.Connect(ctx, scripting.FromV8(client))
+	if err != nil {
+		client.Close()
+		return fmt.Errorf("failed to load redis scripts: %w", err)
+	}
+	return nil
+}
+
+func parseURL(config *ClientConfig) (*redis.Options, error) {
+	if config.SentinelMasterName != "" && config.URL == "" {
+		return &redis.Options{}, nil
+	}
+	options, err := redis.ParseURL(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse redis url: %w", err)
+	}
+	return options, nil
+}
+
+func failoverOptions(config *ClientConfig, options *redis.Options) *redis.FailoverOptions {
+	return &redis.FailoverOptions{
+		MasterName:       config.SentinelMasterName,
+		SentinelAddrs:    config.SentinelAddrs,
+		SentinelPassword: config.SentinelPassword,
+		Username:         options.Username,
+		Password:         options.Password,
+		DB:               options.DB,
+		MaxRetries:       options.MaxRetries,
+		DialTimeout:      options.DialTimeout,
+		ReadTimeout:      options.ReadTimeout,
+		WriteTimeout:     options.WriteTimeout,
+		PoolSize:         options.PoolSize,
+		TLSConfig:        options.TLSConfig,
+	}
+}
+
+// replicaClusterClient routes commands between a primary and its replica by describing them
+// as a single shard cluster, which is how go-redis supports replicas without sentinel
+func replicaClusterClient(primary, replica *redis.Options) (*redis.ClusterClient, error) {
+	if primary.DB != 0 {
+		return nil, errors.New("replica reads only support db 0")
+	}
+	return redis.NewClusterClient(&redis.ClusterOptions{
+		ClusterSlots: func(ctx context.Context) ([]redis.ClusterSlot, error) {
+			return []redis.ClusterSlot{{
+				Start: 0,
+				End:   16383,
+				Nodes: []redis.ClusterNode{{Addr: primary.Addr}, {Addr: replica.Addr}},
+			}}, nil
+		},
+		ReadOnly:     true,
+		Username:     primary.Username,
+		Password:     primary.Password,
+		MaxRetries:   primary.MaxRetries,
+		DialTimeout:  primary.DialTimeout,
+		ReadTimeout:  primary.ReadTimeout,
+		WriteTimeout: primary.WriteTimeout,
+		PoolSize:     primary.PoolSize,
+		TLSConfig:    primary.TLSConfig,
+	}), nil
+}
+
+func waitForConnection(ctx context.Context, client redis.UniversalClient, timeout time.Duration) error {
 	timeoutTimer := time.NewTimer(timeout)
 	defer timeoutTimer.Stop()
 	ticker := time.NewTicker(10 * time.Millisecond)
@@ -83,7 +248,7 @@ func waitForConnection(ctx context.Context, client *redis.Client, timeout time.D
 	}
 }
 
-func isConnected(ctx context.Context, client *redis.Client) bool {
+func isConnected(ctx context.Context, client redis.UniversalClient) bool {
 	result, err := client.Ping(ctx).Result()
 	if err != nil {
 		return false
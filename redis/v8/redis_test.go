@@ -31,6 +31,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/spf13/viper"
+	"github.com/topfreegames/extensions/v9/redis/scripting"
 	redisextensions "github.com/topfreegames/extensions/v9/redis/v8"
 )
 
@@ -48,6 +49,18 @@ var _ = Describe("Redis", func() {
 	})
 
 	Describe("[Integration]", func() {
+		It("should preload the scripts of a registry", func() {
+			config := viper.New()
+			config.SetConfigFile("../../config/test.yaml")
+			Expect(config.ReadInConfig()).NotTo(HaveOccurred())
+			scripts := scripting.NewRegistry(nil)
+			script := scripts.Register("extensions.test", "return 'loaded'")
+			client, err := redisextensions.NewClient(ctx, "extensions.redisV8", config, scripts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(client.ScriptExists(ctx, script.SHA1).Result()).To(Equal([]bool{true}))
+			Expect(scripts.Run(ctx, "extensions.test", []string{})).To(Equal("loaded"))
+		})
+
 		It("set", func() {
 			key := "extensions:testkey"
 			expectedValue := "value"
@@ -60,6 +73,56 @@ var _ = Describe("Redis", func() {
 	})
 })
 
+var _ = Describe("Client config", func() {
+	Describe("[Unit]", func() {
+		It("should read sentinel and replica settings", func() {
+			config := viper.New()
+			config.Set("redis.url", "redis://:pass@localhost:6379/0")
+			config.Set("redis.connectionTimeout", "1s")
+			config.Set("redis.sentinel.masterName", "mymaster")
+			config.Set("redis.sentinel.addrs", []string{"localhost:26379", "localhost:26380"})
+			config.Set("redis.sentinel.password", "sentinelpass")
+			config.Set("redis.replicaReads", true)
+			config.Set("redis.replicaUrl", "redis://replica:6379/0")
+
+			Expect(redisextensions.CreateClientConfig("redis", config)).To(Equal(&redisextensions.ClientConfig{
+				URL:                "redis://:pass@localhost:6379/0",
+				ConnectionTimeout:  time.Second,
+				SentinelMasterName: "mymaster",
+				SentinelAddrs:      []string{"localhost:26379", "localhost:26380"},
+				SentinelPassword:   "sentinelpass",
+				ReplicaReads:       true,
+				ReplicaURL:         "redis://replica:6379/0",
+			}))
+		})
+
+		It("should only support replica reads on universal clients", func() {
+			_, err := redisextensions.NewClientFromConfig(context.TODO(), &redisextensions.ClientConfig{
+				URL:          "redis://localhost:6379/0",
+				ReplicaReads: true,
+			})
+			Expect(err).To(MatchError("replica reads are only supported by NewUniversalClient"))
+		})
+
+		It("should need sentinel or a replica url for replica reads", func() {
+			_, err := redisextensions.NewUniversalClientFromConfig(context.TODO(), &redisextensions.ClientConfig{
+				URL:          "redis://localhost:6379/0",
+				ReplicaReads: true,
+			})
+			Expect(err).To(MatchError("replica reads need a sentinel master name or a replica url"))
+		})
+
+		It("should only support db 0 with a replica url", func() {
+			_, err := redisextensions.NewUniversalClientFromConfig(context.TODO(), &redisextensions.ClientConfig{
+				URL:          "redis://localhost:6379/1",
+				ReplicaReads: true,
+				ReplicaURL:   "redis://replica:6379/1",
+			})
+			Expect(err).To(MatchError("replica reads only support db 0"))
+		})
+	})
+})
+
 func TestRedisExtensions(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Redis v8 extensions")
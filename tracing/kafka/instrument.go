@@ -0,0 +1,145 @@
+/*
+ * Copyright (c) 2026 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package kafka
+
+import (
+	"context"
+
+	"github.com/Shopify/sarama"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/opentracing/opentracing-go"
+	"github.com/topfreegames/extensions/v9/tracing"
+)
+
+// StartProduceSpan starts a span for producing a message to topic, as a child of
+// the span active in ctx
+func StartProduceSpan(ctx context.Context, topic string) (opentracing.Span, context.Context) {
+	var parent opentracing.SpanContext
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		parent = span.Context()
+	}
+
+	operationName := "kafka produce " + topic
+	reference := opentracing.ChildOf(parent)
+	tags := opentracing.Tags{
+		"message_bus.destination": topic,
+		"span.kind":               "producer",
+	}
+	tags = tracing.RunCustomTracingTagsHooks(ctx, tags)
+
+	span := opentracing.StartSpan(operationName, reference, tags)
+	tracing.RunCustomTracingHooks(ctx, operationName, span)
+	return span, opentracing.ContextWithSpan(ctx, span)
+}
+
+// StartConsumeSpan starts a span for consuming a message, as a child of parent when
+// it is not nil
+func StartConsumeSpan(
+	ctx context.Context,
+	parent opentracing.SpanContext,
+	topic string,
+	partition int32,
+	offset int64,
+) (opentracing.Span, context.Context) {
+	operationName := "kafka consume " + topic
+	reference := opentracing.ChildOf(parent)
+	tags := opentracing.Tags{
+		"message_bus.destination": topic,
+		"kafka.partition":         partition,
+		"kafka.offset":            offset,
+		"span.kind":               "consumer",
+	}
+	tags = tracing.RunCustomTracingTagsHooks(ctx, tags)
+
+	span := opentracing.StartSpan(operationName, reference, tags)
+	tracing.RunCustomTracingHooks(ctx, operationName, span)
+	return span, opentracing.ContextWithSpan(ctx, span)
+}
+
+// Inject writes the context of span into the headers of message, replacing the span
+// context it already carried. Spans are started, injected and extracted with the
+// global tracer
+func Inject(span opentracing.Span, message *sarama.ProducerMessage) error {
+	carrier := opentracing.TextMapCarrier{}
+	err := opentracing.GlobalTracer().Inject(span.Context(), opentracing.TextMap, carrier)
+	if err != nil {
+		return err
+	}
+	headers := make([]sarama.RecordHeader, 0, len(message.Headers)+len(carrier))
+	for _, header := range message.Headers {
+		if _, ok := carrier[string(header.Key)]; !ok {
+			headers = append(headers, header)
+		}
+	}
+	for key, value := range carrier {
+		headers = append(headers, sarama.RecordHeader{
+			Key:   []byte(key),
+			Value: []byte(value),
+		})
+	}
+	message.Headers = headers
+	return nil
+}
+
+// Extract reads a span context from the headers of a consumed message
+func Extract(headers []*sarama.RecordHeader) (opentracing.SpanContext, error) {
+	carrier := opentracing.TextMapCarrier{}
+	for _, header := range headers {
+		carrier[string(header.Key)] = string(header.Value)
+	}
+	return opentracing.GlobalTracer().Extract(opentracing.TextMap, carrier)
+}
+
+// InjectConfluent writes the context of span into the headers of a message produced with
+// the confluent client, like Inject
+func InjectConfluent(span opentracing.Span, message *kafka.Message) error {
+	carrier := opentracing.TextMapCarrier{}
+	err := opentracing.GlobalTracer().Inject(span.Context(), opentracing.TextMap, carrier)
+	if err != nil {
+		return err
+	}
+	headers := make([]kafka.Header, 0, len(message.Headers)+len(carrier))
+	for _, header := range message.Headers {
+		if _, ok := carrier[header.Key]; !ok {
+			headers = append(headers, header)
+		}
+	}
+	for key, value := range carrier {
+		headers = append(headers, kafka.Header{
+			Key:   key,
+			Value: []byte(value),
+		})
+	}
+	message.Headers = headers
+	return nil
+}
+
+// ExtractConfluent reads a span context from the headers of a message consumed with the
+// confluent client
+func ExtractConfluent(headers []kafka.Header) (opentracing.SpanContext, error) {
+	carrier := opentracing.TextMapCarrier{}
+	for _, header := range headers {
+		carrier[header.Key] = string(header.Value)
+	}
+	return opentracing.GlobalTracer().Extract(opentracing.TextMap, carrier)
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright (c) 2026 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+var _ = Describe("Tracing Kafka", func() {
+	var tracer *mocktracer.MockTracer
+
+	BeforeEach(func() {
+		tracer = mocktracer.New()
+		opentracing.SetGlobalTracer(tracer)
+	})
+
+	AfterEach(func() {
+		opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+	})
+
+	Describe("[Unit]", func() {
+		It("should start produce spans as children of the active span", func() {
+			parent := tracer.StartSpan("parent")
+			ctx := opentracing.ContextWithSpan(context.Background(), parent)
+
+			span, spanCtx := StartProduceSpan(ctx, "topic")
+			span.Finish()
+
+			Expect(opentracing.SpanFromContext(spanCtx)).To(Equal(span))
+			finished := tracer.FinishedSpans()
+			Expect(finished).To(HaveLen(1))
+			Expect(finished[0].OperationName).To(Equal("kafka produce topic"))
+			Expect(finished[0].ParentID).To(Equal(parent.(*mocktracer.MockSpan).SpanContext.SpanID))
+			Expect(finished[0].Tag("message_bus.destination")).To(Equal("topic"))
+			Expect(finished[0].Tag("span.kind")).To(Equal("producer"))
+		})
+
+		It("should start consume spans with topic, partition and offset tags", func() {
+			span, _ := StartConsumeSpan(context.Background(), nil, "topic", 2, 42)
+			span.Finish()
+
+			finished := tracer.FinishedSpans()
+			Expect(finished).To(HaveLen(1))
+			Expect(finished[0].OperationName).To(Equal("kafka consume topic"))
+			Expect(finished[0].ParentID).To(BeZero())
+			Expect(finished[0].Tag("kafka.partition")).To(BeEquivalentTo(2))
+			Expect(finished[0].Tag("kafka.offset")).To(BeEquivalentTo(42))
+			Expect(finished[0].Tag("span.kind")).To(Equal("consumer"))
+		})
+
+		It("should propagate span context through message headers", func() {
+			span, _ := StartProduceSpan(context.Background(), "topic")
+			message := &sarama.ProducerMessage{Topic: "topic"}
+			Expect(Inject(span, message)).To(Succeed())
+			Expect(message.Headers).NotTo(BeEmpty())
+
+			headers := []*sarama.RecordHeader{}
+			for i := range message.Headers {
+				headers = append(headers, &message.Headers[i])
+			}
+			spanContext, err := Extract(headers)
+			Expect(err).NotTo(HaveOccurred())
+
+			child, _ := StartConsumeSpan(context.Background(), spanContext, "topic", 0, 0)
+			child.Finish()
+			span.Finish()
+			Expect(child.(*mocktracer.MockSpan).ParentID).To(Equal(span.(*mocktracer.MockSpan).SpanContext.SpanID))
+			Expect(child.(*mocktracer.MockSpan).SpanContext.TraceID).To(Equal(span.(*mocktracer.MockSpan).SpanContext.TraceID))
+		})
+
+		It("should propagate span context through confluent message headers", func() {
+			span, _ := StartProduceSpan(context.Background(), "topic")
+			message := &kafka.Message{}
+			Expect(InjectConfluent(span, message)).To(Succeed())
+			Expect(message.Headers).NotTo(BeEmpty())
+
+			spanContext, err := ExtractConfluent(message.Headers)
+			Expect(err).NotTo(HaveOccurred())
+			child, _ := StartConsumeSpan(context.Background(), spanContext, "topic", 0, 0)
+			child.Finish()
+			span.Finish()
+			Expect(child.(*mocktracer.MockSpan).ParentID).To(Equal(span.(*mocktracer.MockSpan).SpanContext.SpanID))
+		})
+
+		It("should replace the span context carried by a message", func() {
+			first, _ := StartProduceSpan(context.Background(), "topic")
+			message := &kafka.Message{Headers: []kafka.Header{{Key: "custom", Value: []byte("value")}}}
+			Expect(InjectConfluent(first, message)).To(Succeed())
+			headers := len(message.Headers)
+			second, _ := StartProduceSpan(context.Background(), "topic")
+			Expect(InjectConfluent(second, message)).To(Succeed())
+			Expect(message.Headers).To(HaveLen(headers))
+			Expect(message.Headers[0]).To(Equal(kafka.Header{Key: "custom", Value: []byte("value")}))
+
+			spanContext, err := ExtractConfluent(message.Headers)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spanContext.(mocktracer.MockSpanContext).SpanID).To(Equal(second.(*mocktracer.MockSpan).SpanContext.SpanID))
+		})
+
+		It("should not find a span context in messages without headers", func() {
+			_, err := ExtractConfluent(nil)
+			Expect(err).To(Equal(opentracing.ErrSpanContextNotFound))
+		})
+	})
+})
+
+func TestTracingKafka(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Tracing Kafka")
+}
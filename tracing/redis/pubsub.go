@@ -0,0 +1,71 @@
+/*
+ * Copyright (c) 2026 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package redis
+
+import (
+	"context"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/topfreegames/extensions/v9/tracing"
+)
+
+// StartPublishSpan starts a span for publishing a message to a pub/sub channel, as a child of
+// the span active in ctx
+func StartPublishSpan(ctx context.Context, channel string) (opentracing.Span, context.Context) {
+	var parent opentracing.SpanContext
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		parent = span.Context()
+	}
+
+	operationName := "redis publish " + channel
+	reference := opentracing.ChildOf(parent)
+	tags := opentracing.Tags{
+		"db.type":                 "redis",
+		"message_bus.destination": channel,
+		"span.kind":               "producer",
+	}
+	tags = tracing.RunCustomTracingTagsHooks(ctx, tags)
+
+	span := opentracing.StartSpan(operationName, reference, tags)
+	tracing.RunCustomTracingHooks(ctx, operationName, span)
+	return span, opentracing.ContextWithSpan(ctx, span)
+}
+
+// StartReceiveSpan starts a span for handling a message received from a pub/sub channel,
+// pattern is the subscribed pattern the channel matched, if any
+func StartReceiveSpan(ctx context.Context, channel, pattern string) (opentracing.Span, context.Context) {
+	operationName := "redis receive " + channel
+	tags := opentracing.Tags{
+		"db.type":                 "redis",
+		"message_bus.destination": channel,
+		"span.kind":               "consumer",
+	}
+	if pattern != "" {
+		tags["redis.pattern"] = pattern
+	}
+	tags = tracing.RunCustomTracingTagsHooks(ctx, tags)
+
+	span := opentracing.StartSpan(operationName, tags)
+	tracing.RunCustomTracingHooks(ctx, operationName, span)
+	return span, opentracing.ContextWithSpan(ctx, span)
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright (c) 2026 TFG Co <backend@tfgco.com>
+ * Author: TFG Co <backend@tfgco.com>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+ * the Software, and to permit persons to whom the Software is furnished to do so,
+ * subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+ * FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+ * COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+ * IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+ * CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package redis
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+var _ = Describe("Tracing Redis Pub/Sub", func() {
+	var tracer *mocktracer.MockTracer
+
+	BeforeEach(func() {
+		tracer = mocktracer.New()
+		opentracing.SetGlobalTracer(tracer)
+	})
+
+	AfterEach(func() {
+		opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+	})
+
+	Describe("[Unit]", func() {
+		It("should start publish spans as children of the active span", func() {
+			parent := tracer.StartSpan("parent")
+			ctx := opentracing.ContextWithSpan(context.Background(), parent)
+
+			span, spanCtx := StartPublishSpan(ctx, "channel")
+			span.Finish()
+
+			Expect(opentracing.SpanFromContext(spanCtx)).To(Equal(span))
+			finished := tracer.FinishedSpans()
+			Expect(finished).To(HaveLen(1))
+			Expect(finished[0].OperationName).To(Equal("redis publish channel"))
+			Expect(finished[0].ParentID).To(Equal(parent.(*mocktracer.MockSpan).SpanContext.SpanID))
+			Expect(finished[0].Tag("message_bus.destination")).To(Equal("channel"))
+			Expect(finished[0].Tag("span.kind")).To(Equal("producer"))
+		})
+
+		It("should start receive spans with the matched pattern", func() {
+			span, _ := StartReceiveSpan(context.Background(), "news.sports", "news.*")
+			span.Finish()
+
+			finished := tracer.FinishedSpans()
+			Expect(finished).To(HaveLen(1))
+			Expect(finished[0].OperationName).To(Equal("redis receive news.sports"))
+			Expect(finished[0].ParentID).To(BeZero())
+			Expect(finished[0].Tag("redis.pattern")).To(Equal("news.*"))
+			Expect(finished[0].Tag("span.kind")).To(Equal("consumer"))
+		})
+
+		It("should not tag receive spans of channel subscriptions with a pattern", func() {
+			span, _ := StartReceiveSpan(context.Background(), "channel", "")
+			span.Finish()
+
+			Expect(tracer.FinishedSpans()[0].Tags()).NotTo(HaveKey("redis.pattern"))
+		})
+	})
+})
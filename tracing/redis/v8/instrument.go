@@ -33,17 +33,27 @@ import (
 )
 
 type redisTracingHook struct {
-	client *redis.Client
+	db int
 }
 
 // Instrument adds tracing instrumentation on a Redis client
 func Instrument(client *redis.Client) {
-	client.AddHook(redisTracingHook{client: client})
+	client.AddHook(redisTracingHook{db: client.Options().DB})
+}
+
+// InstrumentUniversal adds tracing instrumentation on a Redis client of any kind, such as the
+// failover and cluster clients used for sentinel and replica reads
+func InstrumentUniversal(client redis.UniversalClient) {
+	if c, ok := client.(*redis.Client); ok {
+		Instrument(c)
+		return
+	}
+	client.AddHook(redisTracingHook{})
 }
 
 func (hook redisTracingHook) createSpan(ctx context.Context, operationName string) (opentracing.Span, context.Context) {
 	tags := opentracing.Tags{
-		"db.instance": hook.client.Options().DB,
+		"db.instance": hook.db,
 		"db.type":     "redis",
 		"span.kind":   "client",
 	}